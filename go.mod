@@ -7,6 +7,7 @@ require (
 	github.com/mholt/archiver/v4 v4.0.0-alpha.9
 	github.com/openai/openai-go v0.1.0-alpha.62
 	github.com/spf13/viper v1.18.2
+	golang.org/x/text v0.19.0
 	modernc.org/sqlite v1.38.2
 )
 
@@ -72,7 +73,6 @@ require (
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
 	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
-	golang.org/x/text v0.19.0 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log-tools-go/pkg/xframe"
+	"path"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -26,6 +30,70 @@ type StorageConfig struct {
 	LogDir       string `mapstructure:"log_dir"`
 	MaxFileSize  int64  `mapstructure:"max_file_size"`
 	DatabasePath string `mapstructure:"database_path"`
+	MaxLineSize  int    `mapstructure:"max_line_size"` // 单行最大长度（字节），超出部分截断，为0时取默认值
+
+	MaxArchiveDepth     int     `mapstructure:"max_archive_depth"`     // 压缩包最大嵌套层数，为0时取默认值
+	MaxExtractSize      int64   `mapstructure:"max_extract_size"`      // 单次上传解压的总字节数上限，为0时取默认值
+	MaxExtractFiles     int     `mapstructure:"max_extract_files"`     // 单次上传解压的文件数上限，为0时取默认值
+	MaxCompressionRatio float64 `mapstructure:"max_compression_ratio"` // 解压后大小与压缩包大小的最大比例，为0时取默认值
+}
+
+// DefaultMaxLineSize 默认单行最大长度
+const DefaultMaxLineSize = 1024 * 1024
+
+// DefaultMaxArchiveDepth 默认压缩包最大嵌套层数
+const DefaultMaxArchiveDepth = 3
+
+// ArchiveDepth 压缩包最大嵌套层数，未配置时返回默认值
+func (c *Config) ArchiveDepth() int {
+	if c == nil || c.Storage.MaxArchiveDepth <= 0 {
+		return DefaultMaxArchiveDepth
+	}
+	return c.Storage.MaxArchiveDepth
+}
+
+// 解压限制默认值
+const (
+	DefaultMaxExtractSize      = 2 * 1024 * 1024 * 1024 // 2GB
+	DefaultMaxExtractFiles     = 1000
+	DefaultMaxCompressionRatio = 200
+)
+
+// ExtractLimits 解压限制
+type ExtractLimits struct {
+	MaxSize  int64
+	MaxFiles int
+	MaxRatio float64
+}
+
+// ExtractLimits 返回解压限制，未配置的项取默认值
+func (c *Config) ExtractLimits() ExtractLimits {
+	limits := ExtractLimits{
+		MaxSize:  DefaultMaxExtractSize,
+		MaxFiles: DefaultMaxExtractFiles,
+		MaxRatio: DefaultMaxCompressionRatio,
+	}
+	if c == nil {
+		return limits
+	}
+	if c.Storage.MaxExtractSize > 0 {
+		limits.MaxSize = c.Storage.MaxExtractSize
+	}
+	if c.Storage.MaxExtractFiles > 0 {
+		limits.MaxFiles = c.Storage.MaxExtractFiles
+	}
+	if c.Storage.MaxCompressionRatio > 0 {
+		limits.MaxRatio = c.Storage.MaxCompressionRatio
+	}
+	return limits
+}
+
+// LineLimit 单行最大长度，未配置时返回默认值
+func (c *Config) LineLimit() int {
+	if c == nil || c.Storage.MaxLineSize <= 0 {
+		return DefaultMaxLineSize
+	}
+	return c.Storage.MaxLineSize
 }
 
 type FilterConfig struct {
@@ -39,28 +107,114 @@ type AiConfig struct {
 	Model  string `mapstructure:"model"`
 }
 
+// 规则类型
+const (
+	RuleTypeRegex  = "regex"  // 逐字段正则（默认）
+	RuleTypeNamed  = "named"  // 单个命名分组正则
+	RuleTypeJSON   = "json"   // JSON行（结构化日志）
+	RuleTypeLogfmt = "logfmt" // logfmt（key=value）
+	RuleTypeLogcat = "logcat" // Android logcat 预设格式
+	RuleTypeSyslog = "syslog" // syslog（RFC 3164 / RFC 5424）
+	RuleTypeAccess = "access" // Web访问日志（Apache/Nginx）
+)
+
+// LogcatFormats logcat 支持的输出格式（-v 参数），year/epoch 为 threadtime 的时间变体
+var LogcatFormats = []string{"threadtime", "brief", "time", "long", "process", "year", "epoch"}
+
+// SyslogFormats syslog 支持的格式
+var SyslogFormats = []string{"rfc3164", "rfc5424"}
+
+// AccessFormats 访问日志预设格式，也可直接配置 Nginx log_format 字符串
+var AccessFormats = []string{"common", "combined"}
+
+// 无年份时间的年份来源
+const (
+	YearSourceMTime    = "mtime"    // 文件修改时间（默认）
+	YearSourceUpload   = "upload"   // 上传时间
+	YearSourceExplicit = "explicit" // 规则中指定的年份
+)
+
+// YearSources 支持的年份来源
+var YearSources = []string{YearSourceMTime, YearSourceUpload, YearSourceExplicit}
+
 // LogParseRule 新增：项目规则结构体
 type LogParseRule struct {
-	Timestamp       string `json:"timestamp"`        // 时间正则表达式
-	TimestampFormat string `json:"timestamp_format"` // 时间格式
-	Process         string `json:"process"`          // 进程正则表达式
-	Thread          string `json:"thread"`           // 线程正则表达式
-	Level           string `json:"level"`            // 日志级别正则表达式
-	Module          string `json:"module"`           // 模块名正则表达式
-	Class           string `json:"class"`            // 类名正则表达式
-	ClassLine       string `json:"class_line"`       // 类方法行号正则表达式
-	Tag             string `json:"tag"`              // 标签正则表达式
-	Message         string `json:"message"`          // 日志内容正则表达式
+	Type            string            `json:"type"`              // 规则类型[regex,named,json,logfmt,logcat,syslog,access]，为空时按regex处理
+	Pattern         string            `json:"pattern"`           // 命名分组正则表达式[named模式]，如(?P<timestamp>...)(?P<level>...)
+	Timestamp       string            `json:"timestamp"`         // 时间正则表达式
+	TimestampFormat string            `json:"timestamp_format"`  // 时间格式[Java写法如yyyy-MM-dd HH:mm:ss.SSS，或epoch_s/epoch_ms/epoch_us]，为空时自动识别
+	Process         string            `json:"process"`           // 进程正则表达式
+	Thread          string            `json:"thread"`            // 线程正则表达式
+	Level           string            `json:"level"`             // 日志级别正则表达式
+	Module          string            `json:"module"`            // 模块名正则表达式
+	Class           string            `json:"class"`             // 类名正则表达式
+	ClassLine       string            `json:"class_line"`        // 类方法行号正则表达式
+	Tag             string            `json:"tag"`               // 标签正则表达式
+	Message         string            `json:"message"`           // 日志内容正则表达式
+	RecordStart     string            `json:"record_start"`      // 记录起始正则表达式[多行日志合并]
+	RecordStartTime bool              `json:"record_start_time"` // 以可解析的时间戳作为记录起始[多行日志合并]
+	Format          string            `json:"format"`            // 预设格式[logcat: threadtime,brief,time,long,process,year,epoch; syslog: rfc3164,rfc5424; access: common,combined或Nginx log_format]，为空时自动识别
+	Fields          map[string]string `json:"fields"`            // 字段映射[json,logfmt]：字段名 -> 键路径（点号分隔表示嵌套，如 fields.msg）
+	YearSource      string            `json:"year_source"`       // 无年份时间的年份来源[mtime,upload,explicit]，为空时按mtime处理
+	Year            int               `json:"year"`              // 指定年份[year_source为explicit时使用]
+	TimeZone        string            `json:"time_zone"`         // 不含时区的时间所属时区，如Asia/Shanghai、Local，为空时按UTC处理
+	Levels          map[string]string `json:"levels"`            // 级别映射：原始值 -> 标准级别[TRACE,DEBUG,INFO,WARN,ERROR,FATAL]，未配置的按常见写法映射
+	CustomFields    []CustomField     `json:"custom_fields"`     // 自定义提取字段，结果保存在扩展属性中
+	Encoding        string            `json:"encoding"`          // 文件编码，如gbk、gb18030、utf-16le，为空或auto时自动识别
+	Include         []string          `json:"include"`           // 压缩包内导入的文件[glob，匹配完整路径或文件名]，为空时导入所有文本文件
+	Exclude         []string          `json:"exclude"`           // 压缩包内排除的文件[glob，匹配完整路径或文件名]，优先于include
+	Decoders        []FrameDecoder    `json:"decoders"`          // 十六进制帧解码器，解码结果保存在扩展属性中
 }
+
+// 自定义字段类型
+const (
+	FieldTypeString   = "string"   // 字符串（默认）
+	FieldTypeInt      = "int"      // 整数
+	FieldTypeFloat    = "float"    // 浮点数
+	FieldTypeDuration = "duration" // 时长，如 1.5s、120ms，按毫秒保存
+)
+
+// FieldTypes 支持的自定义字段类型
+var FieldTypes = []string{FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeDuration}
+
+// CustomField 自定义提取字段
+type CustomField struct {
+	Name    string `json:"name"`    // 字段名，即扩展属性的键
+	Pattern string `json:"pattern"` // 正则表达式，取第一个分组，无分组时取整个匹配
+	Type    string `json:"type"`    // 类型[string,int,float,duration]，为空时按string处理
+}
+
+// FrameDecoder 十六进制帧解码器，从行中提取帧并按协议解码，
+// 结果以 name 为键保存在扩展属性中（command、command_id、checksum 及各字段）
+type FrameDecoder struct {
+	Name     string          `json:"name"`     // 解码器名称，即扩展属性的键
+	Pattern  string          `json:"pattern"`  // 提取帧的正则，取第一个分组，无分组时取整个匹配
+	Protocol xframe.Protocol `json:"protocol"` // 帧协议：帧头、长度字段、命令表、字段布局和校验
+}
+
+// FrameAttrs 帧解码器写入扩展属性的固定键，字段不能与之重名
+var FrameAttrs = []string{"command", "command_id", "checksum"}
+
 type LogProjectKeyword struct {
-	Keyword string `json:"keyword"` // 关键词
-	Desc    string `json:"desc"`    // 描述
-	Mode    string `json:"mode"`    // 匹配模式[word,regular]
-	Color   string `json:"color"`   // 颜色
+	Keyword     string `json:"keyword"`     // 关键词
+	Desc        string `json:"desc"`        // 描述
+	Mode        string `json:"mode"`        // 匹配模式[word,regular]，为空时按word处理
+	Color       string `json:"color"`       // 颜色
+	MaxGap      int    `json:"max_gap"`     // 顺序规则：距上一步的最大间隔（毫秒），为0时不限制
+	Correlation string `json:"correlation"` // 顺序规则：提取关联键的正则（取第一个分组），为空时使用场景的关联键
 }
+
+// 关键词匹配模式
+const (
+	KeywordModeWord    = "word"    // 包含关键词文本
+	KeywordModeRegular = "regular" // 正则表达式
+)
+
 type LogProjectScene struct {
-	Name     string              `json:"name"`
-	Keywords []LogProjectKeyword `json:"keywords"`
+	Name        string              `json:"name"`
+	Keywords    []LogProjectKeyword `json:"keywords"`    // 关键词，顺序规则中按定义顺序作为步骤
+	Correlation string              `json:"correlation"` // 顺序规则：提取关联键的正则（取第一个分组，如帧头字节），关联键相同的步骤属于同一次流程
+	Timeout     int                 `json:"timeout"`     // 顺序规则：整个流程的最长耗时（毫秒），为0时不限制
 }
 type LogProjectModule struct {
 	Name   string            `json:"name"`
@@ -73,6 +227,205 @@ type LogProjectRule struct {
 	Modules     []LogProjectModule `json:"modules"`
 }
 
+// Validate 校验项目的解析规则和场景关键词
+func (pr *LogProjectRule) Validate() error {
+	if err := pr.Rule.Validate(); err != nil {
+		return err
+	}
+	for _, m := range pr.Modules {
+		for _, scene := range m.Scenes {
+			for _, k := range scene.Keywords {
+				if k.Keyword == "" {
+					return fmt.Errorf("场景[%s]的关键词不能为空", scene.Name)
+				}
+				switch k.Mode {
+				case "", KeywordModeWord:
+				case KeywordModeRegular:
+					if _, err := regexp.Compile(k.Keyword); err != nil {
+						return fmt.Errorf("场景[%s]关键词[%s]正则错误: %w", scene.Name, k.Keyword, err)
+					}
+				default:
+					return fmt.Errorf("场景[%s]关键词[%s]的匹配模式不支持: %s", scene.Name, k.Keyword, k.Mode)
+				}
+				if k.MaxGap < 0 {
+					return fmt.Errorf("场景[%s]关键词[%s]的最大间隔不能为负数", scene.Name, k.Keyword)
+				}
+				if _, err := regexp.Compile(k.Correlation); err != nil {
+					return fmt.Errorf("场景[%s]关键词[%s]关联键正则错误: %w", scene.Name, k.Keyword, err)
+				}
+			}
+			if scene.Timeout < 0 {
+				return fmt.Errorf("场景[%s]的超时时间不能为负数", scene.Name)
+			}
+			if _, err := regexp.Compile(scene.Correlation); err != nil {
+				return fmt.Errorf("场景[%s]关联键正则错误: %w", scene.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RuleFieldNames 规则支持的字段名称（named模式下的分组名）
+var RuleFieldNames = []string{"timestamp", "process", "thread", "level", "module", "class", "class_line", "tag", "message"}
+
+// FieldPatterns 返回逐字段正则表达式（字段名 -> 正则）
+func (r *LogParseRule) FieldPatterns() map[string]string {
+	return map[string]string{
+		"timestamp":  r.Timestamp,
+		"process":    r.Process,
+		"thread":     r.Thread,
+		"level":      r.Level,
+		"module":     r.Module,
+		"class":      r.Class,
+		"class_line": r.ClassLine,
+		"tag":        r.Tag,
+		"message":    r.Message,
+	}
+}
+
+// RuleCompiler 按解析时的编译步骤（正则、预设格式、时间格式、时区、编码、帧协议等）校验规则，
+// 由解析器所在的包注册，Validate 通过它校验，避免与解析器各自编译一遍
+var RuleCompiler func(rule *LogParseRule) error
+
+// Validate 校验规则：编译规则中的正则和格式，并校验其余配置项
+func (r *LogParseRule) Validate() error {
+	if RuleCompiler == nil {
+		return r.ValidateSettings()
+	}
+	return RuleCompiler(r)
+}
+
+// ValidateSettings 校验规则中不需要编译的配置项（字段映射、级别映射、年份来源、文件匹配、字段名等），
+// 编译规则前调用
+func (r *LogParseRule) ValidateSettings() error {
+	if r.Type == RuleTypeJSON || r.Type == RuleTypeLogfmt {
+		if err := r.validateFields(); err != nil {
+			return err
+		}
+	}
+	for raw, level := range r.Levels {
+		if LevelSeverity(level) == 0 {
+			return fmt.Errorf("级别%s映射到了非标准级别: %s", raw, level)
+		}
+	}
+	if r.YearSource != "" && !contains(YearSources, r.YearSource) {
+		return fmt.Errorf("不支持的年份来源: %s", r.YearSource)
+	}
+	if r.YearSource == YearSourceExplicit && r.Year <= 0 {
+		return fmt.Errorf("year_source为explicit时必须配置year")
+	}
+	for _, pattern := range append(append([]string{}, r.Include...), r.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("文件匹配规则错误 %s: %w", pattern, err)
+		}
+	}
+	if err := r.validateCustomFields(); err != nil {
+		return err
+	}
+	return r.validateDecoders()
+}
+
+// MatchMember 按include/exclude判断是否导入压缩包中的文件，不导入时返回原因，rule为nil时全部导入
+func (r *LogParseRule) MatchMember(name string) (bool, string) {
+	if r == nil {
+		return true, ""
+	}
+	for _, pattern := range r.Exclude {
+		if matchGlob(pattern, name) {
+			return false, "匹配排除规则 " + pattern
+		}
+	}
+	if len(r.Include) == 0 {
+		return true, ""
+	}
+	for _, pattern := range r.Include {
+		if matchGlob(pattern, name) {
+			return true, ""
+		}
+	}
+	return false, "不匹配包含规则"
+}
+
+// matchGlob 用glob匹配完整路径或文件名
+func matchGlob(pattern, name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if ok, _ := path.Match(pattern, name); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, path.Base(name))
+	return ok
+}
+
+// validateCustomFields 校验自定义字段的名称和类型
+func (r *LogParseRule) validateCustomFields() error {
+	names := make(map[string]bool, len(r.CustomFields))
+	for _, f := range r.CustomFields {
+		if f.Name == "" {
+			return fmt.Errorf("自定义字段必须配置name")
+		}
+		if names[f.Name] {
+			return fmt.Errorf("自定义字段重复: %s", f.Name)
+		}
+		names[f.Name] = true
+		if f.Pattern == "" {
+			return fmt.Errorf("自定义字段%s必须配置pattern", f.Name)
+		}
+		if f.Type != "" && !contains(FieldTypes, f.Type) {
+			return fmt.Errorf("自定义字段%s类型不支持: %s", f.Name, f.Type)
+		}
+	}
+	return nil
+}
+
+// validateDecoders 校验帧解码器的名称和字段名
+func (r *LogParseRule) validateDecoders() error {
+	names := make(map[string]bool, len(r.Decoders))
+	for _, d := range r.Decoders {
+		if d.Name == "" {
+			return fmt.Errorf("帧解码器必须配置name")
+		}
+		if names[d.Name] {
+			return fmt.Errorf("帧解码器重复: %s", d.Name)
+		}
+		names[d.Name] = true
+		if d.Pattern == "" {
+			return fmt.Errorf("帧解码器%s必须配置pattern", d.Name)
+		}
+		for _, f := range d.Protocol.Fields {
+			if contains(FrameAttrs, f.Name) {
+				return fmt.Errorf("帧解码器%s字段名与固定属性重名: %s", d.Name, f.Name)
+			}
+		}
+		for _, c := range d.Protocol.Commands {
+			for _, f := range c.Fields {
+				if contains(FrameAttrs, f.Name) {
+					return fmt.Errorf("帧解码器%s字段名与固定属性重名: %s", d.Name, f.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateFields 校验字段映射中的字段名
+func (r *LogParseRule) validateFields() error {
+	for name := range r.Fields {
+		if !contains(RuleFieldNames, name) {
+			return fmt.Errorf("未知的映射字段: %s", name)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 var AppConfig *Config
 var ProjectRules []LogProjectRule
 
@@ -104,13 +457,31 @@ func LoadProjectRules(path string) error {
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, &ProjectRules)
+	var rules []LogProjectRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	for _, pr := range rules {
+		if err := pr.Validate(); err != nil {
+			return fmt.Errorf("项目[%s]规则错误: %w", pr.ProjectName, err)
+		}
+	}
+	ProjectRules = rules
+	return nil
 }
 
 func GetRuleByProjectName(name string) *LogParseRule {
-	for _, pr := range ProjectRules {
-		if pr.ProjectName == name {
-			return &pr.Rule
+	if pr := GetProjectRule(name); pr != nil {
+		return &pr.Rule
+	}
+	return nil
+}
+
+// GetProjectRule 按项目名称获取项目配置（含场景关键词）
+func GetProjectRule(name string) *LogProjectRule {
+	for i := range ProjectRules {
+		if ProjectRules[i].ProjectName == name {
+			return &ProjectRules[i]
 		}
 	}
 	return nil
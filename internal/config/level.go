@@ -0,0 +1,91 @@
+package config
+
+import "strings"
+
+// LevelOrder 标准日志级别，按严重程度从低到高排列
+var LevelOrder = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// LevelUnknown 无法映射到标准级别时使用的颜色键
+const LevelUnknown = "UNKNOWN"
+
+// DefaultLevelAliases 常见日志库的级别写法到标准级别的映射（键为大写）
+var DefaultLevelAliases = map[string]string{
+	"V":           "TRACE",
+	"VERBOSE":     "TRACE",
+	"FINEST":      "TRACE",
+	"FINER":       "TRACE",
+	"D":           "DEBUG",
+	"DBG":         "DEBUG",
+	"FINE":        "DEBUG",
+	"I":           "INFO",
+	"INF":         "INFO",
+	"INFORMATION": "INFO",
+	"NOTICE":      "INFO",
+	"W":           "WARN",
+	"WRN":         "WARN",
+	"WARNING":     "WARN",
+	"E":           "ERROR",
+	"ERR":         "ERROR",
+	"SEVERE":      "ERROR",
+	"F":           "FATAL",
+	"A":           "FATAL",
+	"ASSERT":      "FATAL",
+	"CRIT":        "FATAL",
+	"CRITICAL":    "FATAL",
+	"ALERT":       "FATAL",
+	"EMERG":       "FATAL",
+	"PANIC":       "FATAL",
+}
+
+// LevelSeverity 标准级别的严重程度（1-6），非标准级别返回0
+func LevelSeverity(level string) int {
+	for i, l := range LevelOrder {
+		if strings.EqualFold(l, level) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// LevelAliases 合并默认映射与项目映射（项目优先），返回以大写原始值为键的映射
+func LevelAliases(project map[string]string) map[string]string {
+	aliases := make(map[string]string, len(DefaultLevelAliases)+len(project))
+	for k, v := range DefaultLevelAliases {
+		aliases[k] = v
+	}
+	for k, v := range project {
+		aliases[strings.ToUpper(k)] = strings.ToUpper(v)
+	}
+	return aliases
+}
+
+// NormalizeLevel 按 LevelAliases 返回的映射将原始级别转换为标准级别，无法映射时返回大写的原始值
+func NormalizeLevel(raw string, aliases map[string]string) string {
+	key := strings.ToUpper(strings.TrimSpace(raw))
+	if level, ok := aliases[key]; ok {
+		return level
+	}
+	return key
+}
+
+// LevelColor 返回级别的颜色，未配置时使用 UNKNOWN 的颜色
+func (c *Config) LevelColor(level string) string {
+	if c == nil {
+		return ""
+	}
+	if color, ok := c.levelColor(level); ok {
+		return color
+	}
+	color, _ := c.levelColor(LevelUnknown)
+	return color
+}
+
+// levelColor viper 读取的键为小写，按不区分大小写查找
+func (c *Config) levelColor(level string) (string, bool) {
+	for k, v := range c.LogLevels {
+		if strings.EqualFold(k, level) {
+			return v, true
+		}
+	}
+	return "", false
+}
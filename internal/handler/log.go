@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
 	"log-tools-go/internal/config"
 	"log-tools-go/internal/model"
 	"log-tools-go/internal/service"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -28,19 +32,46 @@ func NewLogHandler(cfg *config.Config, storage *service.StorageService, parser *
 
 // LogQueryRequest 定义日志查询请求的JSON结构
 type LogQueryRequest struct {
-	FileID    string   `json:"file_id"`  // 文件ID
-	FileIDs   string   `json:"file_ids"` // 多个文件ID
-	Levels    []string `json:"levels"`   // 日志级别
-	Keywords  []string `json:"keywords"` // 关键词ss
-	StartTime *string  `json:"start_time"`
-	EndTime   *string  `json:"end_time"`
-	Source    string   `json:"source"`
-	Module    string   `json:"module"`
-	UseRegex  *bool    `json:"useRegex"` // 是否使用正则匹配
-	Limit     int      `json:"limit"`
-	Offset    int      `json:"offset"`
+	FileID    string            `json:"file_id"`   // 文件ID
+	FileIDs   string            `json:"file_ids"`  // 多个文件ID
+	Levels    []string          `json:"levels"`    // 日志级别
+	MinLevel  string            `json:"min_level"` // 最低级别，如WARN表示WARN及以上
+	Keywords  []string          `json:"keywords"`  // 关键词ss
+	StartTime *string           `json:"start_time"`
+	EndTime   *string           `json:"end_time"`
+	Source    string            `json:"source"`
+	Module    string            `json:"module"`
+	UseRegex  *bool             `json:"useRegex"` // 是否使用正则匹配
+	Attrs     map[string]string `json:"attrs"`    // 扩展属性过滤[键 -> 值]
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+
+	AttrRanges map[string]model.AttrRange `json:"attr_ranges"` // 扩展属性数值范围过滤[键 -> 范围]
+	SortBy     string                     `json:"sort_by"`     // 排序字段，如 severity、attr.latency
+	SortDesc   bool                       `json:"sort_desc"`   // 是否降序
+
+	Scenes        []string `json:"scenes"`         // 场景名称，命中任一场景的日志
+	SceneKeywords []string `json:"scene_keywords"` // 场景关键词，命中任一关键词的日志
 }
 
+// 聚合查询默认和最多返回的分组数
+const (
+	defaultAggregateLimit = 100
+	maxAggregateLimit     = 1000
+)
+
+// 全文检索默认和最多返回的条数
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// 场景顺序检查默认和最多返回的流程明细数
+const (
+	defaultSequenceLimit = 1000
+	maxSequenceLimit     = 10000
+)
+
 func (h *LogHandler) GetLogs(c *gin.Context) {
 	var req LogQueryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -125,15 +156,143 @@ func (h *LogHandler) GetLogStats(c *gin.Context) {
 	})
 }
 
+// AggregateLogs 按字段分组统计日志条数，参数：group_by 分组字段（如 module、attr.error_code），
+// metric 可选的数值统计字段（如 attr.latency），过滤参数与 /logs/stats 相同
+func (h *LogHandler) AggregateLogs(c *gin.Context) {
+	fileID := c.Query("file_id")
+	groupBy := c.Query("group_by")
+	if fileID == "" || groupBy == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "文件ID和分组字段不能为空",
+		})
+		return
+	}
+	filter := h.buildFilter(c)
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAggregateLimit
+	} else if limit > maxAggregateLimit {
+		limit = maxAggregateLimit
+	}
+	buckets, err := h.storage.AggregateLogs(fileID, filter, groupBy, c.Query("metric"), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "聚合统计失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    buckets,
+	})
+}
+
+// GetSceneTimeline 按项目场景中的关键词生成时间线，参数：file_ids 文件ID（逗号分隔），scene 场景名称，
+// project 可选的项目名称（默认取文件解析时使用的项目）
+func (h *LogHandler) GetSceneTimeline(c *gin.Context) {
+	fileIDs := c.Query("file_ids")
+	if fileIDs == "" {
+		fileIDs = c.Query("file_id")
+	}
+	scene := c.Query("scene")
+	if fileIDs == "" || scene == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "文件ID和场景不能为空",
+		})
+		return
+	}
+	timeline, err := h.storage.SceneTimeline(fileIDs, c.Query("project"), scene)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "文件不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "生成场景时间线失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    timeline,
+	})
+}
+
+// GetSceneSequences 按场景的顺序规则检查单个文件，返回完成的流程及耗时、中断的流程（缺步、超时、乱序）
+// 参数：file_id 文件ID，scene 场景名称，project 可选的项目名称，status 只返回该结果的流程明细，limit 明细条数
+func (h *LogHandler) GetSceneSequences(c *gin.Context) {
+	fileID := c.Query("file_id")
+	scene := c.Query("scene")
+	if fileID == "" || scene == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "文件ID和场景不能为空",
+		})
+		return
+	}
+	limit := defaultSequenceLimit
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = min(l, maxSequenceLimit)
+	}
+	report, err := h.storage.SceneSequences(fileID, c.Query("project"), scene, c.Query("status"), limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "文件不存在: " + fileID,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "检查场景顺序失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// GetLogLevels 按严重程度列出标准级别及颜色，指定project时附带该项目中映射到各级别的原始写法
 func (h *LogHandler) GetLogLevels(c *gin.Context) {
-	levels := make([]map[string]interface{}, 0, len(h.config.LogLevels))
-	for level := range h.config.LogLevels {
-		// 将日志级别转换为大写
-		lev := strings.ToUpper(level)
-		color := h.config.LogLevels[level]
+	var aliases map[string]string
+	if project := c.Query("project"); project != "" {
+		rule := config.GetRuleByProjectName(project)
+		if rule == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   "项目不存在: " + project,
+			})
+			return
+		}
+		aliases = config.LevelAliases(rule.Levels)
+	} else {
+		aliases = config.LevelAliases(nil)
+	}
+	names := append(append([]string{}, config.LevelOrder...), config.LevelUnknown)
+	levels := make([]map[string]interface{}, 0, len(names))
+	for _, level := range names {
+		raws := make([]string, 0)
+		for raw, mapped := range aliases {
+			if mapped == level {
+				raws = append(raws, raw)
+			}
+		}
+		sort.Strings(raws)
 		levels = append(levels, map[string]interface{}{
-			"level": lev,
-			"color": color,
+			"level":    level,
+			"color":    h.config.LevelColor(level),
+			"severity": config.LevelSeverity(level),
+			"aliases":  raws,
 		})
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -149,6 +308,7 @@ func (h *LogHandler) buildFilter(c *gin.Context) model.LogFilter {
 	if levels := c.Query("levels"); levels != "" {
 		filter.Levels = strings.Split(levels, ",")
 	}
+	filter.MinLevel = c.Query("min_level")
 
 	// 解析关键词
 	if keywords := c.Query("keywords"); keywords != "" {
@@ -178,6 +338,48 @@ func (h *LogHandler) buildFilter(c *gin.Context) model.LogFilter {
 		filter.Module = module
 	}
 
+	// 解析场景、场景关键词（逗号分隔）
+	if scenes := c.Query("scenes"); scenes != "" {
+		filter.Scenes = strings.Split(scenes, ",")
+	}
+	if keywords := c.Query("scene_keywords"); keywords != "" {
+		filter.SceneKeywords = strings.Split(keywords, ",")
+	}
+
+	// 解析扩展属性过滤，参数形如 attr.http.status=500、attr_min.latency=100、attr_max.latency=500
+	for key, values := range c.Request.URL.Query() {
+		if len(values) == 0 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(key, "attr."):
+			if filter.Attrs == nil {
+				filter.Attrs = make(map[string]string)
+			}
+			filter.Attrs[strings.TrimPrefix(key, "attr.")] = values[0]
+		case strings.HasPrefix(key, "attr_min."), strings.HasPrefix(key, "attr_max."):
+			value, err := strconv.ParseFloat(values[0], 64)
+			if err != nil {
+				continue
+			}
+			if filter.AttrRanges == nil {
+				filter.AttrRanges = make(map[string]model.AttrRange)
+			}
+			name := key[len("attr_min."):]
+			r := filter.AttrRanges[name]
+			if strings.HasPrefix(key, "attr_min.") {
+				r.Min = &value
+			} else {
+				r.Max = &value
+			}
+			filter.AttrRanges[name] = r
+		}
+	}
+
+	// 解析排序
+	filter.SortBy = c.Query("sort_by")
+	filter.SortDesc = c.Query("sort_desc") == "true"
+
 	// 解析分页参数
 	if limitStr := c.Query("limit"); limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil {
@@ -198,12 +400,21 @@ func (h *LogHandler) buildFilter(c *gin.Context) model.LogFilter {
 func (h *LogHandler) buildFilterFromRequest(req LogQueryRequest) model.LogFilter {
 	filter := model.LogFilter{
 		Levels:   req.Levels,
+		MinLevel: req.MinLevel,
 		Keywords: req.Keywords,
 		Source:   req.Source,
 		Module:   req.Module,
+		Attrs:    req.Attrs,
 		UseRegex: false,
 		Limit:    req.Limit,
 		Offset:   req.Offset,
+
+		AttrRanges: req.AttrRanges,
+		SortBy:     req.SortBy,
+		SortDesc:   req.SortDesc,
+
+		Scenes:        req.Scenes,
+		SceneKeywords: req.SceneKeywords,
 	}
 	if req.UseRegex != nil {
 		filter.UseRegex = *req.UseRegex
@@ -225,35 +436,59 @@ func (h *LogHandler) buildFilterFromRequest(req LogQueryRequest) model.LogFilter
 	return filter
 }
 
+// SearchLogs 全文检索日志的消息和原始内容，返回摘要、高亮和命中位置
+// 参数：file_id 文件ID（逗号分隔支持多个），q FTS5检索表达式（短语、前缀、AND/OR/NOT，不足3个字符的关键词逐行匹配），
+// order 排序方式[rank,time,time_desc]，limit 返回条数，offset 跳过条数
 func (h *LogHandler) SearchLogs(c *gin.Context) {
 	fileID := c.Query("file_id")
-	query := c.Query("q")
+	query := model.SearchQuery{Query: c.Query("q"), Order: c.Query("order"), Limit: defaultSearchLimit}
 
-	if fileID == "" || query == "" {
-		c.JSON(http.StatusBadRequest, model.LogResponse{
-			Success: false,
-			Error:   "文件ID和搜索关键词不能为空",
+	if fileID == "" || strings.TrimSpace(query.Query) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "文件ID和搜索关键词不能为空",
+		})
+		return
+	}
+	if query.Order != "" && !slices.Contains(model.SearchOrders, query.Order) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "不支持的排序方式: " + query.Order,
 		})
 		return
 	}
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		query.Limit = min(l, maxSearchLimit)
+	}
+	if o, err := strconv.Atoi(c.Query("offset")); err == nil && o > 0 {
+		query.Offset = o
+	}
 
-	// 从数据库搜索日志
-	searchResults, err := h.storage.SearchLogs(fileID, query, 100)
+	hits, err := h.storage.SearchLogs(fileID, query)
+	if errors.Is(err, model.ErrSearchQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.LogResponse{
-			Success: false,
-			Error:   "搜索失败: " + err.Error(),
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "搜索失败: " + err.Error(),
 		})
 		return
 	}
 
 	// 获取统计信息
-	stats := h.parser.GetLogStats(searchResults)
-
-	c.JSON(http.StatusOK, model.LogResponse{
-		Success: true,
-		Data:    searchResults,
-		Stats:   stats,
+	entries := make([]model.LogEntry, len(hits))
+	for i := range hits {
+		entries[i] = hits[i].LogEntry
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    hits,
+		"stats":   h.parser.GetLogStats(entries),
 	})
 }
 
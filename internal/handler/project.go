@@ -5,6 +5,7 @@ import (
 	"log-tools-go/internal/config"
 	"log-tools-go/internal/service"
 	"net/http"
+	"strings"
 )
 
 type ProjectHandler struct {
@@ -42,6 +43,12 @@ func (h *ProjectHandler) SaveProjects(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求参数错误: " + err.Error()})
 		return
 	}
+	for _, p := range projects {
+		if err := p.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "项目[" + p.ProjectName + "]规则错误: " + err.Error()})
+			return
+		}
+	}
 
 	if err := h.project.SaveProjects(projects); err != nil {
 		c.JSON(500, gin.H{"success": false, "message": "保存项目配置失败: " + err.Error()})
@@ -50,3 +57,35 @@ func (h *ProjectHandler) SaveProjects(c *gin.Context) {
 
 	c.JSON(200, gin.H{"success": true, "message": "项目配置保存成功"})
 }
+
+// DetectProjectRequest 自动识别项目请求
+type DetectProjectRequest struct {
+	Text string `json:"text" binding:"required"` // 日志样本，多行
+}
+
+// DetectProject 按样本日志为所有项目规则评分，按评分从高到低返回
+func (h *ProjectHandler) DetectProject(c *gin.Context) {
+	var req DetectProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求参数错误: " + err.Error()})
+		return
+	}
+	lines := strings.Split(strings.ReplaceAll(req.Text, "\r\n", "\n"), "\n")
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": service.ScoreProjectRules(lines)})
+}
+
+// TestRuleRequest 规则试解析请求
+type TestRuleRequest struct {
+	Rule config.LogParseRule `json:"rule"`
+	Text string              `json:"text" binding:"required"` // 样本日志，多行
+}
+
+// TestRule 在样本日志上试解析规则，返回每行提取的字段、规则错误、字段覆盖率和时间解析错误，不保存配置
+func (h *ProjectHandler) TestRule(c *gin.Context) {
+	var req TestRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求参数错误: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": service.DryRunRule(h.config, &req.Rule, req.Text)})
+}
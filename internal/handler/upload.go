@@ -1,20 +1,30 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log-tools-go/internal/config"
 	"log-tools-go/internal/model"
 	"log-tools-go/internal/service"
-	"log-tools-go/pkg/xjob"
+	"log-tools-go/pkg/xcharset"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	maxParallelFiles    = 4      // 同时解析的文件数上限
+	projectAuto         = "auto" // 自动识别项目
+	minDetectConfidence = 0.2    // 自动识别项目的最低评分，低于该值视为无法识别
+	maxRawLines         = 1000   // 单次读取原始行的最大行数
+)
+
 type UploadHandler struct {
 	config  *config.Config
 	storage *service.StorageService
@@ -81,13 +91,50 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
+	// 解析所有文件，未指定项目（或指定为auto）时按每个文件的样本行自动识别
+	projectName := c.PostForm("project_name")
+	autoDetect := projectName == "" || projectName == projectAuto
+	// 上传时指定的编码优先于项目规则中的编码
+	encoding, err := xcharset.Normalize(c.PostForm("encoding"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.UploadResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	var parser *service.LogParser
+	var rule *config.LogParseRule
+	if !autoDetect {
+		project := config.GetProjectRule(projectName)
+		if project == nil {
+			c.JSON(http.StatusBadRequest, model.UploadResponse{
+				Success: false,
+				Error:   "项目不存在",
+			})
+			return
+		}
+		rule = &project.Rule
+		parser, err = h.newProjectParser(project, encoding)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.UploadResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
 	// 处理不同类型的文件
 	var processedFiles []string
-	ext := strings.ToLower(filepath.Ext(header.Filename))
+	// 压缩包中每个条目的处理结果，memberIndex 为待解析文件对应的条目下标
+	var members []model.ArchiveMember
+	var memberIndex []int
 
-	if ext == ".zip" || ext == ".rar" || ext == ".7z" {
-		// 解压压缩文件 (zip/rar/7z)
-		extractedFiles, err := h.storage.ExtractZipFile(savedPath)
+	if service.IsArchive(savedPath) {
+		// 解压压缩包 (zip/rar/7z/tar及压缩的tar)，单文件压缩的日志在解析时流式解压
+		// 指定项目时按项目的include/exclude筛选，自动识别时识别出项目后再筛选
+		members, err = h.storage.ExtractArchive(savedPath, rule)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, model.UploadResponse{
 				Success: false,
@@ -95,65 +142,156 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 			})
 			return
 		}
-		processedFiles = extractedFiles
+		for i, member := range members {
+			if member.Status == model.ArchiveMemberExtracted && member.Path != "" {
+				processedFiles = append(processedFiles, member.Path)
+				memberIndex = append(memberIndex, i)
+			}
+		}
 	} else {
 		// 直接处理单个文件
 		processedFiles = []string{savedPath}
 	}
 
-	// 解析所有文件
-	projectName := c.PostForm("project_name")
-	rule := config.GetRuleByProjectName(projectName)
-	if rule == nil {
-		c.JSON(http.StatusBadRequest, model.UploadResponse{
-			Success: false,
-			Error:   "项目不存在",
-		})
-		return
+	// 多个文件（如压缩包内的文件）并发解析，结果保持原有顺序
+	results := make([]*model.UploadedFile, len(processedFiles))
+	// 失败原因记录到对应的压缩包条目，各协程只写自己的条目
+	reasons := make([]string, len(processedFiles))
+	// 上传单个文件且无法识别项目时，返回各项目规则的评分
+	var scores []model.RuleScore
+	mark := func(i int, status, reason string) {
+		reasons[i] = reason
+		if memberIndex != nil {
+			members[memberIndex[i]].Status = status
+			members[memberIndex[i]].Reason = reason
+		}
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxParallelFiles)
+	for i, filePath := range processedFiles {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, filePath string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result := &model.UploadedFile{ProjectName: projectName}
+			fileParser := parser
+			if autoDetect {
+				project, fileScores, err := service.DetectFileProjectRule(filePath, encoding)
+				if err != nil {
+					fmt.Printf("文件 %s 识别项目失败: %v\n", filePath, err)
+					mark(i, model.ArchiveMemberFailed, "识别项目失败: "+err.Error())
+					return
+				}
+				if project == nil || fileScores[0].Score < minDetectConfidence {
+					reason := undetectedReason(fileScores)
+					fmt.Printf("文件 %s %s\n", filePath, reason)
+					if memberIndex == nil {
+						scores = fileScores
+					}
+					mark(i, model.ArchiveMemberFailed, reason)
+					return
+				}
+				score := fileScores[0]
+				fileParser, err = h.newProjectParser(project, encoding)
+				if err != nil {
+					fmt.Printf("文件 %s 识别的项目规则错误: %v\n", filePath, err)
+					mark(i, model.ArchiveMemberFailed, "项目规则错误: "+err.Error())
+					return
+				}
+				if memberIndex != nil {
+					if ok, reason := project.Rule.MatchMember(members[memberIndex[i]].Name); !ok {
+						mark(i, model.ArchiveMemberSkipped, reason)
+						return
+					}
+				}
+				result.ProjectName = project.ProjectName
+				result.Confidence = score.Score
+			}
+			beginTime := time.Now()
+			logFile, err := h.storage.IngestLogFile(fileParser, filePath)
+			useTime := time.Since(beginTime)
+			fmt.Printf("解析文件 %s 用时 %s\n", filePath, useTime)
+			if err != nil {
+				// 记录错误但继续处理其他文件
+				fmt.Printf("解析文件 %s 失败: %v\n", filePath, err)
+				mark(i, model.ArchiveMemberFailed, "解析失败: "+err.Error())
+				return
+			}
+			result.FileID = logFile.ID
+			result.Name = logFile.Name
+			result.Encoding = logFile.Encoding
+			results[i] = result
+			if memberIndex != nil {
+				members[memberIndex[i]].Status = model.ArchiveMemberIngested
+				members[memberIndex[i]].FileID = logFile.ID
+			}
+		}(i, filePath)
 	}
-	parser := service.NewLogParserWithRule(h.config, rule)
+	wg.Wait()
 
-	var allLogFiles []*model.LogFile
-	for _, filePath := range processedFiles {
-		beginTime := time.Now()
-		logFile, err := parser.ParseLogFile(filePath)
-		useTime := time.Since(beginTime)
-		fmt.Printf("解析文件 %s 用时 %s\n", filePath, useTime)
-		if err != nil {
-			// 记录错误但继续处理其他文件
-			fmt.Printf("解析文件 %s 失败: %v\n", filePath, err)
-			continue
-		}
-		err = xjob.GetInstance().Submit(func() error {
-			return h.storage.SaveParsedLogs(logFile)
-		}, true)
-		if err != nil {
-			fmt.Printf("保存解析结果失败: %v\n", err)
+	var uploaded []model.UploadedFile
+	for _, result := range results {
+		if result != nil {
+			uploaded = append(uploaded, *result)
 		}
-		allLogFiles = append(allLogFiles, logFile)
 	}
 
-	if len(allLogFiles) == 0 {
+	if len(uploaded) == 0 {
+		errMsg := "没有成功解析任何日志文件"
+		// 单个文件直接返回失败原因，压缩包的失败原因记录在各条目中
+		if memberIndex == nil && len(reasons) == 1 && reasons[0] != "" {
+			errMsg = reasons[0]
+		}
 		c.JSON(http.StatusBadRequest, model.UploadResponse{
 			Success: false,
-			Error:   "没有成功解析任何日志文件",
+			Error:   errMsg,
+			Members: members,
+			Scores:  scores,
 		})
 		return
 	}
 
 	// 返回成功响应
-	fileIDs := make([]string, len(allLogFiles))
-	for i, logFile := range allLogFiles {
-		fileIDs[i] = logFile.ID
+	fileIDs := make([]string, len(uploaded))
+	for i, file := range uploaded {
+		fileIDs[i] = file.FileID
 	}
 
 	c.JSON(http.StatusOK, model.UploadResponse{
-		Success: true,
-		Message: fmt.Sprintf("成功上传并解析了 %d 个文件", len(allLogFiles)),
-		FileID:  strings.Join(fileIDs, ","),
+		Success:     true,
+		Message:     fmt.Sprintf("成功上传并解析了 %d 个文件", len(uploaded)),
+		FileID:      strings.Join(fileIDs, ","),
+		ProjectName: uploaded[0].ProjectName,
+		Confidence:  uploaded[0].Confidence,
+		Files:       uploaded,
+		Members:     members,
 	})
 }
 
+// undetectedReason 无法识别项目的原因，注明评分最高的项目
+func undetectedReason(scores []model.RuleScore) string {
+	if len(scores) == 0 {
+		return "无法识别项目: 没有配置项目规则"
+	}
+	return fmt.Sprintf("无法识别项目: 评分最高的项目[%s]为%.2f，低于%.2f", scores[0].ProjectName, scores[0].Score, minDetectConfidence)
+}
+
+// newProjectParser 创建按项目规则解析并标注场景关键词的解析器，encoding 不为空时优先于项目规则中的编码
+func (h *UploadHandler) newProjectParser(project *config.LogProjectRule, encoding string) (*service.LogParser, error) {
+	parser, err := service.NewLogParserWithRule(h.config, &project.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if parser, err = parser.WithProject(project); err != nil {
+		return nil, err
+	}
+	if encoding != "" {
+		return parser.WithEncoding(encoding)
+	}
+	return parser, nil
+}
+
 func (h *UploadHandler) GetUploadedFiles(c *gin.Context) {
 	files, err := h.storage.GetUploadedFiles()
 	if err != nil {
@@ -193,6 +331,68 @@ func (h *UploadHandler) DeleteFile(c *gin.Context) {
 	})
 }
 
+// GetFileDiagnostics 获取文件的解析诊断信息
+func (h *UploadHandler) GetFileDiagnostics(c *gin.Context) {
+	fileID := c.Param("id")
+	diagnostics, err := h.storage.GetLogFileDiagnostics(fileID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "文件不存在: " + fileID,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取解析诊断失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    diagnostics,
+	})
+}
+
+// GetRawLines 从保留的原始文件读取完整行内容（用于查看被截断的超长行），参数 line 起始行号，end_line 可选的结束行号
+func (h *UploadHandler) GetRawLines(c *gin.Context) {
+	start, err := strconv.Atoi(c.Query("line"))
+	if err != nil || start <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "行号无效"})
+		return
+	}
+	end := start
+	if endStr := c.Query("end_line"); endStr != "" {
+		if end, err = strconv.Atoi(endStr); err != nil || end < start {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "结束行号无效"})
+			return
+		}
+	}
+	if end-start+1 > maxRawLines {
+		end = start + maxRawLines - 1
+	}
+	lines, err := h.storage.ReadRawLines(c.Param("id"), start, end)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+			err = fmt.Errorf("文件不存在")
+		}
+		c.JSON(status, gin.H{"success": false, "error": "读取原始内容失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"line":     start,
+			"end_line": start + len(lines) - 1,
+			"lines":    lines,
+		},
+	})
+}
+
 // 批量删除文件
 func (h *UploadHandler) BatchDeleteFiles(c *gin.Context) {
 	var req struct {
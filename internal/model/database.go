@@ -2,10 +2,12 @@ package model
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"log-tools-go/internal/config"
 	_ "modernc.org/sqlite"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,7 +21,13 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 	if dbPath == "" {
 		dbPath = "./logs.db"
 	}
-	db, err := sql.Open("sqlite", dbPath)
+	// WAL模式下写入批次时不阻塞查询，busy_timeout避免并发访问时立即返回锁错误
+	// 配置的路径已带参数（如 logs.db?_pragma=...）时追加到已有参数之后
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", dbPath+sep+"_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 	if err != nil {
 		return nil, fmt.Errorf("打开数据库失败: %w", err)
 	}
@@ -51,6 +59,10 @@ func (d *Database) initTables() error {
 		size INTEGER NOT NULL,
 		upload_at DATETIME NOT NULL,
 		total_entries INTEGER DEFAULT 0,
+		encoding TEXT NOT NULL DEFAULT '',
+		raw_path TEXT NOT NULL DEFAULT '',
+		project TEXT NOT NULL DEFAULT '',
+		diagnostics TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 	`
@@ -63,6 +75,7 @@ func (d *Database) initTables() error {
 		log_time DATETIME NOT NULL,
 		save_time DATETIME NOT NULL,
 		level TEXT NOT NULL,
+		severity INTEGER NOT NULL DEFAULT 0,
 		module TEXT,
 		process TEXT,
 		thread TEXT,
@@ -73,12 +86,51 @@ func (d *Database) initTables() error {
 		content TEXT NOT NULL,
 		source TEXT NOT NULL,
 		line_number INTEGER NOT NULL,
+		end_line INTEGER NOT NULL DEFAULT 0,
 		color TEXT NOT NULL,
+		attrs TEXT,
+		time_source TEXT NOT NULL DEFAULT 'parsed',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (file_id) REFERENCES log_files(id) ON DELETE CASCADE
 	);
 	`
 
+	// 创建场景关键词标注表
+	createLogAnnotationsTable := `
+	CREATE TABLE IF NOT EXISTS log_annotations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entry_id TEXT NOT NULL,
+		file_id TEXT NOT NULL,
+		line_number INTEGER NOT NULL,
+		module TEXT NOT NULL,
+		scene TEXT NOT NULL,
+		keyword TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		color TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_log_annotations_entry_id ON log_annotations(entry_id);
+	CREATE INDEX IF NOT EXISTS idx_log_annotations_scene ON log_annotations(file_id, scene);
+	CREATE INDEX IF NOT EXISTS idx_log_annotations_keyword ON log_annotations(file_id, keyword);
+	`
+
+	// 创建全文索引：外部内容表，trigram分词支持中文及子串匹配，通过触发器与log_entries同步
+	// （log_entries 的 rowid 未绑定主键，VACUUM 会重新编号，执行 VACUUM 后需 rebuild 索引）
+	createLogEntriesFTS := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS log_entries_fts USING fts5(
+		message, content, content='log_entries', content_rowid='rowid', tokenize='trigram'
+	);
+	CREATE TRIGGER IF NOT EXISTS log_entries_fts_insert AFTER INSERT ON log_entries BEGIN
+		INSERT INTO log_entries_fts(rowid, message, content) VALUES (new.rowid, new.message, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS log_entries_fts_delete AFTER DELETE ON log_entries BEGIN
+		INSERT INTO log_entries_fts(log_entries_fts, rowid, message, content) VALUES ('delete', old.rowid, old.message, old.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS log_entries_fts_update AFTER UPDATE OF message, content ON log_entries BEGIN
+		INSERT INTO log_entries_fts(log_entries_fts, rowid, message, content) VALUES ('delete', old.rowid, old.message, old.content);
+		INSERT INTO log_entries_fts(rowid, message, content) VALUES (new.rowid, new.message, new.content);
+	END;
+	`
+
 	// 创建索引
 	createIndexes := `
 	CREATE INDEX IF NOT EXISTS idx_log_entries_file_id ON log_entries(file_id);
@@ -96,10 +148,173 @@ func (d *Database) initTables() error {
 		return fmt.Errorf("创建log_entries表失败: %w", err)
 	}
 
+	if _, err := d.db.Exec(createLogAnnotationsTable); err != nil {
+		return fmt.Errorf("创建log_annotations表失败: %w", err)
+	}
+
 	if _, err := d.db.Exec(createIndexes); err != nil {
 		return fmt.Errorf("创建索引失败: %w", err)
 	}
 
+	// 旧版本数据库创建全文索引后为已有条目建立索引
+	hasFTS, err := d.hasTable("log_entries_fts")
+	if err != nil {
+		return err
+	}
+	if _, err := d.db.Exec(createLogEntriesFTS); err != nil {
+		return fmt.Errorf("创建全文索引失败: %w", err)
+	}
+	if !hasFTS {
+		if _, err := d.db.Exec("INSERT INTO log_entries_fts(log_entries_fts) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("重建全文索引失败: %w", err)
+		}
+	}
+
+	// 旧版本数据库补充新增字段
+	if err := d.addColumnIfNotExists("log_entries", "end_line", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("log_entries", "attrs", "TEXT"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("log_entries", "time_source", "TEXT NOT NULL DEFAULT 'parsed'"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("log_files", "diagnostics", "TEXT"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("log_files", "encoding", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("log_files", "raw_path", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("log_files", "project", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	hasSeverity, err := d.hasColumn("log_entries", "severity")
+	if err != nil {
+		return err
+	}
+	if !hasSeverity {
+		if err := d.addColumnIfNotExists("log_entries", "severity", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		// 按标准级别回填旧数据的严重程度
+		if _, err := d.db.Exec(severityBackfill()); err != nil {
+			return fmt.Errorf("回填日志级别严重程度失败: %w", err)
+		}
+	}
+	if _, err := d.db.Exec("CREATE INDEX IF NOT EXISTS idx_log_entries_severity ON log_entries(severity)"); err != nil {
+		return fmt.Errorf("创建索引失败: %w", err)
+	}
+
+	return d.migrateUTCTimes()
+}
+
+// migrateUTCTimes 将旧版本按条目原时区保存的日志时间转换为UTC，无法解析的保持原样
+func (d *Database) migrateUTCTimes() error {
+	rows, err := d.db.Query("SELECT rowid, CAST(log_time AS TEXT) FROM log_entries WHERE log_time NOT LIKE '% +0000 UTC'")
+	if err != nil {
+		return fmt.Errorf("查询待转换的日志时间失败: %w", err)
+	}
+	converted := make(map[int64]time.Time)
+	for rows.Next() {
+		var rowid int64
+		var value string
+		if err := rows.Scan(&rowid, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描日志时间失败: %w", err)
+		}
+		if t, ok := parseStoredTime(value); ok {
+			converted[rowid] = t.UTC()
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("查询待转换的日志时间失败: %w", err)
+	}
+	if len(converted) == 0 {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("UPDATE log_entries SET log_time = ? WHERE rowid = ?")
+	if err != nil {
+		return fmt.Errorf("准备更新语句失败: %w", err)
+	}
+	defer stmt.Close()
+	for rowid, t := range converted {
+		if _, err := stmt.Exec(t, rowid); err != nil {
+			return fmt.Errorf("转换日志时间失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// severityBackfill 按级别名称计算严重程度的更新语句
+func severityBackfill() string {
+	stmt := "UPDATE log_entries SET severity = CASE UPPER(TRIM(level))"
+	for _, level := range config.LevelOrder {
+		stmt += fmt.Sprintf(" WHEN '%s' THEN %d", level, config.LevelSeverity(level))
+	}
+	// 旧数据中保存的是原始级别，常见写法（如E、W、warning）按默认映射回填
+	aliases := make([]string, 0, len(config.DefaultLevelAliases))
+	for alias := range config.DefaultLevelAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		severity := config.LevelSeverity(config.DefaultLevelAliases[alias])
+		stmt += fmt.Sprintf(" WHEN '%s' THEN %d", strings.ReplaceAll(alias, "'", "''"), severity)
+	}
+	return stmt + " ELSE 0 END"
+}
+
+// hasColumn 判断表中是否存在字段
+func (d *Database) hasColumn(table, column string) (bool, error) {
+	rows, err := d.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, fmt.Errorf("读取%s表结构失败: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, fmt.Errorf("读取%s表结构失败: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("读取%s表结构失败: %w", table, err)
+	}
+	return false, nil
+}
+
+// hasTable 判断表（含虚拟表）是否存在
+func (d *Database) hasTable(table string) (bool, error) {
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); err != nil {
+		return false, fmt.Errorf("读取表%s失败: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// addColumnIfNotExists 为已存在的表补充字段（兼容旧版本数据库）
+func (d *Database) addColumnIfNotExists(table, column, definition string) error {
+	exists, err := d.hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+	if _, err := d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("为%s表添加字段%s失败: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -111,12 +326,99 @@ func (d *Database) SaveLogFile(logFile *LogFile) error {
 	}
 	defer tx.Rollback()
 
+	if err := saveLogFileInfo(tx, logFile); err != nil {
+		return err
+	}
+	if err := insertLogEntries(tx, logFile.ID, logFile.Entries); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SaveLogFileInfo 保存日志文件信息并清空旧条目，流式写入条目前调用
+func (d *Database) SaveLogFileInfo(logFile *LogFile) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveLogFileInfo(tx, logFile); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertLogEntries 追加一批日志条目并累加文件的条目数，每批单独提交，提交后即可查询
+func (d *Database) InsertLogEntries(fileID string, entries []LogEntry) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertLogEntries(tx, fileID, entries); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE log_files SET total_entries = total_entries + ? WHERE id = ?", len(entries), fileID)
+	if err != nil {
+		return fmt.Errorf("更新日志条目数失败: %w", err)
+	}
+	return tx.Commit()
+}
+
+// UpdateLogFileDiagnostics 保存文件的解析诊断信息，流式解析完成后调用
+func (d *Database) UpdateLogFileDiagnostics(fileID string, diagnostics *ParseDiagnostics) error {
+	data, err := encodeDiagnostics(diagnostics)
+	if err != nil {
+		return err
+	}
+	if _, err := d.db.Exec("UPDATE log_files SET diagnostics = ? WHERE id = ?", data, fileID); err != nil {
+		return fmt.Errorf("保存解析诊断失败: %w", err)
+	}
+	return nil
+}
+
+// GetLogFileDiagnostics 获取文件的解析诊断信息，文件不存在时返回 sql.ErrNoRows
+func (d *Database) GetLogFileDiagnostics(fileID string) (*ParseDiagnostics, error) {
+	var data sql.NullString
+	err := d.db.QueryRow("SELECT diagnostics FROM log_files WHERE id = ?", fileID).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	if !data.Valid || data.String == "" {
+		return nil, nil
+	}
+	var diagnostics ParseDiagnostics
+	if err := json.Unmarshal([]byte(data.String), &diagnostics); err != nil {
+		return nil, fmt.Errorf("解析诊断数据失败: %w", err)
+	}
+	return &diagnostics, nil
+}
+
+func encodeDiagnostics(diagnostics *ParseDiagnostics) (interface{}, error) {
+	if diagnostics == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(diagnostics)
+	if err != nil {
+		return nil, fmt.Errorf("编码解析诊断失败: %w", err)
+	}
+	return string(data), nil
+}
+
+func saveLogFileInfo(tx *sql.Tx, logFile *LogFile) error {
 	// 插入或更新日志文件信息
 	stmt := `
-	INSERT OR REPLACE INTO log_files (id, name, size, upload_at, total_entries)
-	VALUES (?, ?, ?, ?, ?)`
+	INSERT OR REPLACE INTO log_files (id, name, size, upload_at, total_entries, encoding, raw_path, project, diagnostics)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err = tx.Exec(stmt, logFile.ID, logFile.Name, logFile.Size, logFile.UploadAt, logFile.Total)
+	diagnostics, err := encodeDiagnostics(logFile.Diagnostics)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(stmt, logFile.ID, logFile.Name, logFile.Size, logFile.UploadAt.UTC(), logFile.Total, logFile.Encoding, logFile.RawPath, logFile.Project, diagnostics)
 	if err != nil {
 		return fmt.Errorf("保存日志文件信息失败: %w", err)
 	}
@@ -126,40 +428,194 @@ func (d *Database) SaveLogFile(logFile *LogFile) error {
 	if err != nil {
 		return fmt.Errorf("删除旧日志条目失败: %w", err)
 	}
+	_, err = tx.Exec("DELETE FROM log_annotations WHERE file_id = ?", logFile.ID)
+	if err != nil {
+		return fmt.Errorf("删除旧日志标注失败: %w", err)
+	}
+	return nil
+}
 
-	// 批量插入日志条目
-	if len(logFile.Entries) > 0 {
-		entryStmt := `
-			INSERT INTO log_entries (id, file_id, log_time, save_time, module,level,process, thread,class,class_line,tag,message, content, source, line_number, color)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? ,?)`
+func insertLogEntries(tx *sql.Tx, fileID string, entries []LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO log_entries (id, file_id, log_time, save_time, module,level,severity,process, thread,class,class_line,tag,message, content, source, line_number, end_line, color, attrs, time_source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("准备插入语句失败: %w", err)
+	}
+	defer stmt.Close()
 
-		// 每批最多500条记录
-		batchSize := 500
-		for i := 0; i < len(logFile.Entries); i += batchSize {
-			end := i + batchSize
-			if end > len(logFile.Entries) {
-				end = len(logFile.Entries)
-			}
+	// 时间统一按UTC保存：驱动以 time.Time.String() 的文本写入，不同时区的文本无法直接比较和排序
+	for _, entry := range entries {
+		attrs, err := encodeAttrs(entry.Attrs)
+		if err != nil {
+			return fmt.Errorf("编码扩展属性失败: %w", err)
+		}
+		_, err = stmt.Exec(
+			entry.ID, fileID, entry.LogTime.UTC(), entry.SaveTime.UTC(), entry.Module, entry.Level, entry.Severity, entry.Process, entry.Thread, entry.Class, entry.ClassLine, entry.Tag,
+			entry.Message, entry.Content, entry.Source, entry.Line, entry.EndLine, entry.Color, attrs, entry.TimeSource)
+		if err != nil {
+			return fmt.Errorf("插入日志条目失败: %w", err)
+		}
+	}
+	return insertLogAnnotations(tx, fileID, entries)
+}
 
-			// 执行这一批的插入
-			for _, entry := range logFile.Entries[i:end] {
-				_, err = tx.Exec(entryStmt,
-					entry.ID, logFile.ID, entry.LogTime, entry.SaveTime, entry.Module, entry.Level, entry.Process, entry.Thread, entry.Class, entry.ClassLine, entry.Tag,
-					entry.Message, entry.Content, entry.Source, entry.Line, entry.Color)
+// insertLogAnnotations 保存条目命中的场景关键词
+func insertLogAnnotations(tx *sql.Tx, fileID string, entries []LogEntry) error {
+	var stmt *sql.Stmt
+	for _, entry := range entries {
+		for _, a := range entry.Annotations {
+			if stmt == nil {
+				var err error
+				stmt, err = tx.Prepare(`
+					INSERT INTO log_annotations (entry_id, file_id, line_number, module, scene, keyword, description, color)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
 				if err != nil {
-					return fmt.Errorf("插入日志条目失败: %w", err)
+					return fmt.Errorf("准备插入语句失败: %w", err)
 				}
+				defer stmt.Close()
+			}
+			if _, err := stmt.Exec(entry.ID, fileID, entry.Line, a.Module, a.Scene, a.Keyword, a.Desc, a.Color); err != nil {
+				return fmt.Errorf("插入日志标注失败: %w", err)
 			}
 		}
 	}
+	return nil
+}
 
-	return tx.Commit()
+// splitFileIDs 将逗号分隔的文件ID转换为IN子句的占位符和参数
+func splitFileIDs(fileID string) (string, []interface{}) {
+	ids := strings.Split(fileID, ",")
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, strings.TrimSpace(id))
+	}
+	return strings.Repeat("?,", len(ids)-1) + "?", args
+}
+
+// GetSceneKeywordHits 统计场景中各关键词在文件（逗号分隔支持多个）中的命中条数、首次和最后出现时间及首次出现的位置
+func (d *Database) GetSceneKeywordHits(fileID, scene string) (map[string]KeywordHits, error) {
+	placeholders, args := splitFileIDs(fileID)
+	from := ` FROM log_annotations a JOIN log_entries e ON e.id = a.entry_id
+		WHERE a.file_id IN (` + placeholders + `) AND a.scene = ?`
+	args = append(args, scene)
+	rows, err := d.db.Query(`SELECT a.keyword, COUNT(*), MIN(e.log_time), MAX(e.log_time)`+from+` GROUP BY a.keyword`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("统计场景关键词失败: %w", err)
+	}
+	hits := make(map[string]KeywordHits)
+	for rows.Next() {
+		var h KeywordHits
+		var first, last sql.NullString
+		if err := rows.Scan(&h.Keyword, &h.Count, &first, &last); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("扫描场景关键词统计失败: %w", err)
+		}
+		h.FirstAt, _ = parseStoredTime(first.String)
+		h.LastAt, _ = parseStoredTime(last.String)
+		hits[h.Keyword] = h
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("统计场景关键词失败: %w", err)
+	}
+
+	// 首次出现的位置
+	for keyword, h := range hits {
+		err := d.db.QueryRow(`SELECT e.file_id, e.line_number, e.message`+from+` AND a.keyword = ?
+			ORDER BY e.log_time ASC, e.line_number ASC LIMIT 1`, append(args, keyword)...).
+			Scan(&h.FileID, &h.Line, &h.Message)
+		if err != nil {
+			return nil, fmt.Errorf("查询关键词首次出现位置失败: %w", err)
+		}
+		hits[keyword] = h
+	}
+	return hits, nil
+}
+
+// GetSceneHits 按时间和行号顺序获取文件中场景关键词的所有命中
+func (d *Database) GetSceneHits(fileID, scene string) ([]SceneHit, error) {
+	rows, err := d.db.Query(`
+		SELECT a.keyword, e.log_time, e.line_number, e.content
+		FROM log_annotations a JOIN log_entries e ON e.id = a.entry_id
+		WHERE a.file_id = ? AND a.scene = ?
+		ORDER BY e.log_time ASC, e.line_number ASC, a.id ASC`, fileID, scene)
+	if err != nil {
+		return nil, fmt.Errorf("查询场景关键词失败: %w", err)
+	}
+	defer rows.Close()
+	var hits []SceneHit
+	for rows.Next() {
+		var h SceneHit
+		if err := rows.Scan(&h.Keyword, &h.Time, &h.Line, &h.Content); err != nil {
+			return nil, fmt.Errorf("扫描场景关键词失败: %w", err)
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询场景关键词失败: %w", err)
+	}
+	return hits, nil
+}
+
+// attachAnnotations 为查询出的条目补充命中的场景关键词
+func (d *Database) attachAnnotations(entries []LogEntry) error {
+	index := make(map[string]int, len(entries))
+	for i := range entries {
+		index[entries[i].ID] = i
+	}
+	// 分批查询，避免超过SQLite的参数个数限制
+	const batch = 500
+	for start := 0; start < len(entries); start += batch {
+		end := min(start+batch, len(entries))
+		args := make([]interface{}, 0, end-start)
+		for _, entry := range entries[start:end] {
+			args = append(args, entry.ID)
+		}
+		rows, err := d.db.Query(`
+			SELECT entry_id, file_id, line_number, module, scene, keyword, description, color
+			FROM log_annotations WHERE entry_id IN (`+strings.Repeat("?,", len(args)-1)+`?) ORDER BY id`, args...)
+		if err != nil {
+			return fmt.Errorf("查询日志标注失败: %w", err)
+		}
+		for rows.Next() {
+			var a LogAnnotation
+			if err := rows.Scan(&a.EntryID, &a.FileID, &a.Line, &a.Module, &a.Scene, &a.Keyword, &a.Desc, &a.Color); err != nil {
+				rows.Close()
+				return fmt.Errorf("扫描日志标注失败: %w", err)
+			}
+			if i, ok := index[a.EntryID]; ok {
+				entries[i].Annotations = append(entries[i].Annotations, a)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("查询日志标注失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetLogFile 获取单个日志文件信息（不含条目），文件不存在时返回 sql.ErrNoRows
+func (d *Database) GetLogFile(fileID string) (*LogFile, error) {
+	var file LogFile
+	err := d.db.QueryRow(`
+		SELECT id, name, size, upload_at, total_entries, encoding, raw_path, project
+		FROM log_files WHERE id = ?`, fileID).
+		Scan(&file.ID, &file.Name, &file.Size, &file.UploadAt, &file.Total, &file.Encoding, &file.RawPath, &file.Project)
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
 }
 
 // 获取日志文件列表
 func (d *Database) GetLogFiles() ([]LogFile, error) {
 	rows, err := d.db.Query(`
-		SELECT id, name, size, upload_at, total_entries
+		SELECT id, name, size, upload_at, total_entries, encoding, raw_path, project
 		FROM log_files
 		ORDER BY upload_at DESC`)
 	if err != nil {
@@ -169,7 +625,7 @@ func (d *Database) GetLogFiles() ([]LogFile, error) {
 	var files []LogFile
 	for rows.Next() {
 		var file LogFile
-		err := rows.Scan(&file.ID, &file.Name, &file.Size, &file.UploadAt, &file.Total)
+		err := rows.Scan(&file.ID, &file.Name, &file.Size, &file.UploadAt, &file.Total, &file.Encoding, &file.RawPath, &file.Project)
 		if err != nil {
 			return nil, fmt.Errorf("扫描日志文件数据失败: %w", err)
 		}
@@ -178,70 +634,216 @@ func (d *Database) GetLogFiles() ([]LogFile, error) {
 	return files, nil
 }
 
-// 获取日志条目
-func (d *Database) GetLogEntries(fileID string, filter LogFilter) ([]LogEntry, error) {
+// buildWhere 根据文件ID（逗号分隔支持多个）和过滤条件构建WHERE子句
+func buildWhere(fileID string, filter LogFilter) (string, []interface{}) {
 	// 检查fileID是否包含多个ID（逗号分隔）
 	fileIDs := strings.Split(fileID, ",")
-
-	query := `SELECT id, log_time, save_time, module, level, process, thread, class, class_line, tag, message, content, source, line_number, color FROM log_entries`
 	args := make([]interface{}, 0)
 
-	if len(fileIDs) > 0 {
-		// 构建IN查询
-		placeholders := strings.Repeat("?,", len(fileIDs)-1) + "?"
-		query += " WHERE file_id IN (" + placeholders + ")"
-		for _, id := range fileIDs {
-			args = append(args, strings.TrimSpace(id))
-		}
-	} else {
-		query += " WHERE file_id = ?"
-		args = append(args, fileID)
+	// 构建IN查询
+	placeholders := strings.Repeat("?,", len(fileIDs)-1) + "?"
+	where := " WHERE file_id IN (" + placeholders + ")"
+	for _, id := range fileIDs {
+		args = append(args, strings.TrimSpace(id))
 	}
 
 	// 添加过滤条件
 	if len(filter.Levels) > 0 {
 		placeholders := strings.Repeat("?,", len(filter.Levels)-1) + "?"
-		query += " AND level IN (" + placeholders + ")"
+		where += " AND level IN (" + placeholders + ")"
 		for _, level := range filter.Levels {
 			args = append(args, level)
 		}
 	}
 
-	if len(filter.Keywords) > 0 {
+	if severity := config.LevelSeverity(filter.MinLevel); severity > 0 {
+		where += " AND severity >= ?"
+		args = append(args, severity)
+	}
+
+	// 3个字符以上的关键词走全文索引，较短的关键词trigram无法索引，仍逐行匹配
+	for _, keyword := range filter.Keywords {
 		if filter.UseRegex {
-			for _, keyword := range filter.Keywords {
-				query += (" AND content REGEXP '" + keyword + "'")
-			}
+			where += " AND content REGEXP ?"
+			args = append(args, keyword)
+		} else if ftsUsable(keyword) {
+			where += " AND rowid IN (SELECT rowid FROM log_entries_fts WHERE log_entries_fts MATCH ?)"
+			args = append(args, ftsPhrase(keyword))
 		} else {
-			for _, keyword := range filter.Keywords {
-				w := "'%" + keyword + "%'"
-				query += (" AND content LIKE " + w)
-			}
+			where += " AND content LIKE ?"
+			args = append(args, "%"+keyword+"%")
 		}
 	}
 
+	// 与保存的时间一样转换为UTC后比较
 	if filter.StartTime != nil {
-		query += " AND log_time >= ?"
-		args = append(args, filter.StartTime)
+		where += " AND log_time >= ?"
+		args = append(args, filter.StartTime.UTC())
 	}
 
 	if filter.EndTime != nil {
-		query += " AND log_time <= ?"
-		args = append(args, filter.EndTime)
+		where += " AND log_time <= ?"
+		args = append(args, filter.EndTime.UTC())
 	}
 
 	if filter.Source != "" {
-		query += " AND source LIKE ?"
+		where += " AND source LIKE ?"
 		args = append(args, "%"+filter.Source+"%")
 	}
 
 	if filter.Module != "" {
-		query += " AND module = ?"
+		where += " AND module = ?"
 		args = append(args, filter.Module)
 	}
 
+	// 场景、场景关键词过滤（命中任一即可）
+	annotationFilters := []struct {
+		column string
+		values []string
+	}{{"scene", filter.Scenes}, {"keyword", filter.SceneKeywords}}
+	for _, f := range annotationFilters {
+		if len(f.values) == 0 {
+			continue
+		}
+		where += " AND id IN (SELECT entry_id FROM log_annotations WHERE file_id IN (" + placeholders + ") AND " +
+			f.column + " IN (" + strings.Repeat("?,", len(f.values)-1) + "?))"
+		for _, id := range fileIDs {
+			args = append(args, strings.TrimSpace(id))
+		}
+		for _, v := range f.values {
+			args = append(args, v)
+		}
+	}
+
+	// 扩展属性过滤（按键的字符串值精确匹配）
+	for key, value := range filter.Attrs {
+		where += " AND " + attrTextExpr + " = ?"
+		args = append(args, AttrPath(key), AttrPath(key), value)
+	}
+
+	// 扩展属性数值范围过滤，非数值的属性不在任何范围内
+	for key, r := range filter.AttrRanges {
+		if r.Min != nil {
+			where += " AND " + attrNumberExpr + " >= ?"
+			args = append(args, AttrPath(key), AttrPath(key), *r.Min)
+		}
+		if r.Max != nil {
+			where += " AND " + attrNumberExpr + " <= ?"
+			args = append(args, AttrPath(key), AttrPath(key), *r.Max)
+		}
+	}
+
+	return where, args
+}
+
+// attrTextExpr 扩展属性的字符串值，参数为两次属性路径；
+// json_extract 将JSON布尔值转换为1/0，布尔值和null按JSON字面量（true、false、null）比较
+const attrTextExpr = "CASE json_type(attrs, ?) WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' WHEN 'null' THEN 'null' " +
+	"ELSE CAST(json_extract(attrs, ?) AS TEXT) END"
+
+// attrNumberExpr 扩展属性的数值，参数为两次属性路径；字符串、布尔值等非数值为NULL，
+// 避免 CAST 将其转换为0后参与范围过滤和统计
+const attrNumberExpr = "CASE WHEN json_type(attrs, ?) IN ('integer','real') THEN json_extract(attrs, ?) END"
+
+// fieldColumns 可用于排序和分组的固定字段
+var fieldColumns = map[string]string{
+	"log_time":    "log_time",
+	"line":        "line_number",
+	"level":       "level",
+	"severity":    "severity",
+	"module":      "module",
+	"process":     "process",
+	"thread":      "thread",
+	"class":       "class",
+	"tag":         "tag",
+	"source":      "source",
+	"time_source": "time_source",
+}
+
+// fieldExpr 将字段名（固定字段或 attr.键）转换为SQL表达式
+func fieldExpr(field string) (string, []interface{}, error) {
+	if key := strings.TrimPrefix(field, "attr."); key != field && key != "" {
+		return "json_extract(attrs, ?)", []interface{}{AttrPath(key)}, nil
+	}
+	if column, ok := fieldColumns[field]; ok {
+		return column, nil, nil
+	}
+	return "", nil, fmt.Errorf("不支持的字段: %s", field)
+}
+
+// numberExpr 将统计字段（固定字段或 attr.键）转换为数值表达式，非数值为NULL，不参与统计
+func numberExpr(field string) (string, []interface{}, error) {
+	if key := strings.TrimPrefix(field, "attr."); key != field && key != "" {
+		return attrNumberExpr, []interface{}{AttrPath(key), AttrPath(key)}, nil
+	}
+	if column, ok := fieldColumns[field]; ok {
+		return "CASE WHEN typeof(" + column + ") IN ('integer','real') THEN " + column + " END", nil, nil
+	}
+	return "", nil, fmt.Errorf("不支持的字段: %s", field)
+}
+
+// buildOrder 构建排序子句，未指定排序字段时按时间和行号升序
+func buildOrder(filter LogFilter) (string, []interface{}, error) {
+	order := " ORDER BY log_time ASC, line_number ASC"
+	if filter.SortBy == "" {
+		return order, nil, nil
+	}
+	expr, args, err := fieldExpr(filter.SortBy)
+	if err != nil {
+		return "", nil, err
+	}
+	direction := " ASC"
+	if filter.SortDesc {
+		direction = " DESC"
+	}
+	return " ORDER BY " + expr + direction + ", log_time ASC, line_number ASC", args, nil
+}
+
+// AttrPath 将属性键（点号分隔表示嵌套，如 http.status）转换为SQLite JSON路径
+func AttrPath(key string) string {
+	path := "$"
+	for _, seg := range strings.Split(key, ".") {
+		path += `."` + strings.ReplaceAll(seg, `"`, `\"`) + `"`
+	}
+	return path
+}
+
+// encodeAttrs 将扩展属性编码为JSON，无属性时存NULL
+func encodeAttrs(attrs map[string]interface{}) (interface{}, error) {
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// decodeAttrs 解析数据库中的扩展属性JSON
+func decodeAttrs(data sql.NullString) map[string]interface{} {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal([]byte(data.String), &attrs); err != nil {
+		return nil
+	}
+	return attrs
+}
+
+// 获取日志条目
+func (d *Database) GetLogEntries(fileID string, filter LogFilter) ([]LogEntry, error) {
+	where, args := buildWhere(fileID, filter)
+	query := `SELECT id, log_time, save_time, module, level, severity, process, thread, class, class_line, tag, message, content, source, line_number, end_line, color, attrs, time_source FROM log_entries` + where
+
 	// 添加排序和分页
-	query += " ORDER BY log_time ASC, line_number ASC"
+	order, orderArgs, err := buildOrder(filter)
+	if err != nil {
+		return nil, err
+	}
+	query += order
+	args = append(args, orderArgs...)
 
 	if filter.Limit > 0 {
 		query += " LIMIT ?"
@@ -262,12 +864,14 @@ func (d *Database) GetLogEntries(fileID string, filter LogFilter) ([]LogEntry, e
 	var entries []LogEntry
 	for rows.Next() {
 		var entry LogEntry
+		var attrs sql.NullString
 		err := rows.Scan(
 			&entry.ID,
 			&entry.LogTime,
 			&entry.SaveTime,
 			&entry.Module,
 			&entry.Level,
+			&entry.Severity,
 			&entry.Process,
 			&entry.Thread,
 			&entry.Class,
@@ -277,14 +881,25 @@ func (d *Database) GetLogEntries(fileID string, filter LogFilter) ([]LogEntry, e
 			&entry.Content,
 			&entry.Source,
 			&entry.Line,
+			&entry.EndLine,
 			&entry.Color,
+			&attrs,
+			&entry.TimeSource,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("扫描日志条目数据失败: %w", err)
 		}
+		entry.Attrs = decodeAttrs(attrs)
 		entries = append(entries, entry)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询日志条目失败: %w", err)
+	}
+	rows.Close()
 
+	if err := d.attachAnnotations(entries); err != nil {
+		return nil, err
+	}
 	return entries, nil
 }
 
@@ -293,209 +908,130 @@ func (d *Database) GetLogStats(fileID string, filter LogFilter) (LogStats, error
 	stats := LogStats{
 		LevelCounts: make(map[string]int),
 	}
-
-	// 检查fileID是否包含多个ID（逗号分隔）
-	fileIDs := strings.Split(fileID, ",")
+	where, args := buildWhere(fileID, filter)
 
 	// 获取总条目数
-	query := "SELECT COUNT(*) FROM log_entries"
-	args := make([]interface{}, 0)
-
-	if len(fileIDs) > 0 {
-		// 构建IN查询
-		placeholders := strings.Repeat("?,", len(fileIDs)-1) + "?"
-		query += " WHERE file_id IN (" + placeholders + ")"
-		for _, id := range fileIDs {
-			args = append(args, strings.TrimSpace(id))
-		}
-	} else {
-		query += " WHERE file_id = ?"
-		args = append(args, fileID)
-	}
-
-	// 添加过滤条件
-	if len(filter.Levels) > 0 {
-		placeholders := strings.Repeat("?,", len(filter.Levels)-1) + "?"
-		query += " AND level IN (" + placeholders + ")"
-		for _, level := range filter.Levels {
-			args = append(args, level)
-		}
-	}
-
-	if len(filter.Keywords) > 0 {
-		for _, keyword := range filter.Keywords {
-			query += " AND message LIKE ?"
-			args = append(args, "%"+keyword+"%")
-		}
-	}
-
-	if filter.StartTime != nil {
-		query += " AND log_time >= ?"
-		args = append(args, filter.StartTime)
-	}
-
-	if filter.EndTime != nil {
-		query += " AND log_time <= ?"
-		args = append(args, filter.EndTime)
-	}
-
-	if filter.Source != "" {
-		query += " AND source LIKE ?"
-		args = append(args, "%"+filter.Source+"%")
-	}
-
-	if filter.Module != "" {
-		query += " AND module = ?"
-		args = append(args, filter.Module)
-	}
-
-	// 获取总条目数
-	err := d.db.QueryRow(query, args...).Scan(&stats.TotalEntries)
+	err := d.db.QueryRow("SELECT COUNT(*) FROM log_entries"+where, args...).Scan(&stats.TotalEntries)
 	if err != nil {
 		return stats, fmt.Errorf("获取总条目数失败: %w", err)
 	}
 
 	// 获取时间范围
-	timeQuery := "SELECT MIN(log_time), MAX(log_time) FROM log_entries"
-	timeArgs := make([]interface{}, 0)
-
-	if len(fileIDs) > 0 {
-		// 构建IN查询
-		placeholders := strings.Repeat("?,", len(fileIDs)-1) + "?"
-		timeQuery += " WHERE file_id IN (" + placeholders + ")"
-		for _, id := range fileIDs {
-			timeArgs = append(timeArgs, strings.TrimSpace(id))
-		}
-	} else {
-		timeQuery += " WHERE file_id = ?"
-		timeArgs = append(timeArgs, fileID)
-	}
-
-	// 添加相同的过滤条件到时间查询
-	if len(filter.Levels) > 0 {
-		placeholders := strings.Repeat("?,", len(filter.Levels)-1) + "?"
-		timeQuery += " AND level IN (" + placeholders + ")"
-		for _, level := range filter.Levels {
-			timeArgs = append(timeArgs, level)
-		}
-	}
-
-	if len(filter.Keywords) > 0 {
-		for _, keyword := range filter.Keywords {
-			timeQuery += " AND message LIKE ?"
-			timeArgs = append(timeArgs, "%"+keyword+"%")
-		}
-	}
-
-	if filter.StartTime != nil {
-		timeQuery += " AND log_time >= ?"
-		timeArgs = append(timeArgs, filter.StartTime)
-	}
-
-	if filter.EndTime != nil {
-		timeQuery += " AND log_time <= ?"
-		timeArgs = append(timeArgs, filter.EndTime)
-	}
-
-	if filter.Source != "" {
-		timeQuery += " AND source LIKE ?"
-		timeArgs = append(timeArgs, "%"+filter.Source+"%")
-	}
-
-	if filter.Module != "" {
-		timeQuery += " AND module = ?"
-		timeArgs = append(timeArgs, filter.Module)
-	}
-
+	timeQuery := "SELECT MIN(log_time), MAX(log_time) FROM log_entries" + where
 	var startTimeStr, endTimeStr sql.NullString
-	err = d.db.QueryRow(timeQuery, timeArgs...).Scan(&startTimeStr, &endTimeStr)
+	err = d.db.QueryRow(timeQuery, args...).Scan(&startTimeStr, &endTimeStr)
 	if err != nil && err != sql.ErrNoRows {
 		return stats, fmt.Errorf("获取时间范围失败: %w", err)
 	}
 
 	// 解析时间字符串
 	if startTimeStr.Valid {
-		if t, err := time.Parse("2006-01-02 15:04:05", startTimeStr.String); err == nil {
+		if t, ok := parseStoredTime(startTimeStr.String); ok {
 			stats.TimeRange.Start = t
 		}
 	}
 	if endTimeStr.Valid {
-		if t, err := time.Parse("2006-01-02 15:04:05", endTimeStr.String); err == nil {
+		if t, ok := parseStoredTime(endTimeStr.String); ok {
 			stats.TimeRange.End = t
 		}
 	}
 
 	// 获取各级别统计
-	levelQuery := `SELECT level, COUNT(*) FROM log_entries`
-	levelArgs := make([]interface{}, 0)
-
-	if len(fileIDs) > 0 {
-		// 构建IN查询
-		placeholders := strings.Repeat("?,", len(fileIDs)-1) + "?"
-		levelQuery += " WHERE file_id IN (" + placeholders + ")"
-		for _, id := range fileIDs {
-			levelArgs = append(levelArgs, strings.TrimSpace(id))
-		}
-	} else {
-		levelQuery += " WHERE file_id = ?"
-		levelArgs = append(levelArgs, fileID)
+	levelQuery := `SELECT level, COUNT(*) FROM log_entries` + where + " GROUP BY level"
+	levelRows, err := d.db.Query(levelQuery, args...)
+	if err != nil {
+		return stats, fmt.Errorf("获取级别统计失败: %w", err)
 	}
+	defer levelRows.Close()
 
-	// 添加相同的过滤条件到级别统计查询
-	if len(filter.Levels) > 0 {
-		placeholders := strings.Repeat("?,", len(filter.Levels)-1) + "?"
-		levelQuery += " AND level IN (" + placeholders + ")"
-		for _, level := range filter.Levels {
-			levelArgs = append(levelArgs, level)
+	for levelRows.Next() {
+		var level string
+		var count int
+		err := levelRows.Scan(&level, &count)
+		if err != nil {
+			return stats, fmt.Errorf("扫描级别统计失败: %w", err)
 		}
+		stats.LevelCounts[level] = count
 	}
 
-	if len(filter.Keywords) > 0 {
-		for _, keyword := range filter.Keywords {
-			levelQuery += " AND message LIKE ?"
-			levelArgs = append(levelArgs, "%"+keyword+"%")
-		}
-	}
+	return stats, nil
+}
 
-	if filter.StartTime != nil {
-		levelQuery += " AND log_time >= ?"
-		levelArgs = append(levelArgs, filter.StartTime)
+// AggregateLogs 按字段分组统计日志条数，metric不为空时附带该字段的数值统计（非数值不参与），按条数降序返回前limit组
+func (d *Database) AggregateLogs(fileID string, filter LogFilter, groupBy, metric string, limit int) ([]AggregateBucket, error) {
+	groupExpr, args, err := fieldExpr(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + groupExpr + ", COUNT(*)"
+	if metric != "" {
+		value, metricArgs, err := numberExpr(metric)
+		if err != nil {
+			return nil, err
+		}
+		query += ", SUM(" + value + "), AVG(" + value + "), MIN(" + value + "), MAX(" + value + ")"
+		for i := 0; i < 4; i++ {
+			args = append(args, metricArgs...)
+		}
 	}
+	where, whereArgs := buildWhere(fileID, filter)
+	query += " FROM log_entries" + where + " GROUP BY 1 ORDER BY 2 DESC LIMIT ?"
+	args = append(append(args, whereArgs...), limit)
 
-	if filter.EndTime != nil {
-		levelQuery += " AND log_time <= ?"
-		levelArgs = append(levelArgs, filter.EndTime)
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("聚合查询失败: %w", err)
 	}
+	defer rows.Close()
 
-	if filter.Source != "" {
-		levelQuery += " AND source LIKE ?"
-		levelArgs = append(levelArgs, "%"+filter.Source+"%")
+	buckets := make([]AggregateBucket, 0)
+	for rows.Next() {
+		var bucket AggregateBucket
+		dest := []interface{}{&bucket.Value, &bucket.Count}
+		var sum, avg, min, max sql.NullFloat64
+		if metric != "" {
+			dest = append(dest, &sum, &avg, &min, &max)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("扫描聚合结果失败: %w", err)
+		}
+		if b, ok := bucket.Value.([]byte); ok {
+			bucket.Value = string(b)
+		}
+		bucket.Sum, bucket.Avg, bucket.Min, bucket.Max = nullFloat(sum), nullFloat(avg), nullFloat(min), nullFloat(max)
+		buckets = append(buckets, bucket)
 	}
+	return buckets, rows.Err()
+}
 
-	if filter.Module != "" {
-		levelQuery += " AND module = ?"
-		levelArgs = append(levelArgs, filter.Module)
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
 	}
+	return &v.Float64
+}
 
-	levelQuery += " GROUP BY level"
+// storedTimeLayouts 数据库中时间的存储格式，驱动默认按 time.Time.String() 写入；
+// 固定时区（如 time.FixedZone 或解析出的 +08:00）的时区名与偏移相同，如 "+0800 +0800"
+var storedTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999 -0700 -0700",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+}
 
-	levelRows, err := d.db.Query(levelQuery, levelArgs...)
-	if err != nil {
-		return stats, fmt.Errorf("获取级别统计失败: %w", err)
+// parseStoredTime 解析聚合查询（MIN/MAX）直接返回的时间字符串
+func parseStoredTime(value string) (time.Time, bool) {
+	// 去掉单调时钟部分 "m=+0.001"
+	if i := strings.Index(value, " m="); i > 0 {
+		value = value[:i]
 	}
-	defer levelRows.Close()
-
-	for levelRows.Next() {
-		var level string
-		var count int
-		err := levelRows.Scan(&level, &count)
-		if err != nil {
-			return stats, fmt.Errorf("扫描级别统计失败: %w", err)
+	for _, layout := range storedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
 		}
-		stats.LevelCounts[level] = count
 	}
-
-	return stats, nil
+	return time.Time{}, false
 }
 
 // 删除日志文件
@@ -517,34 +1053,119 @@ func (d *Database) DeleteLogFileEntry(fileID string) error {
 	if err != nil {
 		return fmt.Errorf("删除日志条目失败: %w", err)
 	}
+	_, err = d.db.Exec("DELETE FROM log_annotations WHERE file_id = ?", fileID)
+	if err != nil {
+		return fmt.Errorf("删除日志标注失败: %w", err)
+	}
 	return nil
 }
 
-// 搜索日志
-func (d *Database) SearchLogs(fileID string, query string, limit int) ([]LogEntry, error) {
-	rows, err := d.db.Query(`
-		SELECT id, log_time,save_time, level, message, source, line_number, color
-		FROM log_entries
-		WHERE file_id = ? AND message LIKE ?
-		ORDER BY log_time DESC
-		LIMIT ?`, fileID, "%"+query+"%", limit)
+// SearchLogs 在文件（逗号分隔支持多个）中全文检索消息和原始内容，返回摘要、高亮和命中位置，
+// 检索表达式不符合FTS5语法时返回 ErrSearchQuery
+func (d *Database) SearchLogs(fileID string, query SearchQuery) ([]SearchHit, error) {
+	if term := shortTerm(query.Query); term != "" {
+		terms, ok := plainTerms(query.Query)
+		if !ok {
+			return nil, fmt.Errorf("%w: 检索词%q不足3个字符，全文索引无法匹配，去掉FTS5语法后可按关键词逐行匹配", ErrSearchQuery, term)
+		}
+		return d.searchLike(fileID, terms, query)
+	}
+
+	placeholders, fileArgs := splitFileIDs(fileID)
+	order := " ORDER BY log_entries_fts.rank, e.log_time ASC, e.line_number ASC"
+	switch query.Order {
+	case SearchOrderTime:
+		order = " ORDER BY e.log_time ASC, e.line_number ASC"
+	case SearchOrderTimeDesc:
+		order = " ORDER BY e.log_time DESC, e.line_number DESC"
+	}
+	stmt := `
+		SELECT e.id, e.log_time, e.save_time, e.module, e.level, e.severity, e.process, e.thread, e.class, e.class_line, e.tag,
+			e.message, e.content, e.source, e.line_number, e.end_line, e.color, e.attrs, e.time_source,
+			log_entries_fts.rank,
+			snippet(log_entries_fts, -1, ?, ?, '…', 64),
+			highlight(log_entries_fts, 0, ?, ?),
+			highlight(log_entries_fts, 1, ?, ?)
+		FROM log_entries_fts JOIN log_entries e ON e.rowid = log_entries_fts.rowid
+		WHERE log_entries_fts MATCH ? AND e.file_id IN (` + placeholders + `)` + order + `
+		LIMIT ? OFFSET ?`
+	args := []interface{}{matchOpen, matchClose, matchOpen, matchClose, matchOpen, matchClose, query.Query}
+	args = append(append(args, fileArgs...), query.Limit, query.Offset)
+	return d.querySearchHits(stmt, args, nil)
+}
+
+// searchLike 逐行匹配包含全部关键词的条目，用于trigram无法索引的短关键词；
+// 没有相关度，按相关度排序时按时间升序，命中位置在查询结果中标记
+func (d *Database) searchLike(fileID string, terms []string, query SearchQuery) ([]SearchHit, error) {
+	placeholders, args := splitFileIDs(fileID)
+	where := "e.file_id IN (" + placeholders + ")"
+	for _, term := range terms {
+		where += ` AND (e.message LIKE ? ESCAPE '\' OR e.content LIKE ? ESCAPE '\')`
+		args = append(args, likePattern(term), likePattern(term))
+	}
+	order := " ORDER BY e.log_time ASC, e.line_number ASC"
+	if query.Order == SearchOrderTimeDesc {
+		order = " ORDER BY e.log_time DESC, e.line_number DESC"
+	}
+	stmt := `
+		SELECT e.id, e.log_time, e.save_time, e.module, e.level, e.severity, e.process, e.thread, e.class, e.class_line, e.tag,
+			e.message, e.content, e.source, e.line_number, e.end_line, e.color, e.attrs, e.time_source,
+			0, e.content, e.message, e.content
+		FROM log_entries e
+		WHERE ` + where + order + `
+		LIMIT ? OFFSET ?`
+	args = append(args, query.Limit, query.Offset)
+	return d.querySearchHits(stmt, args, terms)
+}
+
+// querySearchHits 执行检索并转换结果，查询的最后四列为相关度、摘要、消息和原始内容；
+// terms 不为空时摘要、消息和原始内容未标记命中词，按关键词标记
+func (d *Database) querySearchHits(stmt string, args []interface{}, terms []string) ([]SearchHit, error) {
+	rows, err := d.db.Query(stmt, args...)
 	if err != nil {
-		return nil, fmt.Errorf("搜索日志失败: %w", err)
+		return nil, searchError(err)
 	}
 	defer rows.Close()
 
-	var entries []LogEntry
+	var hits []SearchHit
 	for rows.Next() {
-		var entry LogEntry
-		err := rows.Scan(&entry.ID, &entry.LogTime, &entry.SaveTime, &entry.Level,
-			&entry.Message, &entry.Source, &entry.Line, &entry.Color)
+		var hit SearchHit
+		var attrs sql.NullString
+		var snippet, message, content string
+		err := rows.Scan(
+			&hit.ID, &hit.LogTime, &hit.SaveTime, &hit.Module, &hit.Level, &hit.Severity, &hit.Process, &hit.Thread, &hit.Class, &hit.ClassLine, &hit.Tag,
+			&hit.Message, &hit.Content, &hit.Source, &hit.Line, &hit.EndLine, &hit.Color, &attrs, &hit.TimeSource,
+			&hit.Rank, &snippet, &message, &content,
+		)
 		if err != nil {
 			return nil, fmt.Errorf("扫描搜索结果失败: %w", err)
 		}
-		entries = append(entries, entry)
+		hit.Attrs = decodeAttrs(attrs)
+		if len(terms) > 0 {
+			message, content = markTerms(message, terms), markTerms(content, terms)
+			snippet = markedSnippet(content)
+		}
+		hit.Snippet = markHTML(snippet)
+		hit.Highlight = markHTML(message)
+		hit.Offsets = append(matchOffsets("message", message), matchOffsets("content", content)...)
+		hits = append(hits, hit)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, searchError(err)
+	}
+	rows.Close()
 
-	return entries, nil
+	entries := make([]LogEntry, len(hits))
+	for i := range hits {
+		entries[i] = hits[i].LogEntry
+	}
+	if err := d.attachAnnotations(entries); err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		hits[i].Annotations = entries[i].Annotations
+	}
+	return hits, nil
 }
 
 func (s *Database) GetModuleOptions(fileID string) ([]*string, error) {
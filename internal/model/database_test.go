@@ -0,0 +1,272 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"log-tools-go/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// legacySchema 最初版本的表结构（无severity、attrs、全文索引等）
+const legacySchema = `
+CREATE TABLE log_files (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	upload_at DATETIME NOT NULL,
+	total_entries INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE log_entries (
+	id TEXT PRIMARY KEY,
+	file_id TEXT NOT NULL,
+	log_time DATETIME NOT NULL,
+	save_time DATETIME NOT NULL,
+	level TEXT NOT NULL,
+	module TEXT,
+	process TEXT,
+	thread TEXT,
+	class TEXT,
+	class_line TEXT,
+	tag TEXT,
+	message TEXT NOT NULL,
+	content TEXT NOT NULL,
+	source TEXT NOT NULL,
+	line_number INTEGER NOT NULL,
+	color TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (file_id) REFERENCES log_files(id) ON DELETE CASCADE
+);
+`
+
+// openTestDatabase 在临时目录中打开数据库
+func openTestDatabase(t *testing.T, path string) *Database {
+	t.Helper()
+	db, err := NewDatabase(&config.Config{Storage: config.StorageConfig{DatabasePath: path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createLegacyDatabase 按旧版本表结构创建数据库，写入指定级别和消息的条目
+func createLegacyDatabase(t *testing.T, rows [][2]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)
+	if _, err := db.Exec("INSERT INTO log_files (id, name, size, upload_at) VALUES ('f1', 'old.log', 1, ?)", now); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		_, err := db.Exec(`INSERT INTO log_entries (id, file_id, log_time, save_time, level, module, message, content, source, line_number, color)
+			VALUES (?, 'f1', ?, ?, ?, '', ?, ?, 'old.log', ?, '')`,
+			"f1_"+string(rune('a'+i)), now.Add(time.Duration(i)*time.Second), now, row[0], row[1], row[1], i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestMigrateLegacySeverity(t *testing.T) {
+	path := createLegacyDatabase(t, [][2]string{
+		{"E", "e"}, {"D", "d"}, {"W", "w"}, {"warning", "warning"}, {"err", "err"},
+		{"INFO", "info"}, {" fatal ", "fatal"}, {"custom", "custom"},
+	})
+	db := openTestDatabase(t, path)
+
+	rows, err := db.db.Query("SELECT message, severity FROM log_entries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := map[string]int{}
+	for rows.Next() {
+		var message string
+		var severity int
+		if err := rows.Scan(&message, &severity); err != nil {
+			t.Fatal(err)
+		}
+		got[message] = severity
+	}
+	want := map[string]int{"e": 5, "d": 2, "w": 4, "warning": 4, "err": 5, "info": 3, "fatal": 6, "custom": 0}
+	for message, severity := range want {
+		if got[message] != severity {
+			t.Errorf("%s severity = %d, want %d", message, got[message], severity)
+		}
+	}
+
+	// 迁移后旧数据可按最低级别过滤
+	entries, err := db.GetLogEntries("f1", LogFilter{MinLevel: "WARN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("min_level=WARN entries = %d, want 5", len(entries))
+	}
+}
+
+// testEntry 构造测试用的日志条目，原始内容为时间、级别和消息
+func testEntry(fileID string, i int, level, message string) LogEntry {
+	logTime := time.Date(2024, 8, 2, 15, 56, i, 0, time.UTC)
+	return LogEntry{
+		ID:       fmt.Sprintf("%s_%d", fileID, i),
+		LogTime:  logTime,
+		SaveTime: logTime,
+		Level:    level,
+		Severity: config.LevelSeverity(level),
+		Message:  message,
+		Content:  "2024-08-02 " + level + " " + message,
+		Source:   fileID + ".log",
+		Line:     i + 1,
+		EndLine:  i + 1,
+	}
+}
+
+// saveTestLog 保存文件及其条目
+func saveTestLog(t *testing.T, db *Database, fileID string, entries ...LogEntry) {
+	t.Helper()
+	logFile := &LogFile{ID: fileID, Name: fileID + ".log", UploadAt: time.Now(), Entries: entries, Total: len(entries)}
+	if err := db.SaveLogFile(logFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDatabasePathWithParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "params.db")
+	db := openTestDatabase(t, path+"?_pragma=foreign_keys(1)")
+	var journal string
+	var foreignKeys int
+	if err := db.db.QueryRow("PRAGMA journal_mode").Scan(&journal); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatal(err)
+	}
+	if journal != "wal" || foreignKeys != 1 {
+		t.Errorf("journal_mode = %s, foreign_keys = %d", journal, foreignKeys)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("数据库文件 = %v", err)
+	}
+}
+
+func TestSaveMultiLineEntry(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	entry := testEntry("f1", 0, "ERROR", "request failed\n\tat Service.handle(Service.java:42)")
+	entry.EndLine = 2
+	saveTestLog(t, db, "f1", entry)
+	entries, err := db.GetLogEntries("f1", LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Line != 1 || entries[0].EndLine != 2 || entries[0].Message != entry.Message {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestMixedTimeZones(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	shanghai := time.FixedZone("+0800", 8*3600)
+	at := func(entry LogEntry, tm time.Time) LogEntry {
+		entry.LogTime = tm
+		return entry
+	}
+	// f1 按+08:00解析，f2 为UTC：按本地时间文本比较时 10:00+08:00 会排在 03:00Z 之后
+	saveTestLog(t, db, "f1",
+		at(testEntry("f1", 0, "INFO", "a"), time.Date(2024, 8, 2, 10, 0, 0, 0, shanghai)),
+		at(testEntry("f1", 1, "INFO", "c"), time.Date(2024, 8, 2, 12, 0, 0, 500000000, shanghai)),
+	)
+	saveTestLog(t, db, "f2", at(testEntry("f2", 0, "INFO", "b"), time.Date(2024, 8, 2, 3, 0, 0, 0, time.UTC)))
+
+	messages := func(filter LogFilter) []string {
+		t.Helper()
+		entries, err := db.GetLogEntries("f1,f2", filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Message)
+		}
+		return got
+	}
+	if got := messages(LogFilter{}); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("order = %v", got)
+	}
+	// 过滤条件可使用任意时区
+	start := time.Date(2024, 8, 2, 10, 30, 0, 0, shanghai)
+	end := time.Date(2024, 8, 2, 4, 0, 0, 0, time.UTC)
+	if got := messages(LogFilter{StartTime: &start, EndTime: &end}); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("range = %v", got)
+	}
+
+	stats, err := db.GetLogStats("f1,f2", LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.TimeRange.Start.Equal(time.Date(2024, 8, 2, 2, 0, 0, 0, time.UTC)) ||
+		!stats.TimeRange.End.Equal(time.Date(2024, 8, 2, 4, 0, 0, 500000000, time.UTC)) {
+		t.Errorf("time range = %v - %v", stats.TimeRange.Start, stats.TimeRange.End)
+	}
+}
+
+func TestParseStoredTime(t *testing.T) {
+	want := time.Date(2024, 8, 2, 2, 0, 0, 0, time.UTC)
+	for _, value := range []string{
+		"2024-08-02 02:00:00 +0000 UTC",
+		"2024-08-02 10:00:00 +0800 CST",
+		"2024-08-02 10:00:00 +0800 +0800",
+		"2024-08-02 10:00:00+08:00",
+		"2024-08-02 02:00:00 +0000 UTC m=+0.001",
+	} {
+		if got, ok := parseStoredTime(value); !ok || !got.Equal(want) {
+			t.Errorf("parseStoredTime(%q) = %v, %v", value, got, ok)
+		}
+	}
+}
+
+func TestMigrateUTCTimes(t *testing.T) {
+	path := createLegacyDatabase(t, [][2]string{{"I", "a"}, {"I", "b"}, {"I", "c"}})
+	raw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本按原时区保存：固定时区的文本驱动无法读取，不同时区的文本无法直接排序
+	_, err = raw.Exec(`UPDATE log_entries SET log_time = CASE id
+		WHEN 'f1_b' THEN '2024-08-02 23:00:00 +0800 +0800'
+		WHEN 'f1_c' THEN '2024-08-02 23:30:00 +0800 CST' ELSE log_time END`)
+	raw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := openTestDatabase(t, path)
+	entries, err := db.GetLogEntries("f1", LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Message)
+	}
+	if !reflect.DeepEqual(got, []string{"b", "c", "a"}) {
+		t.Errorf("order = %v", got)
+	}
+	if !entries[0].LogTime.Equal(time.Date(2024, 8, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("time = %v", entries[0].LogTime)
+	}
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// attrEntry 构造带扩展属性的测试条目
+func attrEntry(i int, attrs map[string]interface{}) LogEntry {
+	entry := testEntry("f1", i, "INFO", "request")
+	entry.Attrs = attrs
+	return entry
+}
+
+// entryIDs 按条件查询并返回条目ID
+func entryIDs(t *testing.T, db *Database, filter LogFilter) []string {
+	t.Helper()
+	entries, err := db.GetLogEntries("f1", filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestAttrFilter(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	saveTestLog(t, db, "f1",
+		attrEntry(0, map[string]interface{}{"status": 200, "cached": true, "user": "alice", "http": map[string]interface{}{"method": "GET"}}),
+		attrEntry(1, map[string]interface{}{"status": "200", "cached": false, "user": nil}),
+		attrEntry(2, map[string]interface{}{"status": 500, "latency": 1.5}),
+		attrEntry(3, nil),
+	)
+
+	tests := []struct {
+		attrs map[string]string
+		want  []string
+	}{
+		{map[string]string{"status": "200"}, []string{"f1_0", "f1_1"}},
+		{map[string]string{"cached": "true"}, []string{"f1_0"}},
+		{map[string]string{"cached": "false"}, []string{"f1_1"}},
+		{map[string]string{"cached": "1"}, []string{}},
+		{map[string]string{"user": "null"}, []string{"f1_1"}},
+		{map[string]string{"latency": "1.5"}, []string{"f1_2"}},
+		{map[string]string{"http.method": "GET"}, []string{"f1_0"}},
+		{map[string]string{"status": "200", "user": "alice"}, []string{"f1_0"}},
+		{map[string]string{"missing": ""}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := entryIDs(t, db, LogFilter{Attrs: tt.attrs}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("attrs %v = %v, want %v", tt.attrs, got, tt.want)
+		}
+	}
+
+}
+
+func TestAttrRangeAndSort(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	saveTestLog(t, db, "f1",
+		attrEntry(0, map[string]interface{}{"latency": 120}),
+		attrEntry(1, map[string]interface{}{"latency": 8.5}),
+		attrEntry(2, map[string]interface{}{"latency": "slow"}),
+		attrEntry(3, map[string]interface{}{"latency": true}),
+		attrEntry(4, map[string]interface{}{"latency": 45}),
+		attrEntry(5, nil),
+	)
+	value := func(v float64) *float64 { return &v }
+
+	// 字符串、布尔值不按0参与范围过滤
+	tests := []struct {
+		r    AttrRange
+		want []string
+	}{
+		{AttrRange{Min: value(0)}, []string{"f1_0", "f1_1", "f1_4"}},
+		{AttrRange{Max: value(50)}, []string{"f1_1", "f1_4"}},
+		{AttrRange{Min: value(10), Max: value(100)}, []string{"f1_4"}},
+		{AttrRange{Max: value(1)}, []string{}},
+	}
+	for _, tt := range tests {
+		got := entryIDs(t, db, LogFilter{AttrRanges: map[string]AttrRange{"latency": tt.r}})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("range %+v = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+
+	// 按属性排序，同值按时间；升序时无该属性的条目在前
+	got := entryIDs(t, db, LogFilter{SortBy: "attr.latency", AttrRanges: map[string]AttrRange{"latency": {Min: value(0)}}})
+	if want := []string{"f1_1", "f1_4", "f1_0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort = %v, want %v", got, want)
+	}
+	got = entryIDs(t, db, LogFilter{SortBy: "attr.latency", SortDesc: true, AttrRanges: map[string]AttrRange{"latency": {Min: value(0)}}})
+	if want := []string{"f1_0", "f1_4", "f1_1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort desc = %v, want %v", got, want)
+	}
+	if got := entryIDs(t, db, LogFilter{SortBy: "attr.latency"}); got[0] != "f1_5" {
+		t.Errorf("sort = %v", got)
+	}
+	if _, err := db.GetLogEntries("f1", LogFilter{SortBy: "unknown"}); err == nil {
+		t.Error("不支持的排序字段应返回错误")
+	}
+}
+
+func TestAggregateLogs(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	saveTestLog(t, db, "f1",
+		attrEntry(0, map[string]interface{}{"path": "/a", "latency": 100}),
+		attrEntry(1, map[string]interface{}{"path": "/a", "latency": 300}),
+		attrEntry(2, map[string]interface{}{"path": "/a", "latency": "timeout"}),
+		attrEntry(3, map[string]interface{}{"path": "/b", "latency": 50.5}),
+		attrEntry(4, map[string]interface{}{"path": "/c", "latency": false}),
+	)
+
+	buckets, err := db.AggregateLogs("f1", LogFilter{}, "attr.path", "attr.latency", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 3 {
+		t.Fatalf("buckets = %+v", buckets)
+	}
+	// 非数值不参与统计：/a 的平均值为 (100+300)/2，/c 没有数值
+	a := buckets[0]
+	if a.Value != "/a" || a.Count != 3 || *a.Sum != 400 || *a.Avg != 200 || *a.Min != 100 || *a.Max != 300 {
+		t.Errorf("/a = %v %d %v %v %v %v", a.Value, a.Count, *a.Sum, *a.Avg, *a.Min, *a.Max)
+	}
+	for _, b := range buckets[1:] {
+		switch b.Value {
+		case "/b":
+			if b.Count != 1 || *b.Sum != 50.5 {
+				t.Errorf("/b = %+v", b)
+			}
+		case "/c":
+			if b.Count != 1 || b.Sum != nil || b.Avg != nil {
+				t.Errorf("/c = %+v", b)
+			}
+		default:
+			t.Errorf("bucket = %+v", b)
+		}
+	}
+
+	// 按固定字段分组并附带过滤条件
+	buckets, err = db.AggregateLogs("f1", LogFilter{Attrs: map[string]string{"path": "/a"}}, "level", "line", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 1 || buckets[0].Value != "INFO" || buckets[0].Count != 3 || *buckets[0].Sum != 6 {
+		t.Errorf("level = %+v", buckets)
+	}
+	if _, err := db.AggregateLogs("f1", LogFilter{}, "attr.", "", 10); err == nil {
+		t.Error("不支持的分组字段应返回错误")
+	}
+}
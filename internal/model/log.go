@@ -5,42 +5,120 @@ import (
 )
 
 type LogEntry struct {
-	ID        string    `json:"id"`         // 日志ID
-	LogTime   time.Time `json:"log_time"`   // 日志时间
-	SaveTime  time.Time `json:"save_time"`  // 日志保存时间
-	Level     string    `json:"level"`      // 日志级别
-	Module    string    `json:"module"`     // 模块名称
-	Process   *string   `json:"process"`    // 进程名称
-	Thread    *string   `json:"thread"`     // 线程名称
-	Class     *string   `json:"class"`      // 类名
-	ClassLine *string   `json:"class_line"` // 类行号
-	Tag       *string   `json:"tag"`        // 标签
-	Message   string    `json:"message"`    // 日志消息
-	Content   string    `json:"content"`    // 日志内容[原始内容]
-	Source    string    `json:"source"`     // 日志来源
-	Line      int       `json:"line"`       // 日志行号
-	Color     string    `json:"color"`      // 日志颜色
+	ID         string                 `json:"id"`              // 日志ID
+	LogTime    time.Time              `json:"log_time"`        // 日志时间
+	SaveTime   time.Time              `json:"save_time"`       // 日志保存时间
+	Level      string                 `json:"level"`           // 日志级别[标准级别]
+	Severity   int                    `json:"severity"`        // 级别严重程度[1-6对应TRACE至FATAL，0为非标准级别]
+	Module     string                 `json:"module"`          // 模块名称
+	Process    *string                `json:"process"`         // 进程名称
+	Thread     *string                `json:"thread"`          // 线程名称
+	Class      *string                `json:"class"`           // 类名
+	ClassLine  *string                `json:"class_line"`      // 类行号
+	Tag        *string                `json:"tag"`             // 标签
+	Message    string                 `json:"message"`         // 日志消息
+	Content    string                 `json:"content"`         // 日志内容[原始内容]
+	Source     string                 `json:"source"`          // 日志来源
+	Line       int                    `json:"line"`            // 日志行号
+	EndLine    int                    `json:"end_line"`        // 日志结束行号[多行日志]
+	Color      string                 `json:"color"`           // 日志颜色
+	Attrs      map[string]interface{} `json:"attrs,omitempty"` // 扩展属性[结构化日志中未映射的字段]
+	TimeSource string                 `json:"time_source"`     // 日志时间来源[parsed,inferred,inherited,synthesized]
+
+	Annotations []LogAnnotation `json:"annotations,omitempty"` // 命中的场景关键词
+}
+
+// LogAnnotation 日志条目命中的项目场景关键词
+type LogAnnotation struct {
+	EntryID string `json:"entry_id"`        // 日志ID
+	FileID  string `json:"file_id"`         // 文件ID
+	Line    int    `json:"line"`            // 日志行号
+	Module  string `json:"module"`          // 模块名称
+	Scene   string `json:"scene"`           // 场景名称
+	Keyword string `json:"keyword"`         // 关键词
+	Desc    string `json:"desc"`            // 关键词描述
+	Color   string `json:"color,omitempty"` // 关键词颜色
 }
 
+// 日志时间来源
+const (
+	TimeSourceParsed      = "parsed"      // 从日志中解析
+	TimeSourceInferred    = "inferred"    // 从日志中解析，年份为推断
+	TimeSourceInherited   = "inherited"   // 无法解析，沿用上一条日志的时间
+	TimeSourceSynthesized = "synthesized" // 无法解析且没有上一条日志，取文件参考时间
+)
+
 type LogFile struct {
-	ID       string     `json:"id"`
-	Name     string     `json:"name"`
-	Size     int64      `json:"size"`
-	UploadAt time.Time  `json:"upload_at"`
-	Entries  []LogEntry `json:"entries"`
-	Total    int        `json:"total"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Size        int64             `json:"size"`
+	UploadAt    time.Time         `json:"upload_at"`
+	Entries     []LogEntry        `json:"entries"`
+	Total       int               `json:"total"`
+	Encoding    string            `json:"encoding,omitempty"`    // 文件编码[识别或指定的编码，如utf-8、gbk、utf-16le]
+	Project     string            `json:"project,omitempty"`     // 解析使用的项目
+	RawPath     string            `json:"raw_path,omitempty"`    // 保留的原始文件路径，用于读取被截断行的完整内容
+	Diagnostics *ParseDiagnostics `json:"diagnostics,omitempty"` // 解析诊断信息
+}
+
+// ParseDiagnostics 单个文件的解析诊断，用于发现与日志格式不符的项目规则
+type ParseDiagnostics struct {
+	TotalLines       int              `json:"total_lines"`       // 非空行数
+	MatchedLines     int              `json:"matched_lines"`     // 匹配到任一字段的行数
+	FieldMatches     map[string]int   `json:"field_matches"`     // 各字段匹配到内容的行数
+	TimestampFailed  int              `json:"timestamp_failed"`  // 提取到时间但无法解析的行数
+	TimestampFormat  string           `json:"timestamp_format"`  // 实际使用的时间格式（未配置时为自动识别结果）
+	TimeSources      map[string]int   `json:"time_sources"`      // 各时间来源的条目数
+	UnmatchedSamples []DiagnosticLine `json:"unmatched_samples"` // 未匹配任何字段的行（前若干条）
+	TimestampErrors  []DiagnosticLine `json:"timestamp_errors"`  // 时间无法解析的行（前若干条）
+	LongestLines     []DiagnosticLine `json:"longest_lines"`     // 最长的若干行
+	TruncatedLines   int              `json:"truncated_lines"`   // 超过单行最大长度被截断的行数
+	TruncatedSamples []DiagnosticLine `json:"truncated_samples"` // 被截断的行（前若干条），完整内容可从原始文件读取
+}
+
+// DiagnosticLine 诊断中引用的一行日志
+type DiagnosticLine struct {
+	Line   int    `json:"line"`            // 行号
+	Length int    `json:"length"`          // 行长度（字节）
+	Text   string `json:"text"`            // 行内容（过长时截断）
+	Value  string `json:"value,omitempty"` // 相关的字段值（如无法解析的时间）
 }
 
 type LogFilter struct {
-	Levels    []string   `json:"levels"`
-	Module    string     `json:"module"`
-	Keywords  []string   `json:"keywords"`
-	StartTime *time.Time `json:"start_time"`
-	EndTime   *time.Time `json:"end_time"`
-	UseRegex  bool       `json:"use_regex"`
-	Source    string     `json:"source"`
-	Limit     int        `json:"limit"`
-	Offset    int        `json:"offset"`
+	Levels    []string          `json:"levels"`
+	MinLevel  string            `json:"min_level"` // 最低级别[标准级别，如WARN表示WARN及以上]
+	Module    string            `json:"module"`
+	Keywords  []string          `json:"keywords"`
+	StartTime *time.Time        `json:"start_time"`
+	EndTime   *time.Time        `json:"end_time"`
+	UseRegex  bool              `json:"use_regex"`
+	Source    string            `json:"source"`
+	Attrs     map[string]string `json:"attrs"` // 扩展属性过滤[键 -> 值，布尔值和null按true/false/null匹配]
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+
+	AttrRanges map[string]AttrRange `json:"attr_ranges"` // 扩展属性数值范围过滤[键 -> 范围]
+	SortBy     string               `json:"sort_by"`     // 排序字段[log_time,line,level,severity,module,process,thread,class,tag,source或attr.键]，默认按时间
+	SortDesc   bool                 `json:"sort_desc"`   // 是否降序
+
+	Scenes        []string `json:"scenes"`         // 命中任一场景的日志
+	SceneKeywords []string `json:"scene_keywords"` // 命中任一场景关键词的日志
+}
+
+// AttrRange 数值范围（闭区间），未设置的一端不限制
+type AttrRange struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// AggregateBucket 按字段分组的聚合结果，指定统计字段时附带其数值统计
+type AggregateBucket struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+	Sum   *float64    `json:"sum,omitempty"`
+	Avg   *float64    `json:"avg,omitempty"`
+	Min   *float64    `json:"min,omitempty"`
+	Max   *float64    `json:"max,omitempty"`
 }
 
 type LogStats struct {
@@ -53,10 +131,52 @@ type LogStats struct {
 }
 
 type UploadResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	FileID  string `json:"file_id,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success     bool            `json:"success"`
+	Message     string          `json:"message"`
+	FileID      string          `json:"file_id,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ProjectName string          `json:"project_name,omitempty"` // 使用的项目（多个文件时为第一个文件的项目）
+	Confidence  float64         `json:"confidence,omitempty"`   // 自动识别项目的评分[0-1]
+	Files       []UploadedFile  `json:"files,omitempty"`        // 成功解析的文件
+	Members     []ArchiveMember `json:"members,omitempty"`      // 压缩包中每个条目的处理结果
+	Scores      []RuleScore     `json:"scores,omitempty"`       // 单个文件无法识别项目时各项目规则的评分（按评分降序）
+}
+
+// RuleScore 项目规则在样本行上的评分
+type RuleScore struct {
+	ProjectName string  `json:"project_name"`
+	Score       float64 `json:"score"`           // 综合评分[0-1]
+	MatchRate   float64 `json:"match_rate"`      // 匹配到任一字段的行占比
+	FieldRate   float64 `json:"field_rate"`      // 平均每行匹配到的已配置字段占比
+	TimeRate    float64 `json:"time_rate"`       // 时间解析成功的行占比
+	Error       string  `json:"error,omitempty"` // 规则无法编译时的错误
+}
+
+// 压缩包条目的处理状态
+const (
+	ArchiveMemberExtracted = "extracted" // 已解压
+	ArchiveMemberIngested  = "ingested"  // 已解压并解析入库
+	ArchiveMemberSkipped   = "skipped"   // 已跳过（非日志文件、超过限制等）
+	ArchiveMemberRejected  = "rejected"  // 不安全被拒绝（路径越界、符号链接、压缩比异常等）
+	ArchiveMemberFailed    = "failed"    // 解压或解析失败
+)
+
+// ArchiveMember 压缩包中一个条目的处理结果
+type ArchiveMember struct {
+	Name   string `json:"name"`              // 条目路径，嵌套压缩包中的条目以 外层/内层 表示
+	Status string `json:"status"`            // 处理状态[extracted,ingested,skipped,rejected,failed]
+	Reason string `json:"reason,omitempty"`  // 跳过或失败的原因，解压成功时为识别出的类型（如嵌套压缩包）
+	FileID string `json:"file_id,omitempty"` // 解析入库后的文件ID
+	Path   string `json:"-"`                 // 解压后的文件路径
+}
+
+// UploadedFile 上传中成功解析的单个文件
+type UploadedFile struct {
+	FileID      string  `json:"file_id"`
+	Name        string  `json:"name"`
+	ProjectName string  `json:"project_name"`
+	Confidence  float64 `json:"confidence,omitempty"` // 自动识别项目的评分[0-1]
+	Encoding    string  `json:"encoding"`             // 文件编码
 }
 
 type LogResponse struct {
@@ -0,0 +1,97 @@
+package model
+
+import "time"
+
+// KeywordHits 场景关键词在若干文件中的命中统计
+type KeywordHits struct {
+	Keyword string    // 关键词
+	Count   int       // 命中条数
+	FirstAt time.Time // 首次出现时间
+	LastAt  time.Time // 最后出现时间
+	FileID  string    // 首次出现的文件
+	Line    int       // 首次出现的行号
+	Message string    // 首次出现的日志消息
+}
+
+// SceneStep 场景时间线中的一步，对应场景中的一个关键词
+type SceneStep struct {
+	Index         int        `json:"index"`                       // 在场景中定义的顺序（从0开始）
+	Keyword       string     `json:"keyword"`                     // 关键词
+	Desc          string     `json:"desc"`                        // 关键词描述
+	Color         string     `json:"color,omitempty"`             // 关键词颜色
+	Missing       bool       `json:"missing"`                     // 是否未出现
+	Count         int        `json:"count"`                       // 命中条数
+	FirstAt       *time.Time `json:"first_at,omitempty"`          // 首次出现时间
+	LastAt        *time.Time `json:"last_at,omitempty"`           // 最后出现时间
+	FileID        string     `json:"file_id,omitempty"`           // 首次出现的文件
+	Line          int        `json:"line,omitempty"`              // 首次出现的行号
+	Message       string     `json:"message,omitempty"`           // 首次出现的日志消息
+	SincePrevious *int64     `json:"since_previous_ms,omitempty"` // 距上一步首次出现的毫秒数，第一步为空
+}
+
+// SceneTimeline 场景时间线，已出现的步骤按首次出现时间排序，未出现的步骤按定义顺序排在最后
+type SceneTimeline struct {
+	Project  string      `json:"project"`               // 项目名称
+	Module   string      `json:"module"`                // 场景所属模块
+	Scene    string      `json:"scene"`                 // 场景名称
+	FileIDs  []string    `json:"file_ids"`              // 参与统计的文件
+	Steps    []SceneStep `json:"steps"`                 // 步骤
+	Found    int         `json:"found"`                 // 已出现的步骤数
+	Missing  int         `json:"missing"`               // 未出现的步骤数
+	Duration *int64      `json:"duration_ms,omitempty"` // 第一步到最后一步首次出现的毫秒数
+}
+
+// SceneHit 场景关键词的一次命中，用于计算顺序规则
+type SceneHit struct {
+	Keyword string    // 关键词
+	Time    time.Time // 日志时间
+	Line    int       // 日志行号
+	Content string    // 日志内容
+}
+
+// 顺序流程的结果
+const (
+	SequenceCompleted  = "completed"    // 按顺序完成
+	SequenceMissing    = "missing"      // 缺少步骤（如未收到应答）
+	SequenceTimeout    = "timeout"      // 步骤间隔或整个流程超时
+	SequenceOutOfOrder = "out_of_order" // 步骤乱序（如未发送就收到应答）
+)
+
+// SequenceStep 流程中已出现的一步
+type SequenceStep struct {
+	Index   int       `json:"index"`                // 在场景中定义的顺序（从0开始）
+	Keyword string    `json:"keyword"`              // 关键词
+	Time    time.Time `json:"time"`                 // 日志时间
+	Line    int       `json:"line"`                 // 日志行号
+	Latency *int64    `json:"latency_ms,omitempty"` // 距上一步的毫秒数，第一步为空
+}
+
+// SequenceRun 一次流程（关联键相同的一组步骤）
+type SequenceRun struct {
+	Key      string         `json:"key,omitempty"`    // 关联键
+	Status   string         `json:"status"`           // 结果[completed,missing,timeout,out_of_order]
+	Reason   string         `json:"reason,omitempty"` // 未完成的原因
+	Steps    []SequenceStep `json:"steps"`            // 已出现的步骤
+	Duration int64          `json:"duration_ms"`      // 第一步到最后一步的毫秒数
+}
+
+// LatencyStats 耗时统计（毫秒）
+type LatencyStats struct {
+	Min int64   `json:"min"`
+	Max int64   `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+// SequenceReport 单个文件中场景顺序规则的检查结果
+type SequenceReport struct {
+	FileID    string                  `json:"file_id"`           // 文件ID
+	Project   string                  `json:"project"`           // 项目名称
+	Module    string                  `json:"module"`            // 场景所属模块
+	Scene     string                  `json:"scene"`             // 场景名称
+	Total     int                     `json:"total"`             // 流程总数
+	Completed int                     `json:"completed"`         // 完成的流程数
+	Counts    map[string]int          `json:"counts"`            // 各结果的流程数
+	Latency   *LatencyStats           `json:"latency,omitempty"` // 完成流程的总耗时统计
+	Steps     map[string]LatencyStats `json:"steps,omitempty"`   // 完成流程中各步骤距上一步的耗时统计[关键词 -> 统计]
+	Runs      []SequenceRun           `json:"runs"`              // 流程明细，按开始时间排序
+}
@@ -0,0 +1,229 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// 全文检索的排序方式
+const (
+	SearchOrderRank     = "rank"      // 按相关度（默认）
+	SearchOrderTime     = "time"      // 按时间升序
+	SearchOrderTimeDesc = "time_desc" // 按时间降序
+)
+
+// SearchOrders 支持的排序方式
+var SearchOrders = []string{SearchOrderRank, SearchOrderTime, SearchOrderTimeDesc}
+
+// ErrSearchQuery 检索表达式不符合FTS5语法
+var ErrSearchQuery = errors.New("检索表达式错误")
+
+// SearchQuery 全文检索条件
+type SearchQuery struct {
+	// FTS5检索表达式：短语 "a b"、前缀 abc*、布尔 AND/OR/NOT、列限定 message:abc；
+	// trigram分词下每个词至少3个字符，不含FTS5语法的检索存在较短的词时改为逐行匹配
+	Query  string `json:"q"`
+	Order  string `json:"order"`  // 排序方式[rank,time,time_desc]，为空时按相关度
+	Limit  int    `json:"limit"`  // 返回条数
+	Offset int    `json:"offset"` // 跳过条数
+}
+
+// MatchOffset 命中词在字段中的位置
+type MatchOffset struct {
+	Field  string `json:"field"`  // 字段[message,content]
+	Start  int    `json:"start"`  // 起始位置（字符）
+	Length int    `json:"length"` // 长度（字符）
+}
+
+// SearchHit 全文检索命中的日志条目
+type SearchHit struct {
+	LogEntry
+	Rank      float64       `json:"rank"`      // 相关度[bm25，越小越相关]
+	Snippet   string        `json:"snippet"`   // 命中位置附近的摘要，命中词以<mark>标记，其余文本已做HTML转义
+	Highlight string        `json:"highlight"` // 完整的消息，命中词以<mark>标记，其余文本已做HTML转义
+	Offsets   []MatchOffset `json:"offsets"`   // 消息和原始内容中的命中位置
+}
+
+// searchError 将FTS5语法错误（含未闭合的引号、不存在的列）转换为 ErrSearchQuery
+func searchError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "fts5:") || strings.Contains(msg, "unterminated string") || strings.Contains(msg, "no such column") {
+		return fmt.Errorf("%w: %v", ErrSearchQuery, err)
+	}
+	return fmt.Errorf("搜索日志失败: %w", err)
+}
+
+// 检索结果中标记命中词的控制字符，日志文本中基本不会出现，转换为<mark>或位置后去除
+const (
+	matchOpen  = "\x02"
+	matchClose = "\x03"
+)
+
+// markHTML 将带控制字符标记的文本转换为HTML：转义文本，命中词以<mark>包裹
+func markHTML(marked string) string {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(marked, matchOpen+matchClose)
+		if i < 0 {
+			break
+		}
+		b.WriteString(html.EscapeString(marked[:i]))
+		if marked[i:i+1] == matchOpen && !open {
+			b.WriteString("<mark>")
+			open = true
+		} else if marked[i:i+1] == matchClose && open {
+			b.WriteString("</mark>")
+			open = false
+		}
+		marked = marked[i+1:]
+	}
+	b.WriteString(html.EscapeString(marked))
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
+
+// matchOffsets 计算带控制字符标记的文本中各命中词去除标记后的位置
+func matchOffsets(field, marked string) []MatchOffset {
+	var offsets []MatchOffset
+	pos, start := 0, -1
+	for marked != "" {
+		i := strings.IndexAny(marked, matchOpen+matchClose)
+		if i < 0 {
+			break
+		}
+		pos += utf8.RuneCountInString(marked[:i])
+		if marked[i:i+1] == matchOpen {
+			start = pos
+		} else if start >= 0 {
+			offsets = append(offsets, MatchOffset{Field: field, Start: start, Length: pos - start})
+			start = -1
+		}
+		marked = marked[i+1:]
+	}
+	return offsets
+}
+
+// ftsPhrase 将关键词转换为只匹配原始内容的FTS5短语，trigram分词下与 LIKE '%关键词%' 等价
+func ftsPhrase(keyword string) string {
+	return `content : "` + strings.ReplaceAll(keyword, `"`, `""`) + `"`
+}
+
+// ftsUsable 关键词是否可以走全文索引，trigram分词要求至少3个字符
+func ftsUsable(keyword string) bool {
+	return utf8.RuneCountInString(keyword) >= 3
+}
+
+// ftsOperators FTS5检索表达式中的运算符
+var ftsOperators = map[string]bool{"AND": true, "OR": true, "NOT": true, "NEAR": true}
+
+// shortTerm 返回检索表达式中不足3个字符、trigram分词无法命中的第一个检索词，没有时返回空字符串
+func shortTerm(query string) string {
+	var terms []string
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			// 引号内的短语整体按trigram匹配
+			terms = append(terms, part)
+			continue
+		}
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool {
+			return r == '(' || r == ')' || r == ' ' || r == '\t' || r == '\n'
+		}) {
+			if ftsOperators[word] {
+				continue
+			}
+			if i := strings.LastIndexByte(word, ':'); i >= 0 {
+				word = word[i+1:]
+			}
+			terms = append(terms, strings.TrimRight(strings.TrimLeft(word, "^+"), "*"))
+		}
+	}
+	for _, term := range terms {
+		if term != "" && !ftsUsable(term) {
+			return term
+		}
+	}
+	return ""
+}
+
+// plainTerms 拆分不含FTS5语法的检索表达式，含引号、括号、前缀、列限定或布尔运算符时返回false
+func plainTerms(query string) ([]string, bool) {
+	terms := strings.Fields(query)
+	for _, term := range terms {
+		if ftsOperators[term] || strings.ContainsAny(term, `"()*:^+{}`) {
+			return nil, false
+		}
+	}
+	return terms, true
+}
+
+// likePattern 将关键词转换为 LIKE 子串匹配模式，转义通配符
+func likePattern(keyword string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(keyword) + "%"
+}
+
+// asciiLower 只转换ASCII字母的小写，字节长度不变，与SQLite LIKE的大小写规则一致
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// markTerms 以控制字符标记文本中的关键词（ASCII字母不区分大小写），重叠时取最先出现、最长的关键词
+func markTerms(text string, terms []string) string {
+	lower := asciiLower(text)
+	lowerTerms := make([]string, len(terms))
+	for i, term := range terms {
+		lowerTerms[i] = asciiLower(term)
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		n := 0
+		for _, term := range lowerTerms {
+			if len(term) > n && strings.HasPrefix(lower[i:], term) {
+				n = len(term)
+			}
+		}
+		if n == 0 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteString(text[i : i+size])
+			i += size
+			continue
+		}
+		b.WriteString(matchOpen + text[i:i+n] + matchClose)
+		i += n
+	}
+	return b.String()
+}
+
+// 逐行匹配时摘要的长度和第一个命中词之前保留的字符数，与全文索引的snippet大致相当
+const (
+	snippetLength  = 64
+	snippetContext = 16
+)
+
+// markedSnippet 截取带控制字符标记的文本中第一个命中词附近的摘要
+func markedSnippet(marked string) string {
+	runes := []rune(marked)
+	first := slices.Index(runes, rune(matchOpen[0]))
+	start := max(0, first-snippetContext)
+	end := min(len(runes), start+snippetLength)
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
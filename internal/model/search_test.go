@@ -0,0 +1,196 @@
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// searchIDs 检索并返回命中条目的ID
+func searchIDs(t *testing.T, db *Database, fileID, q string) []string {
+	t.Helper()
+	hits, err := db.SearchLogs(fileID, SearchQuery{Query: q, Order: SearchOrderTime, Limit: 100})
+	if err != nil {
+		t.Fatalf("SearchLogs(%q): %v", q, err)
+	}
+	ids := []string{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestSearchLogsSync(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	saveTestLog(t, db, "f1", testEntry("f1", 0, "INFO", "connect timeout to server"))
+	saveTestLog(t, db, "f2", testEntry("f2", 0, "INFO", "read timeout"))
+
+	// 插入时同步索引
+	if err := db.InsertLogEntries("f1", []LogEntry{testEntry("f1", 1, "WARN", "retry after timeout")}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, db, "f1", "timeout"); !reflect.DeepEqual(ids, []string{"f1_0", "f1_1"}) {
+		t.Errorf("f1 timeout = %v", ids)
+	}
+	if ids := searchIDs(t, db, "f1,f2", "timeout"); len(ids) != 3 {
+		t.Errorf("f1,f2 timeout = %v", ids)
+	}
+
+	// 删除文件条目时同步删除索引
+	if err := db.DeleteLogFileEntry("f1"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, db, "f1,f2", "timeout"); !reflect.DeepEqual(ids, []string{"f2_0"}) {
+		t.Errorf("删除后 timeout = %v", ids)
+	}
+	var indexed int
+	if err := db.db.QueryRow("SELECT COUNT(*) FROM log_entries_fts WHERE log_entries_fts MATCH 'timeout'").Scan(&indexed); err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 1 {
+		t.Errorf("索引中的条目 = %d, want 1", indexed)
+	}
+}
+
+func TestSearchLogsRebuild(t *testing.T) {
+	// 升级前写入的条目在创建索引时重建
+	path := createLegacyDatabase(t, [][2]string{{"E", "设备重启完成"}, {"I", "connect timeout"}})
+	db := openTestDatabase(t, path)
+	if ids := searchIDs(t, db, "f1", "重启完成"); !reflect.DeepEqual(ids, []string{"f1_a"}) {
+		t.Errorf("重启完成 = %v", ids)
+	}
+	if ids := searchIDs(t, db, "f1", "timeout"); !reflect.DeepEqual(ids, []string{"f1_b"}) {
+		t.Errorf("timeout = %v", ids)
+	}
+
+	// 再次打开不重复写入索引
+	db.Close()
+	db = openTestDatabase(t, path)
+	if ids := searchIDs(t, db, "f1", "timeout"); !reflect.DeepEqual(ids, []string{"f1_b"}) {
+		t.Errorf("重新打开后 timeout = %v", ids)
+	}
+}
+
+func TestSearchLogsQueries(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	saveTestLog(t, db, "f1",
+		testEntry("f1", 0, "INFO", "connect timeout to server"),
+		testEntry("f1", 1, "ERROR", "Connection refused"),
+		testEntry("f1", 2, "WARN", "设备重启完成"),
+		testEntry("f1", 3, "INFO", "timeout: connect 100%_done"),
+	)
+
+	tests := map[string][]string{
+		`"connect timeout"`:               {"f1_0"},
+		`conn*`:                           {"f1_0", "f1_1", "f1_3"},
+		`connect NOT refused`:             {"f1_0", "f1_3"},
+		`refused OR 重启完成`:                 {"f1_1", "f1_2"},
+		`(timeout AND server) OR refused`: {"f1_0", "f1_1"},
+		`message:ERROR`:                   {},
+		`content:ERROR`:                   {"f1_1"},
+		// 不足3个字符的关键词逐行匹配，多个关键词须全部包含，通配符按字面匹配
+		`重启`:        {"f1_2"},
+		`to server`: {"f1_0"},
+		`%_`:        {"f1_3"},
+	}
+	for q, want := range tests {
+		hits, err := db.SearchLogs("f1", SearchQuery{Query: q, Order: SearchOrderTime, Limit: 100})
+		if err != nil {
+			t.Errorf("SearchLogs(%q): %v", q, err)
+			continue
+		}
+		ids := []string{}
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("SearchLogs(%q) = %v, want %v", q, ids, want)
+		}
+	}
+
+	for _, q := range []string{`"connect`, `timeout AND`, `reason:0x12`, `"重启" OR timeout`, `ab*`} {
+		if _, err := db.SearchLogs("f1", SearchQuery{Query: q, Limit: 100}); !errors.Is(err, ErrSearchQuery) {
+			t.Errorf("SearchLogs(%q) err = %v, want ErrSearchQuery", q, err)
+		}
+	}
+}
+
+func TestSearchLogsMarks(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "log.db"))
+	saveTestLog(t, db, "f1", testEntry("f1", 0, "WARN", "设备<1>重启完成，重启次数2"))
+
+	// 全文索引
+	hits, err := db.SearchLogs("f1", SearchQuery{Query: "重启完成", Limit: 10})
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("hits = %v, %v", hits, err)
+	}
+	hit := hits[0]
+	if want := "设备&lt;1&gt;<mark>重启完成</mark>，重启次数2"; hit.Highlight != want {
+		t.Errorf("highlight = %q, want %q", hit.Highlight, want)
+	}
+	if want := "设备&lt;1&gt;<mark>重启完成</mark>，重启次数2"; hit.Snippet != want {
+		t.Errorf("snippet = %q, want %q", hit.Snippet, want)
+	}
+	want := []MatchOffset{{Field: "message", Start: 5, Length: 4}, {Field: "content", Start: 21, Length: 4}}
+	if !reflect.DeepEqual(hit.Offsets, want) {
+		t.Errorf("offsets = %+v, want %+v", hit.Offsets, want)
+	}
+
+	// 逐行匹配
+	hits, err = db.SearchLogs("f1", SearchQuery{Query: "重启 warn", Limit: 10})
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("hits = %v, %v", hits, err)
+	}
+	hit = hits[0]
+	if want := "设备&lt;1&gt;<mark>重启</mark>完成，<mark>重启</mark>次数2"; hit.Highlight != want {
+		t.Errorf("highlight = %q, want %q", hit.Highlight, want)
+	}
+	want = []MatchOffset{
+		{Field: "message", Start: 5, Length: 2}, {Field: "message", Start: 10, Length: 2},
+		{Field: "content", Start: 11, Length: 4}, {Field: "content", Start: 21, Length: 2}, {Field: "content", Start: 26, Length: 2},
+	}
+	if !reflect.DeepEqual(hit.Offsets, want) {
+		t.Errorf("offsets = %+v, want %+v", hit.Offsets, want)
+	}
+}
+
+func TestMarkHTML(t *testing.T) {
+	tests := map[string]string{
+		"plain <a>":                  "plain &lt;a&gt;",
+		"a \x02b&c\x03 d":            "a <mark>b&amp;c</mark> d",
+		"\x02设备\x03重启\x02完成\x03":     "<mark>设备</mark>重启<mark>完成</mark>",
+		"stray\x03 \x02open":         "stray <mark>open</mark>",
+		"\x02nested \x02x\x03\x03 y": "<mark>nested x</mark> y",
+	}
+	for marked, want := range tests {
+		if got := markHTML(marked); got != want {
+			t.Errorf("markHTML(%q) = %q, want %q", marked, got, want)
+		}
+	}
+}
+
+func TestMatchOffsets(t *testing.T) {
+	got := matchOffsets("message", "设备\x02重启\x03后\x02ok\x03，\x02未闭合")
+	want := []MatchOffset{{Field: "message", Start: 2, Length: 2}, {Field: "message", Start: 5, Length: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("offsets = %+v, want %+v", got, want)
+	}
+	if got := matchOffsets("content", "no match"); got != nil {
+		t.Errorf("offsets = %+v", got)
+	}
+}
+
+func TestMarkTerms(t *testing.T) {
+	got := markTerms("Reset 复位 RESET resetting", []string{"reset", "复位", "resetti"})
+	want := "\x02Reset\x03 \x02复位\x03 \x02RESET\x03 \x02resetti\x03ng"
+	if got != want {
+		t.Errorf("markTerms = %q, want %q", got, want)
+	}
+
+	long := "0123456789012345678901234567890123456789复位" + "abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"
+	snippet := markHTML(markedSnippet(markTerms(long, []string{"复位"})))
+	if want := "…4567890123456789<mark>复位</mark>abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqr…"; snippet != want {
+		t.Errorf("snippet = %q, want %q", snippet, want)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"regexp"
+	"strings"
+)
+
+// sceneKeyword 编译后的场景关键词
+type sceneKeyword struct {
+	module  string
+	scene   string
+	keyword config.LogProjectKeyword
+	re      *regexp.Regexp // regular模式的正则，word模式为nil
+}
+
+func (k *sceneKeyword) match(content string) bool {
+	if k.re != nil {
+		return k.re.MatchString(content)
+	}
+	return strings.Contains(content, k.keyword.Keyword)
+}
+
+// annotator 按项目的 模块→场景→关键词 定义标注日志条目，创建后只读
+type annotator struct {
+	keywords []sceneKeyword
+}
+
+// compileAnnotator 预编译项目的场景关键词，没有关键词时返回nil
+func compileAnnotator(modules []config.LogProjectModule) (*annotator, error) {
+	var keywords []sceneKeyword
+	for _, m := range modules {
+		for _, scene := range m.Scenes {
+			for _, k := range scene.Keywords {
+				if k.Keyword == "" {
+					continue
+				}
+				sk := sceneKeyword{module: m.Name, scene: scene.Name, keyword: k}
+				if k.Mode == config.KeywordModeRegular {
+					re, err := regexp.Compile(k.Keyword)
+					if err != nil {
+						return nil, fmt.Errorf("场景[%s]关键词[%s]正则错误: %w", scene.Name, k.Keyword, err)
+					}
+					sk.re = re
+				}
+				keywords = append(keywords, sk)
+			}
+		}
+	}
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+	return &annotator{keywords: keywords}, nil
+}
+
+// annotate 用条目的完整内容（含多行记录的续行）匹配所有关键词，命中的记录到条目的标注中，
+// 条目颜色改为第一个设置了颜色的命中关键词的颜色
+func (a *annotator) annotate(entry *model.LogEntry) {
+	for i := range a.keywords {
+		k := &a.keywords[i]
+		if !k.match(entry.Content) {
+			continue
+		}
+		entry.Annotations = append(entry.Annotations, model.LogAnnotation{
+			EntryID: entry.ID,
+			Line:    entry.Line,
+			Module:  k.module,
+			Scene:   k.scene,
+			Keyword: k.keyword.Keyword,
+			Desc:    k.keyword.Desc,
+			Color:   k.keyword.Color,
+		})
+	}
+	for _, an := range entry.Annotations {
+		if an.Color != "" {
+			entry.Color = an.Color
+			break
+		}
+	}
+}
+
+// WithProject 返回按项目场景关键词标注条目的解析器副本，解析出的文件记录该项目名称
+func (p *LogParser) WithProject(project *config.LogProjectRule) (*LogParser, error) {
+	parser := *p
+	parser.project = project.ProjectName
+	annotator, err := compileAnnotator(project.Modules)
+	if err != nil {
+		return nil, err
+	}
+	parser.annotator = annotator
+	return &parser, nil
+}
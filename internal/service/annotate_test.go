@@ -0,0 +1,78 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnnotateScenes(t *testing.T) {
+	content := "2024-08-02 15:56:24 INFO 系统启动\n" +
+		"2024-08-02 15:56:25 ERROR 看门狗复位 reason=0x12\n" +
+		"  at watchdog.c:88\n" +
+		"2024-08-02 15:56:26 WARN 电压过低 12.1V\n"
+	path := filepath.Join(t.TempDir(), "scene.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project := &config.LogProjectRule{
+		ProjectName: "demo",
+		Rule: config.LogParseRule{
+			Level:       `^\S+ \S+ (\w+)`,
+			Message:     `^\S+ \S+ \w+ (.*)$`,
+			RecordStart: `^\d{4}-`,
+		},
+		Modules: []config.LogProjectModule{{
+			Name: "电源",
+			Scenes: []config.LogProjectScene{{
+				Name: "重启原因",
+				Keywords: []config.LogProjectKeyword{
+					{Keyword: "看门狗复位", Desc: "看门狗触发重启", Color: "#ff0000"},
+					{Keyword: `watchdog\.c:\d+`, Desc: "复位位置", Mode: config.KeywordModeRegular},
+					{Keyword: `电压过低 \d+`, Desc: "低压", Mode: config.KeywordModeRegular, Color: "#ffa500"},
+				},
+			}},
+		}},
+	}
+	if err := project.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &project.Rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parser, err = parser.WithProject(project); err != nil {
+		t.Fatal(err)
+	}
+	// 副本保留场景关键词
+	if parser, err = parser.WithEncoding("utf-8"); err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logFile.Project != "demo" || len(logFile.Entries) != 3 {
+		t.Fatalf("project = %s, entries = %d", logFile.Project, len(logFile.Entries))
+	}
+	if got := logFile.Entries[0].Annotations; len(got) != 0 {
+		t.Errorf("entry 0 annotations = %+v", got)
+	}
+	// 续行中的关键词同样命中，颜色取第一个有颜色的关键词
+	reboot := logFile.Entries[1]
+	if len(reboot.Annotations) != 2 || reboot.Color != "#ff0000" {
+		t.Fatalf("entry 1 = %s %+v", reboot.Color, reboot.Annotations)
+	}
+	if a := reboot.Annotations[1]; a.Scene != "重启原因" || a.Module != "电源" || a.Desc != "复位位置" || a.EntryID != reboot.ID || a.Line != 2 {
+		t.Errorf("annotation = %+v", a)
+	}
+	if low := logFile.Entries[2]; len(low.Annotations) != 1 || low.Color != "#ffa500" {
+		t.Errorf("entry 2 = %s %+v", low.Color, low.Annotations)
+	}
+
+	project.Modules[0].Scenes[0].Keywords[1].Keyword = "("
+	if err := project.Validate(); err == nil {
+		t.Error("错误的关键词正则应校验失败")
+	}
+}
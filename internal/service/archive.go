@@ -0,0 +1,452 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"log-tools-go/pkg/xcharset"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mholt/archiver/v4"
+)
+
+// archiveExts 支持解压的压缩包扩展名
+var archiveExts = []string{
+	".zip", ".rar", ".7z", ".tar",
+	".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz", ".tar.zst", ".tzst",
+}
+
+// logExts 日志文件扩展名，轮转后缀（如 .1）去掉后判断
+var logExts = []string{".txt", ".log", ".out"}
+
+// rotationSuffix 日志轮转的数字后缀，如 app.log.1、messages.2
+var rotationSuffix = regexp.MustCompile(`\.\d+$`)
+
+// sniffSize 按内容识别文件类型时读取的字节数
+const sniffSize = 8 * 1024
+
+// compression 单文件压缩格式，解析时按文件头识别并流式解压
+type compression struct {
+	ext    string
+	magic  []byte
+	format archiver.Decompressor
+}
+
+var compressions = []compression{
+	{".gz", []byte{0x1F, 0x8B}, archiver.Gz{}},
+	{".bz2", []byte("BZh"), archiver.Bz2{}},
+	{".xz", []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}, archiver.Xz{}},
+	{".zst", []byte{0x28, 0xB5, 0x2F, 0xFD}, archiver.Zstd{}},
+}
+
+// fileExt 返回小写的扩展名，.tar.gz 等复合扩展名整体返回
+func fileExt(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExts {
+		if strings.HasPrefix(ext, ".tar.") && strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return filepath.Ext(lower)
+}
+
+// isLogFileName 按扩展名判断是否为日志文件，单文件压缩的日志（如 app.log.gz、app.bz2）去掉压缩扩展名后判断，
+// 轮转的日志（如 app.log.1、app.log.2.gz）去掉数字后缀后判断
+func isLogFileName(name string) bool {
+	lower := strings.ToLower(name)
+	ext := fileExt(lower)
+	for _, c := range compressions {
+		if ext == c.ext {
+			inner := filepath.Ext(rotationSuffix.ReplaceAllString(strings.TrimSuffix(lower, ext), ""))
+			return inner == "" || slices.Contains(logExts, inner)
+		}
+	}
+	return slices.Contains(logExts, filepath.Ext(rotationSuffix.ReplaceAllString(lower, "")))
+}
+
+// compressionOf 按文件头识别单文件压缩格式，未压缩时返回nil
+func compressionOf(head []byte) *compression {
+	for i := range compressions {
+		if bytes.HasPrefix(head, compressions[i].magic) {
+			return &compressions[i]
+		}
+	}
+	return nil
+}
+
+// sniffLog 按内容判断是否为日志（文本）文件，单文件压缩的按解压后的内容判断，不是时返回原因
+func sniffLog(r io.Reader) (bool, string) {
+	reader, closer, err := decompress(r)
+	if err != nil {
+		return false, err.Error()
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	sample := make([]byte, sniffSize)
+	n, err := io.ReadFull(reader, sample)
+	if n == 0 {
+		if err != nil && err != io.EOF {
+			return false, fmt.Sprintf("读取文件失败: %v", err)
+		}
+		return false, "空文件"
+	}
+	if !xcharset.IsText(sample[:n]) {
+		return false, "二进制文件"
+	}
+	return true, ""
+}
+
+// decompress 按文件头识别单文件压缩格式并返回流式解压的reader，未压缩时原样返回，closer为nil
+func decompress(r io.Reader) (io.Reader, io.Closer, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(8)
+	if c := compressionOf(head); c != nil {
+		rc, err := c.format.OpenReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("解压%s文件失败: %w", strings.TrimPrefix(c.ext, "."), err)
+		}
+		return rc, rc, nil
+	}
+	return br, nil, nil
+}
+
+// identifyArchive 识别压缩包格式，不是压缩包（包括单文件压缩）时返回nil
+// stream 不可Seek时需改用返回的reader继续读取
+func identifyArchive(name string, stream io.Reader) (archiver.Extractor, io.Reader) {
+	format, reader, err := archiver.Identify(context.Background(), name, stream)
+	if err != nil {
+		return nil, reader
+	}
+	extractor, ok := format.(archiver.Extractor)
+	if !ok {
+		return nil, reader
+	}
+	return extractor, reader
+}
+
+// IsArchive 判断文件是否为支持解压的压缩包
+func IsArchive(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	extractor, _ := identifyArchive(filepath.Base(filePath), file)
+	return extractor != nil
+}
+
+// ratioFloor 压缩比检查的起始大小，解压量低于该值时不检查压缩比，避免误判压缩率很高的小文件
+const ratioFloor = 1 << 20
+
+// extraction 一次上传的解压过程，嵌套压缩包共享解压总量、文件数的计数
+type extraction struct {
+	limits   config.ExtractLimits
+	maxDepth int
+	bytes    int64  // 已写出的字节数
+	files    int    // 已写出的文件数
+	stopped  string // 达到总量限制的原因，之后的条目全部跳过
+	rule     *config.LogParseRule
+	members  []model.ArchiveMember
+}
+
+// ExtractArchive 解压压缩包（zip、rar、7z、tar 及 tar.gz/tgz/tar.bz2/tar.xz/tar.zst），返回每个条目的处理结果，
+// 状态为 extracted 且 Path 不为空的条目为解压出的日志文件
+// 条目按内容识别：文本文件（含单文件压缩、轮转后缀、无扩展名的如 messages、kmsg）解压，二进制文件跳过；
+// rule 不为nil时按其include/exclude筛选
+// 包内嵌套的压缩包递归解压，深度不超过配置的上限；单文件压缩的日志保持原样，解析时流式解压
+// 越界路径、符号链接等条目被拒绝，解压总大小、文件数和压缩比超过配置的限制时停止写出，均记录在结果中
+func (s *StorageService) ExtractArchive(archivePath string, rule *config.LogParseRule) ([]model.ArchiveMember, error) {
+	if err := os.MkdirAll(s.config.Storage.UploadDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	extractDir, err := os.MkdirTemp(s.config.Storage.UploadDir, "extracted_"+time.Now().Format("20060102_150405")+"_")
+	if err != nil {
+		return nil, fmt.Errorf("创建解压目录失败: %w", err)
+	}
+	ex := &extraction{limits: s.config.ExtractLimits(), maxDepth: s.config.ArchiveDepth(), rule: rule}
+	if err := ex.extract(archivePath, extractDir, "", 1); err != nil {
+		return nil, err
+	}
+	return ex.members, nil
+}
+
+// extract 解压单个压缩包到指定目录，prefix 为嵌套压缩包在外层中的路径，depth 为当前嵌套层数（从1开始）
+func (ex *extraction) extract(archivePath, extractDir, prefix string, depth int) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("打开压缩文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("获取文件信息失败: %w", err)
+	}
+
+	extractor, _ := identifyArchive(filepath.Base(archivePath), file)
+	if extractor == nil {
+		return fmt.Errorf("识别压缩文件格式失败: %s", filepath.Base(archivePath))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("重置文件指针失败: %w", err)
+	}
+
+	// 整个压缩包的解压量按压缩包大小和压缩比限制
+	budget := max(ratioFloor, int64(float64(info.Size())*ex.limits.MaxRatio))
+	var written int64
+	var ratioExceeded string
+
+	var nested []int // 嵌套压缩包在 members 中的下标
+	err = extractor.Extract(context.Background(), file, func(ctx context.Context, f archiver.FileInfo) error {
+		// 只处理文件，跳过目录
+		if f.IsDir() {
+			return nil
+		}
+		member := entryResult{ArchiveMember: model.ArchiveMember{Name: prefix + f.NameInArchive}}
+		if ratioExceeded != "" {
+			// 出现压缩比异常的条目后，整个压缩包不再继续解压
+			member.Status, member.Reason = model.ArchiveMemberSkipped, ratioExceeded
+		} else {
+			member.Status, member.Reason = ex.extractEntry(f, extractDir, depth, &member, budget-written)
+		}
+		switch {
+		case member.Status == model.ArchiveMemberExtracted:
+			written += member.size
+			if member.nested {
+				nested = append(nested, len(ex.members))
+			}
+		case member.ratio:
+			ratioExceeded = member.Reason
+		}
+		ex.members = append(ex.members, member.ArchiveMember)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("解压文件失败: %w", err)
+	}
+
+	for _, i := range nested {
+		path := ex.members[i].Path
+		ex.members[i].Path = ""
+		err := ex.extract(path, path+"_extracted", ex.members[i].Name+"/", depth+1)
+		// 嵌套压缩包解压后删除，不再计入解压总量
+		if fi, statErr := os.Stat(path); statErr == nil {
+			ex.bytes -= fi.Size()
+			ex.files--
+		}
+		os.Remove(path)
+		if err != nil {
+			ex.members[i].Status, ex.members[i].Reason = model.ArchiveMemberFailed, err.Error()
+		}
+	}
+	return nil
+}
+
+// entryResult 单个条目的解压结果
+type entryResult struct {
+	model.ArchiveMember
+	size   int64 // 写出的字节数
+	nested bool  // 是否为嵌套的压缩包
+	ratio  bool  // 是否因压缩比超限被拒绝
+}
+
+// extractEntry 校验并写出单个条目，budget 为所在压缩包按压缩比剩余的可解压字节数
+func (ex *extraction) extractEntry(f archiver.FileInfo, extractDir string, depth int, result *entryResult, budget int64) (string, string) {
+	if ex.stopped != "" {
+		return model.ArchiveMemberSkipped, ex.stopped
+	}
+	if f.LinkTarget != "" || f.Mode()&fs.ModeSymlink != 0 {
+		return model.ArchiveMemberRejected, "不支持符号链接或硬链接: " + f.LinkTarget
+	}
+	if !f.Mode().IsRegular() {
+		return model.ArchiveMemberRejected, "不是普通文件"
+	}
+	rel, err := sanitizeEntryPath(f.NameInArchive)
+	if err != nil {
+		return model.ArchiveMemberRejected, err.Error()
+	}
+	target := filepath.Join(extractDir, rel)
+	if !strings.HasPrefix(target, filepath.Clean(extractDir)+string(os.PathSeparator)) {
+		return model.ArchiveMemberRejected, "路径超出解压目录"
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return model.ArchiveMemberFailed, fmt.Sprintf("打开压缩文件中的文件失败: %v", err)
+	}
+	defer src.Close()
+
+	// 嵌套的压缩包先写出，整个压缩包遍历完成后再递归解压
+	inner, reader := identifyArchive(f.Name(), src)
+	if inner != nil && depth >= ex.maxDepth {
+		return model.ArchiveMemberSkipped, fmt.Sprintf("嵌套层数超过 %d", ex.maxDepth)
+	}
+	// 单文件压缩的日志需写出后才能解压识别，未压缩的写出前识别
+	var compressed *compression
+	if inner == nil {
+		if ok, reason := ex.rule.MatchMember(result.Name); !ok {
+			return model.ArchiveMemberSkipped, reason
+		}
+		br := bufio.NewReaderSize(reader, sniffSize)
+		head, _ := br.Peek(sniffSize)
+		if compressed = compressionOf(head); compressed == nil {
+			if ok, reason := sniffLog(bytes.NewReader(head)); !ok {
+				return model.ArchiveMemberSkipped, reason
+			}
+		}
+		reader = br
+	}
+	if ex.files >= ex.limits.MaxFiles {
+		ex.stopped = fmt.Sprintf("解压文件数超过限制 %d", ex.limits.MaxFiles)
+		return model.ArchiveMemberSkipped, ex.stopped
+	}
+
+	// 可写出的字节数取解压总量、压缩包压缩比、条目压缩比（zip记录了每个条目的压缩大小）限制中最小的一个
+	limit, reason := ex.limits.MaxSize-ex.bytes, fmt.Sprintf("解压总大小超过限制 %d 字节", ex.limits.MaxSize)
+	ratioReason := fmt.Sprintf("压缩比超过限制 %g，疑似压缩炸弹", ex.limits.MaxRatio)
+	if budget < limit {
+		limit, reason = budget, ratioReason
+	}
+	if header, ok := f.Header.(zip.FileHeader); ok {
+		if entryBudget := max(ratioFloor, int64(float64(header.CompressedSize64)*ex.limits.MaxRatio)); entryBudget < limit {
+			limit, reason = entryBudget, ratioReason
+		}
+	}
+	exceeded := func() (string, string) {
+		if reason == ratioReason {
+			result.ratio = true
+		} else {
+			ex.stopped = reason
+		}
+		return model.ArchiveMemberRejected, reason
+	}
+	if f.Size() > limit {
+		return exceeded()
+	}
+
+	n, err := writeFile(target, reader, limit)
+	ex.bytes += n
+	if err != nil || n > limit {
+		os.Remove(target)
+		ex.bytes -= n
+		if err != nil {
+			return model.ArchiveMemberFailed, err.Error()
+		}
+		return exceeded()
+	}
+	if compressed != nil {
+		// 单文件压缩的条目解析时才流式解压，解压后的大小同样受限制，按解压后的大小计入解压总量
+		size, skipped, err := measureCompressedLog(target, limit)
+		if err != nil || skipped != "" || size > limit {
+			os.Remove(target)
+			ex.bytes -= n
+			switch {
+			case err != nil:
+				return model.ArchiveMemberFailed, err.Error()
+			case skipped != "":
+				return model.ArchiveMemberSkipped, skipped
+			}
+			return exceeded()
+		}
+		ex.bytes += size - n
+		n = size
+	}
+	ex.files++
+	result.Path = target
+	result.size = n
+	result.nested = inner != nil
+	switch {
+	case result.nested:
+		return model.ArchiveMemberExtracted, "嵌套压缩包"
+	case compressed != nil:
+		return model.ArchiveMemberExtracted, strings.TrimPrefix(compressed.ext, ".") + "压缩的文本文件"
+	}
+	return model.ArchiveMemberExtracted, ""
+}
+
+// measureCompressedLog 流式解压单文件压缩的文件，按内容判断是否为日志（文本）文件，不是时返回原因；
+// 返回解压后的字节数，最多读取 limit+1 字节，由调用方判断是否超限
+func measureCompressedLog(filePath string, limit int64) (int64, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	reader, closer, err := decompress(file)
+	if err != nil {
+		return 0, "", err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	counted := &io.LimitedReader{R: reader, N: limit + 1}
+	sample := make([]byte, sniffSize)
+	n, err := io.ReadFull(counted, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, "", fmt.Errorf("解压文件失败: %w", err)
+	}
+	if n == 0 {
+		return 0, "空文件", nil
+	}
+	if !xcharset.IsText(sample[:n]) {
+		return 0, "二进制文件", nil
+	}
+	rest, err := io.Copy(io.Discard, counted)
+	if err != nil {
+		return 0, "", fmt.Errorf("解压文件失败: %w", err)
+	}
+	return int64(n) + rest, "", nil
+}
+
+// sanitizeEntryPath 校验条目路径并返回相对路径，拒绝绝对路径和包含 .. 的路径（zip-slip）
+func sanitizeEntryPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", fmt.Errorf("不允许绝对路径")
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("路径不能包含 ..")
+		}
+	}
+	clean := path.Clean(name)
+	if clean == "." || clean == "" {
+		return "", fmt.Errorf("路径为空")
+	}
+	return filepath.FromSlash(clean), nil
+}
+
+// writeFile 将内容写入新文件，自动创建目录，最多写入 limit+1 字节，返回写入的字节数，
+// 超过limit的部分不会写出，由调用方判断是否超限；文件已存在时返回错误
+func writeFile(filePath string, src io.Reader, limit int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("创建目录失败: %w", err)
+	}
+	dst, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return 0, fmt.Errorf("文件重名")
+		}
+		return 0, fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer dst.Close()
+	n, err := io.CopyN(dst, src, limit+1)
+	if err != nil && err != io.EOF {
+		return n, fmt.Errorf("复制文件内容失败: %w", err)
+	}
+	return n, nil
+}
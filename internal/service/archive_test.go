@@ -0,0 +1,341 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mholt/archiver/v4"
+)
+
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func compress(t *testing.T, c archiver.Compressor, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := c.OpenWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractNestedArchive(t *testing.T) {
+	deep := zipBytes(t, map[string][]byte{"d.log": []byte("deep\n")})
+	inner := zipBytes(t, map[string][]byte{"c.log": []byte("c\n"), "deep.zip": deep})
+	entries := map[string][]byte{
+		"logs/a.log":    []byte("a\n"),
+		"logs/b.log.xz": compress(t, archiver.Xz{}, []byte("2024-08-02 15:56:24 INFO from xz\n")),
+		"inner.zip":     inner,
+		"var/messages":  []byte("Aug  2 15:56:24 host kernel: boot\n"),
+		"app.log.1":     []byte("rotated\n"),
+		"kmsg.2.gz":     compress(t, archiver.Gz{}, []byte("<6>[    0.000000] Booting Linux\n")),
+		"core.gz":       compress(t, archiver.Gz{}, []byte("\x7fELF\x02\x01\x01\x00\x00\x00")),
+		"logo.png":      []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+		"empty.log":     nil,
+	}
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for name, data := range entries {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))})
+		tw.Write(data)
+	}
+	tw.Close()
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "bundle.tar.gz")
+	if err := os.WriteFile(archivePath, compress(t, archiver.Gz{}, tarBuf.Bytes()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsArchive(archivePath) {
+		t.Fatal("tar.gz 未识别为压缩包")
+	}
+	s := &StorageService{config: &config.Config{Storage: config.StorageConfig{UploadDir: dir, MaxArchiveDepth: 2}}}
+	members, err := s.ExtractArchive(archivePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, files []string
+	statuses := map[string]string{}
+	for _, m := range members {
+		statuses[m.Name] = m.Status
+		if m.Path != "" {
+			names = append(names, filepath.Base(m.Path))
+			files = append(files, m.Path)
+		}
+	}
+	sort.Strings(names)
+	if want := []string{"a.log", "app.log.1", "b.log.xz", "c.log", "kmsg.2.gz", "messages"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	wantStatus := map[string]string{
+		"logs/a.log":         model.ArchiveMemberExtracted,
+		"logs/b.log.xz":      model.ArchiveMemberExtracted,
+		"inner.zip":          model.ArchiveMemberExtracted,
+		"inner.zip/c.log":    model.ArchiveMemberExtracted,
+		"inner.zip/deep.zip": model.ArchiveMemberSkipped,
+		"var/messages":       model.ArchiveMemberExtracted,
+		"app.log.1":          model.ArchiveMemberExtracted,
+		"kmsg.2.gz":          model.ArchiveMemberExtracted,
+		"core.gz":            model.ArchiveMemberSkipped,
+		"logo.png":           model.ArchiveMemberSkipped,
+		"empty.log":          model.ArchiveMemberSkipped,
+	}
+	if !reflect.DeepEqual(statuses, wantStatus) {
+		t.Errorf("members = %v", statuses)
+	}
+
+	// 单文件压缩的日志解析时流式解压
+	for _, f := range files {
+		if filepath.Base(f) != "b.log.xz" {
+			continue
+		}
+		if IsArchive(f) {
+			t.Error("单文件压缩不应识别为压缩包")
+		}
+		reader, _, err := openLogReader(f, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(data) != "2024-08-02 15:56:24 INFO from xz\n" {
+			t.Errorf("content = %q", data)
+		}
+	}
+}
+
+type tarEntry struct {
+	name     string
+	data     []byte
+	typeflag byte
+	linkname string
+}
+
+func writeTar(t *testing.T, path string, entries []tarEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Typeflag: typeflag, Linkname: e.linkname})
+		tw.Write(e.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, compress(t, archiver.Gz{}, buf.Bytes()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchiveUnsafeEntries(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "unsafe.tar.gz")
+	writeTar(t, archivePath, []tarEntry{
+		{name: "../../evil.log", data: []byte("x\n")},
+		{name: "/etc/abs.log", data: []byte("x\n")},
+		{name: "C:\\abs.log", data: []byte("x\n")},
+		{name: "link.log", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+		{name: "hard.log", typeflag: tar.TypeLink, linkname: "ok.log"},
+		{name: "./ok.log", data: []byte("ok\n")},
+		{name: "ok.log", data: []byte("dup\n")},
+	})
+	s := &StorageService{config: &config.Config{Storage: config.StorageConfig{UploadDir: dir}}}
+	members, err := s.ExtractArchive(archivePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		model.ArchiveMemberRejected, model.ArchiveMemberRejected, model.ArchiveMemberRejected,
+		model.ArchiveMemberRejected, model.ArchiveMemberRejected,
+		model.ArchiveMemberExtracted, model.ArchiveMemberFailed,
+	}
+	if len(members) != len(want) {
+		t.Fatalf("members = %+v", members)
+	}
+	for i, m := range members {
+		if m.Status != want[i] || (m.Status != model.ArchiveMemberExtracted && m.Reason == "") {
+			t.Errorf("%s = %s %q, want %s", m.Name, m.Status, m.Reason, want[i])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.log")); err == nil {
+		t.Error("越界路径被写出")
+	}
+}
+
+func TestExtractArchiveLimits(t *testing.T) {
+	dir := t.TempDir()
+
+	// 压缩比异常：2MB的重复内容压缩后只有几KB，整个压缩包停止解压
+	bomb := filepath.Join(dir, "bomb.tar.gz")
+	writeTar(t, bomb, []tarEntry{
+		{name: "a.log", data: []byte("a\n")},
+		{name: "bomb.log", data: bytes.Repeat([]byte{'0'}, 2<<20)},
+		{name: "b.log", data: []byte("b\n")},
+	})
+	s := &StorageService{config: &config.Config{Storage: config.StorageConfig{UploadDir: dir}}}
+	members, err := s.ExtractArchive(bomb, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{members[0].Status, members[1].Status, members[2].Status}
+	if want := []string{model.ArchiveMemberExtracted, model.ArchiveMemberRejected, model.ArchiveMemberSkipped}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("bomb = %+v", members)
+	}
+	if members[1].Path != "" {
+		t.Error("超限的条目不应保留")
+	}
+
+	// 文件数和总大小限制
+	bundle := filepath.Join(dir, "bundle.tar.gz")
+	writeTar(t, bundle, []tarEntry{
+		{name: "1.log", data: []byte("1\n")},
+		{name: "2.log", data: []byte("2\n")},
+		{name: "3.log", data: []byte("3\n")},
+	})
+	s.config.Storage.MaxExtractFiles = 2
+	members, err = s.ExtractArchive(bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members[1].Status != model.ArchiveMemberExtracted || members[2].Status != model.ArchiveMemberSkipped {
+		t.Errorf("files limit = %+v", members)
+	}
+	s.config.Storage.MaxExtractFiles = 0
+	s.config.Storage.MaxExtractSize = 3
+	members, err = s.ExtractArchive(bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses = []string{members[0].Status, members[1].Status, members[2].Status}
+	if want := []string{model.ArchiveMemberExtracted, model.ArchiveMemberRejected, model.ArchiveMemberSkipped}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("size limit = %+v", members)
+	}
+}
+
+func TestExtractArchiveCompressedMember(t *testing.T) {
+	dir := t.TempDir()
+
+	// 单文件压缩的条目按解压后的大小检查压缩比：2MB的重复内容gzip后只有几KB
+	bomb := filepath.Join(dir, "bomb.tar.gz")
+	writeTar(t, bomb, []tarEntry{
+		{name: "a.log", data: []byte("a\n")},
+		{name: "bomb.log.gz", data: compress(t, archiver.Gz{}, bytes.Repeat([]byte{'0'}, 2<<20))},
+		{name: "b.log", data: []byte("b\n")},
+	})
+	s := &StorageService{config: &config.Config{Storage: config.StorageConfig{UploadDir: dir}}}
+	members, err := s.ExtractArchive(bomb, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{members[0].Status, members[1].Status, members[2].Status}
+	if want := []string{model.ArchiveMemberExtracted, model.ArchiveMemberRejected, model.ArchiveMemberSkipped}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("bomb = %+v", members)
+	}
+	if !strings.Contains(members[1].Reason, "压缩比") || members[1].Path != "" {
+		t.Errorf("bomb.log.gz = %+v", members[1])
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(members[0].Path), "bomb.log.gz")); err == nil {
+		t.Error("超限的条目不应保留")
+	}
+
+	// 解压总量按解压后的大小累计
+	bundle := filepath.Join(dir, "bundle.tar.gz")
+	writeTar(t, bundle, []tarEntry{
+		{name: "a.log.gz", data: compress(t, archiver.Gz{}, append(bytes.Repeat([]byte{'a'}, 199), '\n'))},
+		{name: "b.log", data: append(bytes.Repeat([]byte{'b'}, 99), '\n')},
+	})
+	s.config.Storage.MaxExtractSize = 250
+	members, err = s.ExtractArchive(bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members[0].Status != model.ArchiveMemberExtracted || members[1].Status != model.ArchiveMemberRejected {
+		t.Errorf("size limit = %+v", members)
+	}
+	s.config.Storage.MaxExtractSize = 150
+	members, err = s.ExtractArchive(bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members[0].Status != model.ArchiveMemberRejected || members[1].Status != model.ArchiveMemberSkipped {
+		t.Errorf("size limit = %+v", members)
+	}
+}
+
+func TestExtractArchiveRuleFilter(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "bugreport.tar.gz")
+	writeTar(t, archivePath, []tarEntry{
+		{name: "FS/data/anr/traces.txt", data: []byte("anr\n")},
+		{name: "FS/data/misc/app.log.1", data: []byte("old\n")},
+		{name: "bugreport-2024.txt", data: []byte("report\n")},
+		{name: "version.txt", data: []byte("1\n")},
+	})
+	rule := &config.LogParseRule{Include: []string{"FS/*/anr/*", "FS/*/misc/*", "bugreport-*"}, Exclude: []string{"*.1"}}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := &StorageService{config: &config.Config{Storage: config.StorageConfig{UploadDir: dir}}}
+	members, err := s.ExtractArchive(archivePath, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{model.ArchiveMemberExtracted, model.ArchiveMemberSkipped, model.ArchiveMemberExtracted, model.ArchiveMemberSkipped}
+	for i, m := range members {
+		if m.Status != want[i] {
+			t.Errorf("%s = %s %q, want %s", m.Name, m.Status, m.Reason, want[i])
+		}
+	}
+	if bad := (&config.LogParseRule{Include: []string{"["}}); bad.Validate() == nil {
+		t.Error("错误的glob应校验失败")
+	}
+}
+
+func TestLogFileName(t *testing.T) {
+	tests := map[string]bool{
+		"app.log": true, "app.TXT": true, "app.log.gz": true, "app.log.zst": true, "app.bz2": true,
+		"app.log.1": true, "app.log.2.gz": true, "messages.1": false, "app.out": true,
+		"app.tar.gz": false, "app.json.gz": false, "app.md": false,
+	}
+	for name, want := range tests {
+		if got := isLogFileName(name); got != want {
+			t.Errorf("isLogFileName(%s) = %v", name, got)
+		}
+	}
+	if ext := fileExt("Bundle.TAR.ZST"); ext != ".tar.zst" {
+		t.Errorf("fileExt = %s", ext)
+	}
+}
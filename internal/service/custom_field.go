@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log-tools-go/internal/config"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// customField 预编译的自定义提取字段
+type customField struct {
+	name    string
+	pattern *regexp.Regexp
+	typ     string
+}
+
+// compileCustomFields 预编译规则中的自定义字段
+func compileCustomFields(fields []config.CustomField) ([]customField, error) {
+	compiled := make([]customField, 0, len(fields))
+	var errs []error
+	for _, f := range fields {
+		r, err := regexp.Compile(f.Pattern)
+		if err != nil {
+			errs = append(errs, fieldError("custom_fields."+f.Name, fmt.Errorf("自定义字段%s正则表达式错误: %w", f.Name, err)))
+			continue
+		}
+		compiled = append(compiled, customField{name: f.Name, pattern: r, typ: f.Type})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// extract 从行中提取字段值并按类型转换，未匹配或无法转换时返回false
+func (f customField) extract(line string) (interface{}, bool) {
+	match := f.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil, false
+	}
+	value := match[0]
+	if len(match) > 1 {
+		value = match[1]
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, false
+	}
+	return convertFieldValue(value, f.typ)
+}
+
+// convertFieldValue 按字段类型转换字符串值，时长统一转换为毫秒
+func convertFieldValue(value, typ string) (interface{}, bool) {
+	switch typ {
+	case config.FieldTypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return n, err == nil
+	case config.FieldTypeFloat:
+		n, err := strconv.ParseFloat(value, 64)
+		return n, err == nil
+	case config.FieldTypeDuration:
+		d, err := time.ParseDuration(value)
+		return float64(d) / float64(time.Millisecond), err == nil
+	}
+	return value, true
+}
+
+// applyCustomFields 将自定义字段写入扩展属性，同名时覆盖格式自身解析出的属性
+func applyCustomFields(f *lineFields, fields []customField, line string) {
+	for _, field := range fields {
+		value, ok := field.extract(line)
+		if !ok {
+			continue
+		}
+		if f.attrs == nil {
+			f.attrs = make(map[string]interface{}, len(fields))
+		}
+		f.attrs[field.name] = value
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"unicode/utf8"
+)
+
+const (
+	diagnosticSamples   = 10  // 未匹配行、时间错误行的样本数
+	diagnosticLongest   = 5   // 记录的最长行数
+	diagnosticTextLimit = 512 // 样本行内容的最大长度
+)
+
+// newDiagnostics 创建空的解析诊断
+func newDiagnostics(rule *compiledRule) *model.ParseDiagnostics {
+	d := &model.ParseDiagnostics{
+		FieldMatches:     make(map[string]int),
+		TimeSources:      make(map[string]int),
+		UnmatchedSamples: []model.DiagnosticLine{},
+		TimestampErrors:  []model.DiagnosticLine{},
+		LongestLines:     []model.DiagnosticLine{},
+		TruncatedSamples: []model.DiagnosticLine{},
+	}
+	if rule.timeLayout != nil {
+		d.TimestampFormat = rule.timeLayout.Pattern()
+	}
+	return d
+}
+
+// recordLine 统计单行的字段匹配和时间解析情况
+func recordLine(d *model.ParseDiagnostics, pl *parsedLine) {
+	d.TotalLines++
+	matched := pl.fields.matched
+	for _, name := range config.RuleFieldNames {
+		if pl.fields.get(name) != "" {
+			d.FieldMatches[name]++
+			matched = true
+		}
+	}
+	if matched {
+		d.MatchedLines++
+	} else if len(d.UnmatchedSamples) < diagnosticSamples {
+		d.UnmatchedSamples = append(d.UnmatchedSamples, diagnosticLine(pl, ""))
+	}
+	if pl.fields.timestamp != "" && !pl.timeParsed {
+		d.TimestampFailed++
+		if len(d.TimestampErrors) < diagnosticSamples {
+			d.TimestampErrors = append(d.TimestampErrors, diagnosticLine(pl, pl.fields.timestamp))
+		}
+	}
+	if pl.truncated {
+		d.TruncatedLines++
+		if len(d.TruncatedSamples) < diagnosticSamples {
+			d.TruncatedSamples = append(d.TruncatedSamples, diagnosticLine(pl, ""))
+		}
+	}
+	recordLongest(d, pl)
+}
+
+// recordLongest 按长度降序保留最长的若干行
+func recordLongest(d *model.ParseDiagnostics, pl *parsedLine) {
+	n := len(d.LongestLines)
+	if n == diagnosticLongest && pl.size <= d.LongestLines[n-1].Length {
+		return
+	}
+	i := 0
+	for i < n && d.LongestLines[i].Length >= pl.size {
+		i++
+	}
+	d.LongestLines = append(d.LongestLines[:i], append([]model.DiagnosticLine{diagnosticLine(pl, "")}, d.LongestLines[i:]...)...)
+	if len(d.LongestLines) > diagnosticLongest {
+		d.LongestLines = d.LongestLines[:diagnosticLongest]
+	}
+}
+
+func diagnosticLine(pl *parsedLine, value string) model.DiagnosticLine {
+	text := pl.raw
+	if len(text) > diagnosticTextLimit {
+		cut := diagnosticTextLimit
+		// 截断位置退回到完整的UTF-8字符边界
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "..."
+	}
+	return model.DiagnosticLine{Line: pl.number, Length: pl.size, Text: text, Value: value}
+}
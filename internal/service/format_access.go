@@ -0,0 +1,249 @@
+package service
+
+import (
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/pkg/xtime"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 访问日志预设格式，统一使用 Nginx log_format 写法描述
+var accessPresets = map[string]string{
+	// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+	"common": `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`,
+	// common 之后追加 "Referer" "User-Agent"
+	"combined": `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`,
+}
+
+// 自动识别时依次尝试的格式
+var accessAutoOrder = []string{"combined", "common"}
+
+// log_format 中的变量：$name 或 ${name}，变量名以字母或下划线开头
+var accessVariable = regexp.MustCompile(`\$(?:\{([A-Za-z_]\w*)\}|([A-Za-z_]\w*))`)
+
+var accessSpaces = regexp.MustCompile(` +`)
+
+// 取值形式固定的变量使用专用正则，其余变量按其后的分隔字符截取
+var accessVariablePatterns = map[string]string{
+	"time_local":   `[^\]]+`,
+	"time_iso8601": `\S+`,
+	"status":       `\d{3}`,
+	"msec":         `\d+(?:\.\d+)?`,
+	// 经过多个上游时为 "0.010, 0.002" 或 "0.010 : 0.002"
+	"upstream_response_time": accessUpstreamValues,
+	"upstream_connect_time":  accessUpstreamValues,
+	"upstream_header_time":   accessUpstreamValues,
+	"upstream_status":        accessUpstreamValues,
+}
+
+const accessUpstreamValues = `[\d.-]+(?:\s*[,:]\s*[\d.-]+)*`
+
+// Common Log Format 时间格式
+const accessTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessMatcher Web访问日志（Apache/Nginx），请求信息按类型保存为扩展属性：
+// client_ip, method, path, protocol, status, bytes, referer, user_agent, request_time
+type accessMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+func newAccessMatcher(rule *config.LogParseRule) (*accessMatcher, error) {
+	formats := []string{rule.Format}
+	switch {
+	case rule.Format == "":
+		formats = nil
+		for _, name := range accessAutoOrder {
+			formats = append(formats, accessPresets[name])
+		}
+	case accessPresets[rule.Format] != "":
+		formats = []string{accessPresets[rule.Format]}
+	}
+	m := &accessMatcher{}
+	for _, format := range formats {
+		pattern, err := compileAccessFormat(format)
+		if err != nil {
+			return nil, fieldError("format", err)
+		}
+		m.patterns = append(m.patterns, pattern)
+	}
+	return m, nil
+}
+
+// compileAccessFormat 将 log_format 字符串转换为整行正则，每个变量对应一个同名分组
+func compileAccessFormat(format string) (*regexp.Regexp, error) {
+	locs := accessVariable.FindAllStringSubmatchIndex(format, -1)
+	if len(locs) == 0 {
+		return nil, fmt.Errorf("访问日志格式中没有变量: %s", format)
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for i, loc := range locs {
+		b.WriteString(accessLiteral(format[last:loc[0]]))
+		var name string
+		if loc[2] >= 0 {
+			name = format[loc[2]:loc[3]]
+		} else {
+			name = format[loc[4]:loc[5]]
+		}
+		next := len(format)
+		if i+1 < len(locs) {
+			next = locs[i+1][0]
+		}
+		fmt.Fprintf(&b, "(?P<%s>%s)", name, accessValuePattern(name, format[loc[1]:next], loc[1] == len(format)))
+		last = loc[1]
+	}
+	b.WriteString(accessLiteral(format[last:]))
+	b.WriteString("$")
+	r, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("访问日志格式错误: %w", err)
+	}
+	return r, nil
+}
+
+// accessLiteral 转义格式中的普通文本，连续空格按一个或多个空格匹配
+func accessLiteral(literal string) string {
+	return accessSpaces.ReplaceAllString(regexp.QuoteMeta(literal), " +")
+}
+
+// accessValuePattern 变量取值正则：专用正则优先，否则匹配到下一个分隔字符为止
+func accessValuePattern(name, following string, atEnd bool) string {
+	if pattern, ok := accessVariablePatterns[name]; ok {
+		return pattern
+	}
+	if atEnd {
+		return ".*"
+	}
+	if following == "" {
+		return ".*?"
+	}
+	return "[^" + regexp.QuoteMeta(following[:1]) + "]*"
+}
+
+func (m *accessMatcher) match(line string) lineFields {
+	for _, pattern := range m.patterns {
+		match := pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		f := lineFields{matched: true, attrs: make(map[string]interface{})}
+		for i, name := range pattern.SubexpNames() {
+			if name != "" {
+				f.setAccessVariable(name, match[i])
+			}
+		}
+		if _, ok := f.attrs["bytes"]; !ok && f.attrs["bytes_sent"] != nil {
+			f.attrs["bytes"] = f.attrs["bytes_sent"]
+		}
+		return f
+	}
+	return lineFields{}
+}
+
+// setAccessVariable 将 log_format 变量映射为字段或带类型的扩展属性，"-" 表示空值
+func (f *lineFields) setAccessVariable(name, value string) {
+	if value == "" || value == "-" {
+		return
+	}
+	switch name {
+	case "remote_addr":
+		f.attrs["client_ip"] = value
+	case "remote_user":
+		f.attrs["user"] = value
+	case "request":
+		// 请求行：METHOD PATH PROTOCOL
+		f.message = value
+		parts := strings.Fields(value)
+		if len(parts) > 0 {
+			f.attrs["method"] = parts[0]
+		}
+		if len(parts) > 1 {
+			f.attrs["path"] = parts[1]
+		}
+		if len(parts) > 2 {
+			f.attrs["protocol"] = parts[2]
+		}
+	case "request_method":
+		f.attrs["method"] = value
+	case "request_uri":
+		f.attrs["path"] = value
+	case "uri":
+		if _, ok := f.attrs["path"]; !ok {
+			f.attrs["path"] = value
+		}
+	case "server_protocol":
+		f.attrs["protocol"] = value
+	case "status":
+		status, err := strconv.Atoi(value)
+		if err != nil {
+			f.attrs["status"] = value
+			return
+		}
+		f.attrs["status"] = status
+		f.level = accessLevel(status)
+	case "body_bytes_sent":
+		f.setAccessInt("bytes", value)
+	case "bytes_sent", "request_length", "connection", "connection_requests":
+		f.setAccessInt(name, value)
+	case "request_time", "upstream_response_time", "upstream_connect_time", "upstream_header_time":
+		f.setAccessFloat(name, value)
+	case "http_referer":
+		f.attrs["referer"] = value
+	case "http_user_agent":
+		f.attrs["user_agent"] = value
+	case "time_local":
+		f.timestamp = value
+		if t, err := time.Parse(accessTimeLayout, value); err == nil {
+			f.time = &t
+		}
+	case "time_iso8601":
+		f.timestamp = value
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			f.time = &t
+		}
+	case "msec":
+		if f.timestamp == "" {
+			f.timestamp = value
+			if t, ok := xtime.ParseEpoch(value); ok {
+				f.time = &t
+			}
+		}
+	default:
+		f.attrs[name] = value
+	}
+}
+
+// setAccessInt 字节数等整数变量保存为数字，无法解析时保留原文
+func (f *lineFields) setAccessInt(name, value string) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		f.attrs[name] = n
+	} else {
+		f.attrs[name] = value
+	}
+}
+
+// setAccessFloat 耗时变量（秒，毫秒精度）保存为数字
+// 经过多个上游时 upstream_* 为逗号分隔的多个值，此时保留原文
+func (f *lineFields) setAccessFloat(name, value string) {
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		f.attrs[name] = v
+	} else {
+		f.attrs[name] = value
+	}
+}
+
+// accessLevel 按状态码类别推导日志级别
+func accessLevel(status int) string {
+	switch {
+	case status >= 500:
+		return "ERROR"
+	case status >= 400:
+		return "WARN"
+	default:
+		return "INFO"
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAccessPresets(t *testing.T) {
+	tests := []struct {
+		format string
+		line   string
+		level  string
+		attrs  map[string]interface{}
+	}{
+		{
+			format: "common",
+			line:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			level:  "INFO",
+			attrs: map[string]interface{}{
+				"client_ip": "127.0.0.1", "user": "frank", "method": "GET", "path": "/apache_pb.gif",
+				"protocol": "HTTP/1.0", "status": 200, "bytes": int64(2326),
+			},
+		},
+		{
+			format: "combined",
+			line:   `10.0.0.8 - - [10/Oct/2000:13:55:36 -0700] "POST /api/login HTTP/1.1" 502 0 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)"`,
+			level:  "ERROR",
+			attrs: map[string]interface{}{
+				"client_ip": "10.0.0.8", "method": "POST", "path": "/api/login", "protocol": "HTTP/1.1",
+				"status": 502, "bytes": int64(0), "referer": "https://example.com/", "user_agent": "Mozilla/5.0 (X11; Linux x86_64)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{tt.format, ""} {
+			m, err := newAccessMatcher(&config.LogParseRule{Type: config.RuleTypeAccess, Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := m.match(tt.line)
+			if !got.matched {
+				t.Errorf("[%s] %q 未匹配", format, tt.line)
+				continue
+			}
+			if got.level != tt.level || !reflect.DeepEqual(got.attrs, tt.attrs) {
+				t.Errorf("[%s] level = %s, attrs = %+v", format, got.level, got.attrs)
+			}
+			want := time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC)
+			if got.time == nil || !got.time.Equal(want) {
+				t.Errorf("[%s] time = %v, want %v", format, got.time, want)
+			}
+		}
+	}
+}
+
+func TestAccessNginxLogFormat(t *testing.T) {
+	rule := &config.LogParseRule{
+		Type:   config.RuleTypeAccess,
+		Format: `$remote_addr [$time_iso8601] "$request_method ${request_uri}" $status $body_bytes_sent rt=$request_time urt=$upstream_response_time "$http_x_forwarded_for"`,
+	}
+	m, err := newAccessMatcher(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.match(`192.168.1.10 [2024-08-02T15:56:24+08:00] "GET /v1/items?id=3" 404 153 rt=0.012 urt=0.010, 0.002 "1.2.3.4"`)
+	if !got.matched {
+		t.Fatal("未匹配")
+	}
+	want := map[string]interface{}{
+		"client_ip": "192.168.1.10", "method": "GET", "path": "/v1/items?id=3", "status": 404, "bytes": int64(153),
+		"request_time": 0.012, "upstream_response_time": "0.010, 0.002", "http_x_forwarded_for": "1.2.3.4",
+	}
+	if got.level != "WARN" || !reflect.DeepEqual(got.attrs, want) {
+		t.Errorf("level = %s, attrs = %+v", got.level, got.attrs)
+	}
+	if got.time == nil || !got.time.Equal(time.Date(2024, 8, 2, 7, 56, 24, 0, time.UTC)) {
+		t.Errorf("time = %v", got.time)
+	}
+
+	if _, err := newAccessMatcher(&config.LogParseRule{Type: config.RuleTypeAccess, Format: "nginx"}); err == nil {
+		t.Error("未知格式应返回错误")
+	}
+}
+
+func TestAccessFormatValidate(t *testing.T) {
+	// 自定义格式保存时即编译，不能只检查是否含有 $
+	for _, format := range []string{"nginx", "cost $ ms", "$", "price: $1"} {
+		rule := &config.LogParseRule{Type: config.RuleTypeAccess, Format: format}
+		if err := rule.Validate(); err == nil {
+			t.Errorf("%q 应校验失败", format)
+		}
+	}
+	for _, format := range []string{"", "combined", `$remote_addr [$time_local] "$request" $status`} {
+		rule := &config.LogParseRule{Type: config.RuleTypeAccess, Format: format}
+		if err := rule.Validate(); err != nil {
+			t.Errorf("%q: %v", format, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"encoding/json"
+	"log-tools-go/internal/config"
+	"log-tools-go/pkg/xtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldMapping 结构化日志（json、logfmt）的字段映射：字段名 -> 候选键路径
+type fieldMapping struct {
+	fields map[string][]string
+	format string
+}
+
+// newFieldMapping 规则中配置了映射的字段使用配置的键路径，其余字段使用默认候选键
+func newFieldMapping(rule *config.LogParseRule, defaults map[string][]string) fieldMapping {
+	m := fieldMapping{fields: make(map[string][]string), format: rule.TimestampFormat}
+	for _, name := range config.RuleFieldNames {
+		if path, ok := rule.Fields[name]; ok {
+			if path != "" {
+				m.fields[name] = []string{path}
+			}
+			continue
+		}
+		m.fields[name] = defaults[name]
+	}
+	return m
+}
+
+// extract 从结构化对象中取出映射字段，其余键保留为扩展属性，行已解析为对象即视为符合格式
+func (m fieldMapping) extract(obj map[string]interface{}) lineFields {
+	f := lineFields{matched: true}
+	for _, name := range config.RuleFieldNames {
+		for _, path := range m.fields[name] {
+			value, ok := takeJSONPath(obj, path)
+			if !ok {
+				continue
+			}
+			switch name {
+			case "timestamp":
+				f.timestamp, f.time = structuredTime(value, m.format)
+			case "level":
+				f.level = jsonLevel(value)
+			default:
+				f.set(name, jsonString(value))
+			}
+			break
+		}
+	}
+	if len(obj) > 0 {
+		f.attrs = obj
+	}
+	return f
+}
+
+// takeJSONPath 取出键路径对应的值并从对象中删除，嵌套对象被取空后一并删除
+func takeJSONPath(obj map[string]interface{}, path string) (interface{}, bool) {
+	// 键名本身含点号（如 ECS 的 log.level）时优先按完整键名查找
+	if value, ok := obj[path]; ok {
+		delete(obj, path)
+		return value, true
+	}
+	parts := strings.SplitN(path, ".", 2)
+	if len(parts) < 2 {
+		return nil, false
+	}
+	child, ok := obj[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, ok := takeJSONPath(child, parts[1])
+	if ok && len(child) == 0 {
+		delete(obj, parts[0])
+	}
+	return value, ok
+}
+
+// jsonString 将JSON值转换为字符串，对象和数组保留JSON格式
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+// structuredTime 解析时间字段
+// 未配置时间格式时，先按RFC3339解析，再将纯数字按量级识别为秒/毫秒/微秒/纳秒时间戳
+func structuredTime(value interface{}, format string) (string, *time.Time) {
+	str := jsonString(value)
+	if format != "" {
+		return str, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, str); err == nil {
+		return str, &t
+	}
+	if t, ok := xtime.ParseEpoch(str); ok {
+		return str, &t
+	}
+	return str, nil
+}
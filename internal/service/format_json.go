@@ -0,0 +1,74 @@
+package service
+
+import (
+	"encoding/json"
+	"log-tools-go/internal/config"
+	"strings"
+)
+
+// 未配置映射的字段按常见结构化日志库（zap、logrus、bunyan、pino）的键名依次查找
+var defaultJSONFields = map[string][]string{
+	"timestamp":  {"time", "ts", "timestamp", "@timestamp", "t"},
+	"level":      {"level", "lvl", "severity", "@level"},
+	"message":    {"msg", "message", "@message"},
+	"thread":     {"thread", "thread_name", "threadName"},
+	"module":     {"logger", "logger_name", "module", "name"},
+	"process":    {"pid", "process"},
+	"tag":        {"tag"},
+	"class":      {"caller", "class"},
+	"class_line": {"line"},
+}
+
+// bunyan、pino 使用数值表示日志级别
+var jsonNumericLevels = map[int64]string{
+	10: "TRACE",
+	20: "DEBUG",
+	30: "INFO",
+	40: "WARN",
+	50: "ERROR",
+	60: "FATAL",
+}
+
+// jsonMatcher JSON行解析，每个字段映射到一个键路径，其余键保留为扩展属性
+type jsonMatcher struct {
+	fieldMapping
+}
+
+func newJSONMatcher(rule *config.LogParseRule) (*jsonMatcher, error) {
+	return &jsonMatcher{fieldMapping: newFieldMapping(rule, defaultJSONFields)}, nil
+}
+
+func (m *jsonMatcher) match(line string) lineFields {
+	// 非JSON行（如启动横幅）按普通文本处理
+	if !strings.HasPrefix(line, "{") {
+		return lineFields{}
+	}
+	obj, ok := decodeJSONObject(line)
+	if !ok {
+		return lineFields{}
+	}
+	return m.extract(obj)
+}
+
+// decodeJSONObject 解析一个JSON对象，数值保留为json.Number避免精度丢失
+func decodeJSONObject(line string) (map[string]interface{}, bool) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var obj map[string]interface{}
+	if err := decoder.Decode(&obj); err != nil || obj == nil {
+		return nil, false
+	}
+	return obj, true
+}
+
+// jsonLevel 解析日志级别，兼容数值级别
+func jsonLevel(value interface{}) string {
+	if n, ok := value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			if level, ok := jsonNumericLevels[i]; ok {
+				return level
+			}
+		}
+	}
+	return jsonString(value)
+}
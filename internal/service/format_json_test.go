@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+	"log-tools-go/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONLines(t *testing.T) {
+	content := `starting server
+{"ts":"2024-08-02T15:56:24.5Z","level":"warn","msg":"slow request","logger":"http","caller":"server.go:42","req":{"id":"r1","ms":1234},"cached":true}
+{"time":1722614185000,"level":50,"msg":"boom","name":"svc","pid":12}
+{"ts":"2024-08-02T15:56:26Z","level":"info","msg":"truncated
+`
+	path := filepath.Join(t.TempDir(), "app.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{Type: config.RuleTypeJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logFile.Entries) != 4 {
+		t.Fatalf("entries = %d", len(logFile.Entries))
+	}
+
+	// 非JSON行按普通文本处理
+	if e := logFile.Entries[0]; e.Message != "starting server" || e.Attrs != nil {
+		t.Errorf("entry 0 = %q %v", e.Message, e.Attrs)
+	}
+
+	// 默认键名映射，其余键（含嵌套对象）保留为扩展属性，数值不丢失精度
+	e := logFile.Entries[1]
+	if e.Level != "WARN" || e.Message != "slow request" || e.Module != "http" || *e.Class != "server.go:42" {
+		t.Errorf("entry 1 = %s %q %s %s", e.Level, e.Message, e.Module, *e.Class)
+	}
+	if !e.LogTime.Equal(time.Date(2024, 8, 2, 15, 56, 24, 500000000, time.UTC)) {
+		t.Errorf("time = %v", e.LogTime)
+	}
+	want := map[string]interface{}{
+		"req":    map[string]interface{}{"id": "r1", "ms": json.Number("1234")},
+		"cached": true,
+	}
+	if !reflect.DeepEqual(e.Attrs, want) {
+		t.Errorf("attrs = %#v", e.Attrs)
+	}
+
+	// bunyan/pino 的数值级别和毫秒时间戳
+	e = logFile.Entries[2]
+	if e.Level != "ERROR" || e.Module != "svc" || *e.Process != "12" || e.Attrs != nil {
+		t.Errorf("entry 2 = %s %s %s %v", e.Level, e.Module, *e.Process, e.Attrs)
+	}
+	if !e.LogTime.Equal(time.UnixMilli(1722614185000)) {
+		t.Errorf("time = %v", e.LogTime)
+	}
+
+	// 不完整的JSON行按普通文本处理
+	if e := logFile.Entries[3]; e.Attrs != nil || e.Message != e.Content {
+		t.Errorf("entry 3 = %q %v", e.Message, e.Attrs)
+	}
+}
+
+func TestJSONFieldMapping(t *testing.T) {
+	content := `{"@timestamp":"2024-08-02T15:56:24Z","log.level":"error","fields":{"msg":"disk full","disk":"/dev/sda"},"msg":"kept"}
+`
+	path := filepath.Join(t.TempDir(), "ecs.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{
+		Type:   config.RuleTypeJSON,
+		Fields: map[string]string{"timestamp": "@timestamp", "level": "log.level", "message": "fields.msg", "module": ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := logFile.Entries[0]
+	if e.Level != "ERROR" || e.Message != "disk full" || !e.LogTime.Equal(time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)) {
+		t.Errorf("entry = %s %q %v", e.Level, e.Message, e.LogTime)
+	}
+	// 配置了映射的字段不再按默认键名查找，映射为空的字段不提取
+	want := map[string]interface{}{"fields": map[string]interface{}{"disk": "/dev/sda"}, "msg": "kept"}
+	if !reflect.DeepEqual(e.Attrs, want) {
+		t.Errorf("attrs = %#v", e.Attrs)
+	}
+
+	rule := &config.LogParseRule{Type: config.RuleTypeJSON, Fields: map[string]string{"unknown": "x"}}
+	if err := rule.Validate(); err == nil {
+		t.Error("未知字段的映射应校验失败")
+	}
+}
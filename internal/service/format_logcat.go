@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/pkg/xtime"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// logcat 时间：MM-dd（默认）、yyyy-MM-dd（-v year）或秒级时间戳（-v epoch），支持毫秒/微秒
+const logcatTimestamp = `(?P<timestamp>(?:\d{4}-)?\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}\.\d{3,9}|\d+\.\d{3,9})`
+
+// logcat 单字母级别
+const logcatLevel = `(?P<level>[VDIWEFA])`
+
+// 各输出格式的整行正则
+var logcatPatterns = map[string]*regexp.Regexp{
+	// 08-02 15:56:24.951  2669  2723 D Sensors : PlsSensor: readEvents
+	"threadtime": regexp.MustCompile(`^` + logcatTimestamp + `\s+(?P<process>\d+)\s+(?P<thread>\d+)\s+` + logcatLevel + `\s+(?P<tag>.*?)\s*:(?:\s(?P<message>.*))?$`),
+	// 08-02 15:56:24.951 D/Sensors ( 2669): PlsSensor: readEvents
+	"time": regexp.MustCompile(`^` + logcatTimestamp + `\s+` + logcatLevel + `/(?P<tag>[^(]*?)\s*\(\s*(?P<process>\d+)\):(?:\s(?P<message>.*))?$`),
+	// D/Sensors ( 2669): PlsSensor: readEvents
+	"brief": regexp.MustCompile(`^` + logcatLevel + `/(?P<tag>[^(]*?)\s*\(\s*(?P<process>\d+)\):(?:\s(?P<message>.*))?$`),
+	// D( 2669) PlsSensor: readEvents  (Sensors)
+	"process": regexp.MustCompile(`^` + logcatLevel + `\(\s*(?P<process>\d+)\)\s(?P<message>.*?)\s+\((?P<tag>[^()]*)\)$`),
+	// [ 08-02 15:56:24.951  2669: 2723 D/Sensors ]（消息内容在后续行）
+	"long": regexp.MustCompile(`^\[\s+` + logcatTimestamp + `\s+(?P<process>\d+):\s*(?P<thread>\d+)\s+` + logcatLevel + `/(?P<tag>.*?)\s+\]$`),
+}
+
+// 自动识别时依次尝试的格式
+var logcatAutoOrder = []string{"threadtime", "time", "long", "brief", "process"}
+
+// logcat 缓冲区切换标记：--------- beginning of main
+var logcatBufferMarker = regexp.MustCompile(`^-+\s*(?:beginning of|switch to)\s+(\S+)`)
+
+// logcat 单字母级别对应的完整级别名称
+var logcatLevels = map[string]string{
+	"V": "VERBOSE",
+	"D": "DEBUG",
+	"I": "INFO",
+	"W": "WARN",
+	"E": "ERROR",
+	"F": "FATAL",
+	"A": "FATAL",
+}
+
+// logcatMatcher Android logcat 预设格式，无需编写正则
+type logcatMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+func newLogcatMatcher(rule *config.LogParseRule) (*logcatMatcher, error) {
+	m := &logcatMatcher{}
+	switch rule.Format {
+	case "":
+		for _, name := range logcatAutoOrder {
+			m.patterns = append(m.patterns, logcatPatterns[name])
+		}
+	case "year", "epoch":
+		m.patterns = []*regexp.Regexp{logcatPatterns["threadtime"]}
+	default:
+		pattern, ok := logcatPatterns[rule.Format]
+		if !ok {
+			return nil, fmt.Errorf("不支持的logcat格式: %s", rule.Format)
+		}
+		m.patterns = []*regexp.Regexp{pattern}
+	}
+	return m, nil
+}
+
+func (m *logcatMatcher) match(line string) lineFields {
+	var f lineFields
+	if marker := logcatBufferMarker.FindStringSubmatch(line); marker != nil {
+		f.matched = true
+		f.skip = true
+		f.carry = map[string]interface{}{"buffer": marker[1]}
+		return f
+	}
+	for _, pattern := range m.patterns {
+		match := pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		for i, name := range pattern.SubexpNames() {
+			if name != "" {
+				f.set(name, match[i])
+			}
+		}
+		f.matched = true
+		f.header = pattern == logcatPatterns["long"]
+		f.level = logcatLevels[f.level]
+		// 与现有项目规则保持一致，模块取logcat标签以便按模块筛选
+		f.module = f.tag
+		if t, ok := logcatTime(f.timestamp); ok {
+			f.time = &t
+			// 仅秒级时间戳（-v epoch）为绝对时间
+			f.zoneless = strings.Contains(f.timestamp, "-")
+		}
+		return f
+	}
+	return f
+}
+
+// logcatTime 按时间的形式（月日/年月日/时间戳）解析logcat时间
+func logcatTime(timestamp string) (time.Time, bool) {
+	if timestamp == "" {
+		return time.Time{}, false
+	}
+	if !strings.Contains(timestamp, "-") {
+		return xtime.ParseEpoch(timestamp)
+	}
+	// 布局中省略小数秒，解析时可接受任意位数的毫秒/微秒
+	layout := "01-02 15:04:05"
+	if strings.Count(timestamp, "-") == 2 {
+		layout = "2006-01-02 15:04:05"
+	}
+	t, err := time.Parse(layout, strings.Join(strings.Fields(timestamp), " "))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogcatPresets(t *testing.T) {
+	tests := []struct {
+		format  string
+		line    string
+		want    lineFields
+		logTime time.Time
+	}{
+		{
+			format:  "threadtime",
+			line:    `08-02 15:56:24.951  2669  2723 D Sensors : PlsSensor: readEvents (type=3, code=40)`,
+			want:    lineFields{process: "2669", thread: "2723", level: "DEBUG", tag: "Sensors", message: "PlsSensor: readEvents (type=3, code=40)"},
+			logTime: time.Date(0, 8, 2, 15, 56, 24, 951000000, time.UTC),
+		},
+		{
+			format: "threadtime",
+			line:   `08-02 15:56:25.039  2564    2600 E VehicleHAL-BW-System: L_ACC:[1,1], C_ACC:[3,3]`,
+			want:   lineFields{process: "2564", thread: "2600", level: "ERROR", tag: "VehicleHAL-BW-System", message: "L_ACC:[1,1], C_ACC:[3,3]"},
+		},
+		{
+			format: "threadtime",
+			line:   `08-02 15:56:24.966  2564  2616 I VehicleHAL-BW-DFSK-SD-DVR: read persist.vendor.bw.dvr.auto_date:false`,
+			want:   lineFields{process: "2564", thread: "2616", level: "INFO", tag: "VehicleHAL-BW-DFSK-SD-DVR", message: "read persist.vendor.bw.dvr.auto_date:false"},
+		},
+		{
+			format:  "year",
+			line:    `2024-08-02 15:56:24.951  2669  2723 W Sensors: x`,
+			want:    lineFields{process: "2669", thread: "2723", level: "WARN", tag: "Sensors", message: "x"},
+			logTime: time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC),
+		},
+		{
+			format:  "epoch",
+			line:    `1722614184.951  2669  2723 V Sensors: x`,
+			want:    lineFields{process: "2669", thread: "2723", level: "VERBOSE", tag: "Sensors", message: "x"},
+			logTime: time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC),
+		},
+		{
+			format: "time",
+			line:   `08-02 15:56:24.951 F/libc    ( 2669): Fatal signal 11 (SIGSEGV)`,
+			want:   lineFields{process: "2669", level: "FATAL", tag: "libc", message: "Fatal signal 11 (SIGSEGV)"},
+		},
+		{
+			format: "brief",
+			line:   `I/ActivityManager(  512): Start proc com.example`,
+			want:   lineFields{process: "512", level: "INFO", tag: "ActivityManager", message: "Start proc com.example"},
+		},
+		{
+			format: "process",
+			line:   `W(  512) Unable to start service  (ActivityManager)`,
+			want:   lineFields{process: "512", level: "WARN", tag: "ActivityManager", message: "Unable to start service"},
+		},
+		{
+			format: "long",
+			line:   `[ 08-02 15:56:24.951  2669: 2723 D/Sensors ]`,
+			want:   lineFields{process: "2669", thread: "2723", level: "DEBUG", tag: "Sensors"},
+		},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{tt.format, ""} {
+			m, err := newLogcatMatcher(&config.LogParseRule{Type: config.RuleTypeLogcat, Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := m.match(tt.line)
+			if !got.matched {
+				t.Errorf("[%s] %q 未匹配", format, tt.line)
+				continue
+			}
+			if got.process != tt.want.process || got.thread != tt.want.thread || got.level != tt.want.level ||
+				got.tag != tt.want.tag || got.module != tt.want.tag || got.message != tt.want.message {
+				t.Errorf("[%s] %q = %+v, want %+v", format, tt.line, got, tt.want)
+			}
+			if !tt.logTime.IsZero() && (got.time == nil || !got.time.Equal(tt.logTime)) {
+				t.Errorf("[%s] %q time = %v, want %v", format, tt.line, got.time, tt.logTime)
+			}
+		}
+	}
+}
+
+func TestLogcatParseFile(t *testing.T) {
+	content := `--------- beginning of main
+08-02 15:56:24.951  2669  2723 D Sensors : readEvents
+--------- beginning of crash
+08-02 15:56:25.100  1000  1000 E AndroidRuntime: FATAL EXCEPTION: main
+
+[ 08-02 15:56:25.200  2669: 2723 D/Sensors ]
+line one
+line two
+`
+	path := filepath.Join(t.TempDir(), "logcat.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{Type: config.RuleTypeLogcat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 缓冲区标记不单独成条，只标记后续条目
+	if logFile.Total != 3 {
+		t.Fatalf("Total = %d, want 3", logFile.Total)
+	}
+	wantBuffers := []string{"main", "crash", "crash"}
+	for i, entry := range logFile.Entries {
+		if entry.Attrs["buffer"] != wantBuffers[i] {
+			t.Errorf("entry %d buffer = %v, want %s", i, entry.Attrs["buffer"], wantBuffers[i])
+		}
+	}
+	if first := logFile.Entries[0]; first.Line != 2 || first.TimeSource == model.TimeSourceSynthesized {
+		t.Errorf("first entry = line %d, %s", first.Line, first.TimeSource)
+	}
+	long := logFile.Entries[2]
+	if long.Message != "line one\nline two" || long.Line != 6 || long.EndLine != 8 {
+		t.Errorf("long entry = %q [%d-%d]", long.Message, long.Line, long.EndLine)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"log-tools-go/pkg/xlogfmt"
+	"strings"
+)
+
+// 未配置映射的字段按 go-kit、slog 文本格式的常见键名依次查找
+var defaultLogfmtFields = map[string][]string{
+	"timestamp":  {"time", "ts", "t"},
+	"level":      {"level", "lvl"},
+	"message":    {"msg", "message"},
+	"thread":     {"thread", "goroutine"},
+	"module":     {"logger", "module", "component"},
+	"process":    {"pid"},
+	"tag":        {"tag"},
+	"class":      {"caller", "source"},
+	"class_line": {"line"},
+}
+
+// logfmtMatcher logfmt行解析（key=value key2="quoted value"），其余键值对保留为扩展属性
+type logfmtMatcher struct {
+	fieldMapping
+}
+
+func newLogfmtMatcher(rule *config.LogParseRule) (*logfmtMatcher, error) {
+	return &logfmtMatcher{fieldMapping: newFieldMapping(rule, defaultLogfmtFields)}, nil
+}
+
+func (m *logfmtMatcher) match(line string) lineFields {
+	pairs, err := xlogfmt.Parse(line)
+	if err != nil {
+		return lineFields{}
+	}
+	// 没有任何 key=value 的行（如启动横幅）按普通文本处理
+	obj := make(map[string]interface{})
+	for _, pair := range pairs {
+		if pair.HasValue {
+			setLogfmtPath(obj, pair.Key, pair.Value)
+		}
+	}
+	if len(obj) == 0 {
+		return lineFields{}
+	}
+	return m.extract(obj)
+}
+
+// setLogfmtPath 点号分隔的键（如 slog 分组输出的 req.id）展开为嵌套对象，与JSON格式的属性结构保持一致
+func setLogfmtPath(obj map[string]interface{}, key, value string) {
+	parts := strings.Split(key, ".")
+	node := obj
+	for i, part := range parts[:len(parts)-1] {
+		child, ok := node[part].(map[string]interface{})
+		if !ok {
+			if _, exists := node[part]; exists {
+				// 与已有的非对象值冲突时保留完整键名
+				node[strings.Join(parts[i:], ".")] = value
+				return
+			}
+			child = make(map[string]interface{})
+			node[part] = child
+		}
+		node = child
+	}
+	node[parts[len(parts)-1]] = value
+}
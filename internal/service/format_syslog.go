@@ -0,0 +1,214 @@
+package service
+
+import (
+	"fmt"
+	"log-tools-go/internal/config"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RFC 5424: <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+var syslog5424Pattern = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) ?(.*)$`)
+
+// RFC 3164: [<PRI>]Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG，兼容 rsyslog 输出的 RFC3339 时间
+var syslog3164Pattern = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}|\d{4}-\d{2}-\d{2}T\S+)\s+(\S+)\s+(?:([^\s:\[]+)(?:\[([^\]]*)\])?:\s?)?(.*)$`)
+
+// syslog 设施名称（按编号）
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// syslog 严重级别名称（按编号）
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// syslog 严重级别对应的日志级别
+var syslogLevels = []string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+
+// syslogMatcher 解析 RFC 3164 / RFC 5424 格式的 syslog
+// APP-NAME 映射为模块，PROCID 映射为进程，MSGID 映射为标签，其余信息保留为扩展属性
+type syslogMatcher struct {
+	rfc3164 bool
+	rfc5424 bool
+}
+
+func newSyslogMatcher(rule *config.LogParseRule) (*syslogMatcher, error) {
+	switch rule.Format {
+	case "":
+		return &syslogMatcher{rfc3164: true, rfc5424: true}, nil
+	case "rfc3164":
+		return &syslogMatcher{rfc3164: true}, nil
+	case "rfc5424":
+		return &syslogMatcher{rfc5424: true}, nil
+	default:
+		return nil, fmt.Errorf("不支持的syslog格式: %s", rule.Format)
+	}
+}
+
+func (m *syslogMatcher) match(line string) lineFields {
+	if m.rfc5424 {
+		if f, ok := parseSyslog5424(line); ok {
+			return f
+		}
+	}
+	if m.rfc3164 {
+		if f, ok := parseSyslog3164(line); ok {
+			return f
+		}
+	}
+	return lineFields{}
+}
+
+func parseSyslog5424(line string) (lineFields, bool) {
+	var f lineFields
+	match := syslog5424Pattern.FindStringSubmatch(line)
+	if match == nil {
+		return f, false
+	}
+	attrs := map[string]interface{}{"version": match[2]}
+	if !applySyslogPri(&f, attrs, match[1]) {
+		return f, false
+	}
+	if match[3] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, match[3])
+		if err != nil {
+			return f, false
+		}
+		f.timestamp = match[3]
+		f.time = &t
+	}
+	if match[4] != "-" {
+		attrs["hostname"] = match[4]
+	}
+	f.module = syslogNil(match[5])
+	f.process = syslogNil(match[6])
+	f.tag = syslogNil(match[7])
+
+	rest := match[8]
+	if strings.HasPrefix(rest, "-") {
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "-"), " ")
+	} else {
+		sd, n, err := parseStructuredData(rest)
+		if err != nil {
+			return f, false
+		}
+		attrs["sd"] = sd
+		rest = strings.TrimPrefix(rest[n:], " ")
+	}
+	f.message = strings.TrimPrefix(rest, "\xEF\xBB\xBF")
+	f.attrs = attrs
+	f.matched = true
+	return f, true
+}
+
+func parseSyslog3164(line string) (lineFields, bool) {
+	var f lineFields
+	match := syslog3164Pattern.FindStringSubmatch(line)
+	if match == nil {
+		return f, false
+	}
+	attrs := map[string]interface{}{"hostname": match[3]}
+	if match[1] != "" && !applySyslogPri(&f, attrs, match[1]) {
+		return f, false
+	}
+	f.timestamp = match[2]
+	if strings.Contains(match[2], "T") {
+		t, err := time.Parse(time.RFC3339Nano, match[2])
+		if err != nil {
+			return f, false
+		}
+		f.time = &t
+	} else {
+		// RFC 3164 时间不含年份和时区，年份在合并阶段按规则配置推断
+		t, err := time.Parse("Jan 2 15:04:05", strings.Join(strings.Fields(match[2]), " "))
+		if err != nil {
+			return f, false
+		}
+		f.time = &t
+		f.zoneless = true
+	}
+	f.module = match[4]
+	f.process = match[5]
+	f.message = match[6]
+	f.attrs = attrs
+	f.matched = true
+	return f, true
+}
+
+// applySyslogPri 将 PRI 解码为设施和严重级别
+func applySyslogPri(f *lineFields, attrs map[string]interface{}, pri string) bool {
+	value, err := strconv.Atoi(pri)
+	if err != nil || value > 191 {
+		return false
+	}
+	facility, severity := value/8, value%8
+	attrs["facility"] = syslogFacilities[facility]
+	attrs["severity"] = syslogSeverities[severity]
+	f.level = syslogLevels[severity]
+	return true
+}
+
+// syslogNil RFC 5424 中 "-" 表示空值
+func syslogNil(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// parseStructuredData 解析 RFC 5424 结构化数据 [id key="value" ...][id2 ...]
+// 返回 SD-ID -> 参数 的映射以及消耗的字节数
+func parseStructuredData(s string) (map[string]interface{}, int, error) {
+	sd := make(map[string]interface{})
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		if i >= len(s) || i == start {
+			return nil, 0, fmt.Errorf("结构化数据ID错误")
+		}
+		id := s[start:i]
+		params := make(map[string]interface{})
+		for i < len(s) && s[i] == ' ' {
+			i++
+			start = i
+			for i < len(s) && s[i] != '=' {
+				i++
+			}
+			if i+1 >= len(s) || s[i+1] != '"' {
+				return nil, 0, fmt.Errorf("结构化数据参数错误")
+			}
+			name := s[start:i]
+			i += 2
+			var value strings.Builder
+			for i < len(s) && s[i] != '"' {
+				// 参数值中 \" \\ \] 为转义字符
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+					i++
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, 0, fmt.Errorf("结构化数据参数值未闭合")
+			}
+			i++
+			params[name] = value.String()
+		}
+		if i >= len(s) || s[i] != ']' {
+			return nil, 0, fmt.Errorf("结构化数据未闭合")
+		}
+		i++
+		sd[id] = params
+	}
+	if i == 0 {
+		return nil, 0, fmt.Errorf("缺少结构化数据")
+	}
+	return sd, i, nil
+}
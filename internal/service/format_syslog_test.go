@@ -0,0 +1,89 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyslog5424(t *testing.T) {
+	m, err := newSyslogMatcher(&config.LogParseRule{Type: config.RuleTypeSyslog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := m.match(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication\]"][examplePriority@32473 class="high"] An application event`)
+	if !f.matched {
+		t.Fatal("未匹配")
+	}
+	if f.level != "INFO" || f.module != "evntslog" || f.process != "" || f.tag != "ID47" || f.message != "An application event" {
+		t.Errorf("fields = %+v", f)
+	}
+	if f.attrs["facility"] != "local4" || f.attrs["severity"] != "notice" || f.attrs["hostname"] != "mymachine.example.com" {
+		t.Errorf("attrs = %+v", f.attrs)
+	}
+	wantSD := map[string]interface{}{
+		"exampleSDID@32473":     map[string]interface{}{"iut": "3", "eventSource": `App"lication]`},
+		"examplePriority@32473": map[string]interface{}{"class": "high"},
+	}
+	if !reflect.DeepEqual(f.attrs["sd"], wantSD) {
+		t.Errorf("sd = %+v", f.attrs["sd"])
+	}
+	if f.time == nil || !f.time.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Errorf("time = %v", f.time)
+	}
+
+	f = m.match(`<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su 123 - - 'su root' failed for lonvick on /dev/pts/8`)
+	if f.level != "FATAL" || f.process != "123" || f.tag != "" || f.message != "'su root' failed for lonvick on /dev/pts/8" || f.attrs["facility"] != "auth" {
+		t.Errorf("fields = %+v", f)
+	}
+}
+
+func TestSyslog3164(t *testing.T) {
+	m, err := newSyslogMatcher(&config.LogParseRule{Type: config.RuleTypeSyslog, Format: "rfc3164"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := m.match(`<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8`)
+	if f.level != "FATAL" || f.module != "su" || f.process != "230" || f.message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("fields = %+v", f)
+	}
+	if f.attrs["hostname"] != "mymachine" || f.attrs["severity"] != "crit" {
+		t.Errorf("attrs = %+v", f.attrs)
+	}
+	f = m.match(`Jan  2 03:04:05 router kernel: [ 1.234] eth0 up`)
+	if !f.matched || f.level != "" || f.module != "kernel" || f.message != "[ 1.234] eth0 up" {
+		t.Errorf("fields = %+v", f)
+	}
+}
+
+func TestSyslogYearRollover(t *testing.T) {
+	content := `Dec 30 23:59:58 host app[1]: a
+Dec 31 23:59:59 host app[1]: b
+Jan  1 00:00:01 host app[1]: c
+`
+	path := filepath.Join(t.TempDir(), "messages")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2025, 1, 1, 0, 10, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{Type: config.RuleTypeSyslog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantYears := []int{2024, 2024, 2025}
+	for i, entry := range logFile.Entries {
+		if entry.LogTime.Year() != wantYears[i] {
+			t.Errorf("entry %d time = %v, want year %d", i, entry.LogTime, wantYears[i])
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"log-tools-go/pkg/xframe"
+	"regexp"
+)
+
+// FrameWarningScene 帧解码告警（校验失败、帧不完整等）的标注场景，关键词为告警类型
+const FrameWarningScene = "帧解码告警"
+
+// frameDecoder 预编译的帧解码器
+type frameDecoder struct {
+	name    string
+	pattern *regexp.Regexp
+	decoder *xframe.Decoder
+}
+
+// frameWarning 解码一行时产生的告警，组装条目时转换为标注
+type frameWarning struct {
+	decoder string
+	xframe.Warning
+}
+
+// compileFrameDecoders 预编译规则中的帧解码器
+func compileFrameDecoders(decoders []config.FrameDecoder) ([]frameDecoder, error) {
+	compiled := make([]frameDecoder, 0, len(decoders))
+	var errs []error
+	for _, d := range decoders {
+		r, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			errs = append(errs, fieldError("decoders."+d.Name, fmt.Errorf("帧解码器%s正则表达式错误: %w", d.Name, err)))
+			continue
+		}
+		decoder, err := xframe.New(d.Protocol)
+		if err != nil {
+			errs = append(errs, fieldError("decoders."+d.Name, fmt.Errorf("帧解码器%s协议错误: %w", d.Name, err)))
+			continue
+		}
+		compiled = append(compiled, frameDecoder{name: d.Name, pattern: r, decoder: decoder})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// decode 提取并解码行中的帧，行中没有帧或帧头不符时返回nil
+func (d frameDecoder) decode(line string) *xframe.Frame {
+	match := d.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	text := match[0]
+	if len(match) > 1 {
+		text = match[1]
+	}
+	data, err := xframe.ParseHex(text)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	frame, err := d.decoder.Decode(data)
+	if err != nil {
+		return nil
+	}
+	return frame
+}
+
+// frameAttrs 将解码结果转换为扩展属性
+func frameAttrs(frame *xframe.Frame) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(frame.Fields)+3)
+	for k, v := range frame.Fields {
+		attrs[k] = v
+	}
+	if frame.CommandID != "" {
+		attrs["command_id"] = frame.CommandID
+		attrs["command"] = frame.Command
+	}
+	if frame.Checksum != nil {
+		if *frame.Checksum {
+			attrs["checksum"] = "ok"
+		} else {
+			attrs["checksum"] = "mismatch"
+		}
+	}
+	return attrs
+}
+
+// applyFrameDecoders 解码行中的帧，结果以解码器名称为键写入扩展属性，告警暂存到行中
+func applyFrameDecoders(f *lineFields, decoders []frameDecoder, line string) {
+	for _, d := range decoders {
+		frame := d.decode(line)
+		if frame == nil {
+			continue
+		}
+		if f.attrs == nil {
+			f.attrs = make(map[string]interface{}, len(decoders))
+		}
+		f.attrs[d.name] = frameAttrs(frame)
+		for _, w := range frame.Warnings {
+			f.frameWarnings = append(f.frameWarnings, frameWarning{decoder: d.name, Warning: w})
+		}
+	}
+}
+
+// annotateFrameWarnings 将帧解码告警记录为条目的标注，条目颜色改为WARN级别的颜色，
+// 告警来自多行记录的续行时在描述中注明行号
+func (p *LogParser) annotateFrameWarnings(entry *model.LogEntry, warnings []frameWarning, lineNumber int) {
+	if len(warnings) == 0 {
+		return
+	}
+	color := p.config.LevelColor("WARN")
+	for _, w := range warnings {
+		desc := w.Message
+		if lineNumber != entry.Line {
+			desc = fmt.Sprintf("第%d行: %s", lineNumber, desc)
+		}
+		entry.Annotations = append(entry.Annotations, model.LogAnnotation{
+			EntryID: entry.ID,
+			Line:    entry.Line,
+			Module:  w.decoder,
+			Scene:   FrameWarningScene,
+			Keyword: w.Kind,
+			Desc:    desc,
+			Color:   color,
+		})
+	}
+	if color != "" {
+		entry.Color = color
+	}
+}
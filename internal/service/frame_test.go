@@ -0,0 +1,84 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"log-tools-go/pkg/xframe"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrameDecoders(t *testing.T) {
+	content := "2024-08-02 15:56:24 DispatchThread-MCU [write] L0-> 55 78 d9 09 50 ea 00 09 f2\n" +
+		"2024-08-02 15:56:25 DispatchThread-MCU [write] L0-> 55 78 d9 09 50 eb 00 01 07\n" +
+		"2024-08-02 15:56:26 DispatchThread-MCU [read] L0<- aa bb 01\n"
+	path := filepath.Join(t.TempDir(), "mcu.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rule := &config.LogParseRule{
+		Thread:  `^\S+ \S+ (\S+)`,
+		Message: `^\S+ \S+ \S+ (.*)$`,
+		Decoders: []config.FrameDecoder{{
+			Name:    "mcu",
+			Pattern: `L0(?:->|<-)\s*([0-9a-fA-F ]+)$`,
+			Protocol: xframe.Protocol{
+				Header:  "55 78",
+				Command: &xframe.Field{Offset: 2, Size: 1},
+				Length:  &xframe.Field{Offset: 3, Size: 1},
+				Commands: []xframe.Command{{ID: "d9", Name: "按键上报", Fields: []xframe.Field{
+					{Name: "key", Offset: 6, Size: 1},
+					{Name: "state", Offset: 7, Size: 1},
+				}}},
+				Fields:   []xframe.Field{{Name: "seq", Offset: 4, Size: 2}},
+				Checksum: &xframe.Checksum{Algorithm: "sum8"},
+			},
+		}},
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{LogLevels: map[string]string{"warn": "#e6a23c", "unknown": "#6c757d"}}
+	parser, err := NewLogParserWithRule(cfg, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logFile.Entries) != 3 {
+		t.Fatalf("entries = %d", len(logFile.Entries))
+	}
+
+	ok := logFile.Entries[0]
+	attrs, _ := ok.Attrs["mcu"].(map[string]interface{})
+	if attrs["command"] != "按键上报" || attrs["command_id"] != "d9" || attrs["seq"] != int64(0x50ea) || attrs["state"] != int64(9) || attrs["checksum"] != "ok" {
+		t.Errorf("attrs = %v", ok.Attrs)
+	}
+	if len(ok.Annotations) != 0 || ok.Color != "#6c757d" {
+		t.Errorf("entry 0 = %s %+v", ok.Color, ok.Annotations)
+	}
+
+	// 校验失败记录为告警标注
+	bad := logFile.Entries[1]
+	if attrs, _ := bad.Attrs["mcu"].(map[string]interface{}); attrs["checksum"] != "mismatch" || attrs["state"] != int64(1) {
+		t.Errorf("attrs = %v", bad.Attrs)
+	}
+	if len(bad.Annotations) != 1 || bad.Color != "#e6a23c" {
+		t.Fatalf("entry 1 = %s %+v", bad.Color, bad.Annotations)
+	}
+	if a := bad.Annotations[0]; a.Scene != FrameWarningScene || a.Keyword != xframe.WarnChecksum || a.Module != "mcu" || a.EntryID != bad.ID {
+		t.Errorf("annotation = %+v", a)
+	}
+
+	// 帧头不符的数据不解码
+	if _, ok := logFile.Entries[2].Attrs["mcu"]; ok || len(logFile.Entries[2].Annotations) != 0 {
+		t.Errorf("entry 2 = %+v", logFile.Entries[2])
+	}
+
+	rule.Decoders[0].Protocol.Fields[0].Name = "command"
+	if err := rule.Validate(); err == nil {
+		t.Error("与固定属性重名的字段应校验失败")
+	}
+}
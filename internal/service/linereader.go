@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// truncatedMarker 超长行截断后追加的标记，参数为原始长度（字节）
+const truncatedMarker = " …[已截断，原长%d字节]"
+
+// lineReader 按行读取，不限制行长度；超过limit（大于0时）的行截断并追加标记，超出部分直接丢弃不占用内存
+type lineReader struct {
+	r     *bufio.Reader
+	limit int
+	buf   []byte
+}
+
+func newLineReader(r io.Reader, limit int) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), limit: limit}
+}
+
+// next 读取下一行（去掉行尾的\n和\r）及其原始长度，原始长度超过limit时返回的行已截断，文件结束时返回 io.EOF
+func (l *lineReader) next() (line string, size int, err error) {
+	l.buf = l.buf[:0]
+	var tail [2]byte // 行的最后两个字节，超长行的缓冲区只保留开头部分，需单独记录用于识别换行符
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		size += len(chunk)
+		if l.limit <= 0 || len(l.buf) <= l.limit {
+			l.buf = append(l.buf, chunk...)
+		}
+		for _, b := range chunk[max(0, len(chunk)-2):] {
+			tail[0], tail[1] = tail[1], b
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return "", 0, err
+		}
+		if err == io.EOF && size == 0 {
+			return "", 0, io.EOF
+		}
+		break
+	}
+	if size > 0 && tail[1] == '\n' {
+		size--
+		if size > 0 && tail[0] == '\r' {
+			size--
+		}
+	}
+	if l.limit <= 0 || size <= l.limit {
+		return string(l.buf[:size]), size, nil
+	}
+	cut := l.limit
+	// 截断位置退回到完整的UTF-8字符边界
+	for cut > 0 && !utf8.RuneStart(l.buf[cut]) {
+		cut--
+	}
+	return string(l.buf[:cut]) + fmt.Sprintf(truncatedMarker, size), size, nil
+}
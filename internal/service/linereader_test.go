@@ -0,0 +1,41 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("中", 40000) // 120000字节，超过bufio默认缓冲区
+	input := "short\r\n" + long + "\n" + "x" + long + "\r\nlast"
+	lr := newLineReader(strings.NewReader(input), 100)
+	want := []struct {
+		line string
+		size int
+	}{
+		{"short", 5},
+		{strings.Repeat("中", 33) + fmt.Sprintf(truncatedMarker, len(long)), len(long)},
+		{"x" + strings.Repeat("中", 33) + fmt.Sprintf(truncatedMarker, len(long)+1), len(long) + 1},
+		{"last", 4},
+	}
+	for i, w := range want {
+		line, size, err := lr.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != w.line || size != w.size {
+			t.Errorf("line %d = %.40q (%d), want %.40q (%d)", i, line, size, w.line, w.size)
+		}
+	}
+	if _, _, err := lr.next(); err != io.EOF {
+		t.Errorf("err = %v, want EOF", err)
+	}
+
+	// limit为0时不截断
+	lr = newLineReader(strings.NewReader(long+"\n"), 0)
+	if line, _, err := lr.next(); err != nil || line != long {
+		t.Errorf("unlimited line length = %d, %v", len(line), err)
+	}
+}
@@ -1,131 +1,279 @@
 package service
 
 import (
-	"bufio"
-	"compress/gzip"
 	"crypto/md5"
 	"fmt"
 	"io"
 	"log-tools-go/internal/config"
 	"log-tools-go/internal/model"
-	"log-tools-go/pkg/xmatch"
-	"log-tools-go/pkg/xtime"
+	"log-tools-go/pkg/xcharset"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 )
 
+// LogParser 日志解析器，创建后只读，可被多个协程并发使用
 type LogParser struct {
-	config   *config.Config
-	rule     *config.LogParseRule
-	lastTime *time.Time
+	config    *config.Config
+	rule      *compiledRule
+	project   string     // 项目名称，随文件记录保存
+	annotator *annotator // 项目的场景关键词，为nil时不标注
 }
 
-func NewLogParserWithRule(cfg *config.Config, rule *config.LogParseRule) *LogParser {
-	return &LogParser{config: cfg, rule: rule}
-}
-
-// 解析日志文件
-func (p *LogParser) ParseLogFile(filePath string) (*model.LogFile, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("打开文件失败: %w", err)
-	}
-	defer file.Close()
-
-	var reader io.Reader = file
-
-	// 检查是否为gzip压缩文件
-	if strings.HasSuffix(strings.ToLower(filePath), ".gz") {
-		gzReader, err := gzip.NewReader(file)
+// NewLogParserWithRule 创建解析器，规则中的正则在此预编译，非法正则直接返回错误
+func NewLogParserWithRule(cfg *config.Config, rule *config.LogParseRule) (*LogParser, error) {
+	p := &LogParser{config: cfg}
+	if rule != nil {
+		compiled, err := compileRule(rule)
 		if err != nil {
-			return nil, fmt.Errorf("解压gzip文件失败: %w", err)
+			return nil, fmt.Errorf("解析规则错误: %w", err)
 		}
-		defer gzReader.Close()
-		reader = gzReader
+		p.rule = compiled
 	}
+	return p, nil
+}
 
-	// 生成文件ID
-	fileID := p.generateFileID(filePath)
-
-	// 获取文件信息
-	fileInfo, err := file.Stat()
+// NewLogFile 为待解析的文件生成文件信息
+func (p *LogParser) NewLogFile(filePath string) (*model.LogFile, error) {
+	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("获取文件信息失败: %w", err)
 	}
-
-	logFile := &model.LogFile{
-		ID:       fileID,
+	// 编码在解析前确定，随文件记录一起保存
+	reader, charset, err := openLogReader(filePath, p.encoding())
+	if err != nil {
+		return nil, err
+	}
+	reader.Close()
+	return &model.LogFile{
+		ID:       p.generateFileID(filePath),
 		Name:     filepath.Base(filePath),
 		Size:     fileInfo.Size(),
 		UploadAt: time.Now(),
+		Encoding: charset,
+		RawPath:  filePath,
+		Project:  p.project,
 		Entries:  []model.LogEntry{},
+	}, nil
+}
+
+// encoding 规则指定的文件编码，为空时自动识别
+func (p *LogParser) encoding() string {
+	if p.rule == nil {
+		return ""
 	}
+	return p.rule.encoding
+}
 
-	scanner := bufio.NewScanner(reader)
-	lineNumber := 0
+// WithEncoding 返回使用指定文件编码的解析器副本（如上传时指定的编码），为空或auto时自动识别
+func (p *LogParser) WithEncoding(charset string) (*LogParser, error) {
+	name, err := xcharset.Normalize(charset)
+	if err != nil {
+		return nil, err
+	}
+	if p.rule == nil {
+		return p, nil
+	}
+	rule := *p.rule
+	rule.encoding = name
+	parser := *p
+	parser.rule = &rule
+	return &parser, nil
+}
 
-	for scanner.Scan() {
-		lineNumber++
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		entry := p.parseLogLine(line, lineNumber, filePath)
-		if entry != nil {
-			logFile.Entries = append(logFile.Entries, *entry)
-		}
+// 解析日志文件，所有条目保存在内存中
+func (p *LogParser) ParseLogFile(filePath string) (*model.LogFile, error) {
+	logFile, err := p.NewLogFile(filePath)
+	if err != nil {
+		return nil, err
 	}
-	p.lastTime = nil
-	logFile.Total = len(logFile.Entries)
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("读取文件失败: %w", err)
+	err = p.ParseLogFileStream(logFile, filePath, func(entries []model.LogEntry) error {
+		logFile.Entries = append(logFile.Entries, entries...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return logFile, nil
 }
 
-// 解析日志行
-func (p *LogParser) parseLogLine(line string, lineNumber int, source string) *model.LogEntry {
-	rule := p.rule
-	timestampStr := xmatch.Match(rule.Timestamp, line)
-	timestamp, err := xtime.ParseTime(timestampStr, rule.TimestampFormat)
+// openLogReader 打开日志文件，gzip/bzip2/xz/zstd压缩文件按文件头识别并流式解压，
+// 内容按charset（为空时自动识别）转换为UTF-8，返回实际使用的编码
+func openLogReader(filePath string, charset string) (io.ReadCloser, string, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		if p.lastTime != nil {
-			timestamp = *p.lastTime
-		} else {
-			timestamp = time.Now()
+		return nil, "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	rc := &readCloser{Reader: file, closers: []io.Closer{file}}
+	decompressed, closer, err := decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	rc.Reader = decompressed
+	if closer != nil {
+		rc.closers = []io.Closer{closer, file}
+	}
+	reader, name, err := xcharset.NewReader(rc.Reader, charset)
+	if err != nil {
+		rc.Close()
+		return nil, "", err
+	}
+	rc.Reader = reader
+	return rc, name, nil
+}
+
+// readCloser 关闭时依次关闭多层reader
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// parseLine 解析单行日志的字段和时间（并行阶段，不依赖上下文）
+func (p *LogParser) parseLine(raw string, lineNumber int) *parsedLine {
+	line := strings.TrimSpace(raw)
+	if line == "" {
+		return nil
+	}
+	pl := &parsedLine{number: lineNumber, raw: raw, line: line, size: len(raw)}
+	pl.fields = p.rule.match(line)
+	if pl.fields.time != nil {
+		pl.timestamp = *pl.fields.time
+		pl.timeParsed = true
+		pl.zoneless = pl.fields.zoneless
+		return pl
+	}
+	if pl.fields.timestamp == "" || p.rule.timeLayout == nil {
+		return pl
+	}
+	timestamp, err := p.rule.timeLayout.Parse(pl.fields.timestamp)
+	if err == nil {
+		pl.timestamp = timestamp
+		pl.timeParsed = true
+		pl.zoneless = !p.rule.timeLayout.HasZone()
+	}
+	return pl
+}
+
+// assemble 按行序合并解析结果，返回已完成的日志条目（顺序阶段，维护上下文状态）
+func (p *LogParser) assemble(state *parseState, pl *parsedLine) *model.LogEntry {
+	recordLine(state.diag, pl)
+	// 缓冲区标记等行只影响后续条目的属性，本身不是日志记录
+	if pl.fields.skip {
+		state.carry = mergeAttrs(state.carry, pl.fields.carry)
+		return nil
+	}
+	// 非记录起始行（如堆栈信息）追加到上一条日志
+	if state.current != nil && !p.isRecordStart(pl) {
+		p.appendLine(state.current, pl.raw, pl.number)
+		// 续行中提取到的自定义字段（如堆栈中的错误码）补充到记录，不覆盖已有值
+		for k, v := range pl.fields.attrs {
+			if _, ok := state.current.Attrs[k]; !ok {
+				if state.current.Attrs == nil {
+					state.current.Attrs = make(map[string]interface{})
+				}
+				state.current.Attrs[k] = v
+			}
 		}
+		p.annotateFrameWarnings(state.current, pl.fields.frameWarnings, pl.number)
+		return nil
+	}
+	done := state.current
+	timestamp, timeSource := state.resolveTime(pl, p.rule.location)
+	state.diag.TimeSources[timeSource]++
+	state.lastTime = &timestamp
+	if pl.fields.carry != nil {
+		state.carry = mergeAttrs(state.carry, pl.fields.carry)
+	}
+	state.current = p.parseLogLine(pl.line, pl.fields, timestamp, pl.number, state.logFile.ID, state.source)
+	state.current.TimeSource = timeSource
+	p.annotateFrameWarnings(state.current, pl.fields.frameWarnings, pl.number)
+	if state.carry != nil {
+		state.current.Attrs = mergeAttrs(state.carry, state.current.Attrs)
+	}
+	return done
+}
+
+// mergeAttrs 合并两组属性并返回新的map，后者优先
+func mergeAttrs(base, attrs map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(attrs))
+	for k, v := range base {
+		merged[k] = v
 	}
-	p.lastTime = &timestamp
-	module := xmatch.Match(rule.Module, line)
-	level := xmatch.Match(rule.Level, line)
-	message := xmatch.Match(rule.Message, line)
-	if message == "" {
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	return merged
+}
+
+// isRecordStart 判断一行是否为一条新日志记录的开始
+func (p *LogParser) isRecordStart(pl *parsedLine) bool {
+	if p.rule.recordStart != nil {
+		return p.rule.recordStart.MatchString(pl.line)
+	}
+	if p.rule.RecordStartTime {
+		return pl.fields.timestamp != "" && pl.timeParsed
+	}
+	if p.rule.startOnMatch {
+		return pl.fields.matched
+	}
+	return true
+}
+
+// appendLine 将续行追加到多行日志记录
+func (p *LogParser) appendLine(entry *model.LogEntry, raw string, lineNumber int) {
+	raw = strings.TrimRight(raw, " \t\r")
+	entry.Content += "\n" + raw
+	if entry.Message == "" {
+		// 记录头行之后的第一行即为消息内容
+		entry.Message = strings.TrimSpace(raw)
+	} else {
+		entry.Message += "\n" + raw
+	}
+	entry.EndLine = lineNumber
+}
+
+// 解析日志行
+func (p *LogParser) parseLogLine(line string, fields lineFields, timestamp time.Time, lineNumber int, fileID string, source string) *model.LogEntry {
+	message := fields.message
+	if message == "" && !fields.header {
 		message = line
 	}
-	process := xmatch.Match(rule.Process, line)
-	thread := xmatch.Match(rule.Thread, line)
-	tag := xmatch.Match(rule.Tag, line)
-	class := xmatch.Match(rule.Class, line)
-	classLine := xmatch.Match(rule.ClassLine, line)
-	//fmt.Printf("匹配结果: %s, %s, %s\n", timestampStr, level, message)
+	level := config.NormalizeLevel(fields.level, p.rule.levels)
+	color := p.config.LevelColor(level)
+	if color == "" {
+		color = "#6c757d"
+	}
 	return &model.LogEntry{
-		ID:        fmt.Sprintf("%s_%d", p.generateFileID(source), lineNumber),
+		ID:        fmt.Sprintf("%s_%d", fileID, lineNumber),
 		LogTime:   timestamp,
 		SaveTime:  time.Now(),
-		Module:    module,
+		Module:    fields.module,
 		Level:     level,
-		Process:   &process,
-		Thread:    &thread,
-		Class:     &class,
-		ClassLine: &classLine,
-		Tag:       &tag,
+		Severity:  config.LevelSeverity(level),
+		Process:   &fields.process,
+		Thread:    &fields.thread,
+		Class:     &fields.class,
+		ClassLine: &fields.classLine,
+		Tag:       &fields.tag,
 		Message:   message,
 		Content:   line,
 		Source:    source,
 		Line:      lineNumber,
-		Color:     "#6c757d",
+		EndLine:   lineNumber,
+		Color:     color,
+		Attrs:     fields.attrs,
 	}
 }
 
@@ -161,6 +309,11 @@ func (p *LogParser) matchesFilter(entry model.LogEntry, filter model.LogFilter)
 		}
 	}
 
+	// 检查最低级别
+	if filter.MinLevel != "" && entry.Severity < config.LevelSeverity(filter.MinLevel) {
+		return false
+	}
+
 	// 检查关键词
 	if len(filter.Keywords) > 0 {
 		keywordMatch := false
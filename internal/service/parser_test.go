@@ -0,0 +1,221 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLevelNormalization(t *testing.T) {
+	content := `D boot
+warning disk
+Err failed
+NOTE custom
+PANIC crash
+`
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{LogLevels: map[string]string{"warn": "#ffc107", "unknown": "#343a40"}}
+	parser, err := NewLogParserWithRule(cfg, &config.LogParseRule{
+		Level:  `^(\w+)`,
+		Levels: map[string]string{"note": "info"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		level    string
+		severity int
+	}{
+		{"DEBUG", 2}, {"WARN", 4}, {"ERROR", 5}, {"INFO", 3}, {"FATAL", 6},
+	}
+	for i, w := range want {
+		entry := logFile.Entries[i]
+		if entry.Level != w.level || entry.Severity != w.severity {
+			t.Errorf("entry %d = %s(%d), want %s(%d)", i, entry.Level, entry.Severity, w.level, w.severity)
+		}
+	}
+	if logFile.Entries[1].Color != "#ffc107" || logFile.Entries[0].Color != "#343a40" {
+		t.Errorf("colors = %s, %s", logFile.Entries[1].Color, logFile.Entries[0].Color)
+	}
+
+	filtered := parser.FilterLogs(logFile.Entries, model.LogFilter{MinLevel: "warn"})
+	if len(filtered) != 3 {
+		t.Errorf("MinLevel=warn 过滤后 %d 条, want 3", len(filtered))
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	content := `2024-08-02 15:56:24 INFO started
+2024-13-45 99:00:00 WARN bad time
+unstructured-noise
+2024-08-02 15:56:26 ERROR a much longer line that should be reported as the longest one in the file
+`
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{
+		Timestamp:       `^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})`,
+		TimestampFormat: "yyyy-MM-dd HH:mm:ss",
+		Level:           `^\S+ \S+ (\w+)`,
+		Thread:          `\[(\w+)\]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := logFile.Diagnostics
+	if d.TotalLines != 4 || d.MatchedLines != 3 || d.FieldMatches["timestamp"] != 3 || d.FieldMatches["level"] != 3 || d.FieldMatches["thread"] != 0 {
+		t.Errorf("counts = %+v", d)
+	}
+	if d.TimestampFailed != 1 || len(d.TimestampErrors) != 1 || d.TimestampErrors[0].Line != 2 || d.TimestampErrors[0].Value != "2024-13-45 99:00:00" {
+		t.Errorf("timestamp errors = %+v", d.TimestampErrors)
+	}
+	if len(d.UnmatchedSamples) != 1 || d.UnmatchedSamples[0].Line != 3 {
+		t.Errorf("unmatched = %+v", d.UnmatchedSamples)
+	}
+	if len(d.LongestLines) != 4 || d.LongestLines[0].Line != 4 {
+		t.Errorf("longest = %+v", d.LongestLines)
+	}
+	if d.TimeSources[model.TimeSourceParsed] != 2 || d.TimeSources[model.TimeSourceInherited] != 2 {
+		t.Errorf("time sources = %+v", d.TimeSources)
+	}
+}
+
+func TestDiagnosticLineTruncate(t *testing.T) {
+	// 一个字节的前缀使多字节字符跨越截断位置
+	raw := "x" + strings.Repeat("日志", diagnosticTextLimit)
+	line := diagnosticLine(&parsedLine{number: 1, raw: raw, size: len(raw)}, "")
+	if !utf8.ValidString(line.Text) || !strings.HasSuffix(line.Text, "...") || len(line.Text) > diagnosticTextLimit+3 {
+		t.Errorf("text = %q", line.Text)
+	}
+}
+
+func TestCustomFields(t *testing.T) {
+	content := `{"msg":"order done","req":"r-1"} user=42 cost=1.5s
+{"msg":"order failed","req":"r-2"} user=abc cost=80ms
+  caused by code=E1001
+`
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{
+		Type:        config.RuleTypeJSON,
+		RecordStart: `^\{`,
+		CustomFields: []config.CustomField{
+			{Name: "user_id", Pattern: `user=(\S+)`, Type: config.FieldTypeInt},
+			{Name: "cost", Pattern: `cost=(\S+)`, Type: config.FieldTypeDuration},
+			{Name: "code", Pattern: `E\d+`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{"req": "r-1", "user_id": int64(42), "cost": 1500.0},
+		{"req": "r-2", "cost": 80.0, "code": "E1001"},
+	}
+	if len(logFile.Entries) != len(want) {
+		t.Fatalf("entries = %d, want %d", len(logFile.Entries), len(want))
+	}
+	for i, w := range want {
+		if got := logFile.Entries[i].Attrs; !reflect.DeepEqual(got, w) {
+			t.Errorf("entry %d attrs = %v, want %v", i, got, w)
+		}
+	}
+
+	rule := config.LogParseRule{CustomFields: []config.CustomField{{Name: "x", Pattern: "x", Type: "bool"}}}
+	if err := rule.Validate(); err == nil {
+		t.Error("不支持的字段类型应返回错误")
+	}
+}
+
+func TestMultiLineRecords(t *testing.T) {
+	content := "\tat Orphan.run(Orphan.java:1)\n" +
+		"2024-08-02 15:56:24 ERROR request failed\n" +
+		"java.lang.NullPointerException: id\n" +
+		"\tat com.demo.Service.handle(Service.java:42)   \n" +
+		"Caused by: java.io.IOException\n" +
+		"\t... 3 more\n" +
+		"2024-08-02 15:56:25 INFO next\n"
+	path := filepath.Join(t.TempDir(), "stack.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := config.LogParseRule{
+		Timestamp: `^(\S+ \S+)`,
+		Level:     `^\S+ \S+ (\w+)`,
+		Message:   `^\S+ \S+ \w+ (.*)$`,
+	}
+	byRegex, byTime := base, base
+	byRegex.RecordStart = `^\d{4}-\d{2}-\d{2} `
+	byTime.RecordStartTime = true
+
+	for name, rule := range map[string]config.LogParseRule{"record_start": byRegex, "record_start_time": byTime} {
+		parser, err := NewLogParserWithRule(nil, &rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logFile, err := parser.ParseLogFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logFile.Entries) != 3 {
+			t.Fatalf("%s: entries = %d", name, len(logFile.Entries))
+		}
+
+		// 文件开头的续行没有可追加的记录，单独成为一条
+		first := logFile.Entries[0]
+		if first.Line != 1 || first.EndLine != 1 || first.TimeSource != model.TimeSourceSynthesized {
+			t.Errorf("%s: first = %d-%d %s", name, first.Line, first.EndLine, first.TimeSource)
+		}
+
+		// 堆栈合并到上一条记录，行尾空白被去除
+		stack := logFile.Entries[1]
+		wantMessage := "request failed\njava.lang.NullPointerException: id\n\tat com.demo.Service.handle(Service.java:42)\n" +
+			"Caused by: java.io.IOException\n\t... 3 more"
+		if stack.Line != 2 || stack.EndLine != 6 || stack.Level != "ERROR" || stack.Message != wantMessage {
+			t.Errorf("%s: stack = %d-%d %s %q", name, stack.Line, stack.EndLine, stack.Level, stack.Message)
+		}
+		if wantContent := "2024-08-02 15:56:24 ERROR " + wantMessage; stack.Content != wantContent {
+			t.Errorf("%s: content = %q", name, stack.Content)
+		}
+
+		if next := logFile.Entries[2]; next.Line != 7 || next.EndLine != 7 || next.Message != "next" {
+			t.Errorf("%s: next = %d-%d %q", name, next.Line, next.EndLine, next.Message)
+		}
+	}
+
+	// 未配置记录起始时每行一条
+	parser, err := NewLogParserWithRule(nil, &base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logFile.Entries) != 7 {
+		t.Errorf("entries = %d, want 7", len(logFile.Entries))
+	}
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"log-tools-go/pkg/xtime"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pipelineChunkLines = 1024 // 每个解析任务包含的行数
+	pipelineBatchSize  = 500  // 每批交给sink的日志条目数
+	detectSampleLines  = 200  // 识别时间格式时读取的样本行数
+)
+
+// parsedLine 并行解析阶段的单行结果
+type parsedLine struct {
+	number     int
+	raw        string
+	line       string
+	fields     lineFields
+	timestamp  time.Time
+	timeParsed bool
+	zoneless   bool // 时间不含时区
+	size       int  // 原始行长度（字节）
+	truncated  bool // 超过单行最大长度被截断
+}
+
+// lineChunk 一组连续的行，由解析协程处理后通过result按原顺序取回
+type lineChunk struct {
+	start  int
+	lines  []string
+	sizes  map[int]int // 被截断的行在块中的下标 -> 原始长度
+	result chan []*parsedLine
+}
+
+// parseState 单个文件的顺序解析状态，只在合并阶段使用
+type parseState struct {
+	logFile  *model.LogFile
+	source   string
+	lastTime *time.Time
+	current  *model.LogEntry
+	carry    map[string]interface{} // 对后续条目生效的属性
+	diag     *model.ParseDiagnostics
+
+	refTime   time.Time  // 推断年份的参考时间（文件修改时间、上传时间或指定年份）
+	year      int        // 当前推断的年份
+	lastMonth time.Month // 上一条无年份时间的月份，用于识别跨年
+}
+
+// resolveTime 确定条目的时间及其来源
+// 解析出的时间补全年份并按规则时区解释；无法解析时沿用上一条日志的时间，首条日志取参考时间
+func (s *parseState) resolveTime(pl *parsedLine, location *time.Location) (time.Time, string) {
+	if !pl.timeParsed {
+		if s.lastTime != nil {
+			return *s.lastTime, model.TimeSourceInherited
+		}
+		return s.refTime, model.TimeSourceSynthesized
+	}
+	t := pl.timestamp
+	loc := t.Location()
+	if pl.zoneless {
+		loc = location
+	}
+	if t.Year() == 0 {
+		return s.inferYear(t, loc), model.TimeSourceInferred
+	}
+	if pl.zoneless {
+		t = wallTime(t, t.Year(), loc)
+	}
+	return t, model.TimeSourceParsed
+}
+
+// inferYear 为不含年份的时间（如 RFC 3164、logcat）补全年份
+// 首条记录取参考时间所在年份，若因此晚于参考时间则取上一年；之后月份大幅回退（如12月到1月）时视为跨年
+func (s *parseState) inferYear(t time.Time, loc *time.Location) time.Time {
+	if s.year == 0 {
+		s.year = s.refTime.Year()
+		if wallTime(t, s.year, loc).After(s.refTime) {
+			s.year--
+		}
+	} else if s.lastMonth-t.Month() >= 6 {
+		s.year++
+	}
+	s.lastMonth = t.Month()
+	return wallTime(t, s.year, loc)
+}
+
+// wallTime 以t的月日时分秒在指定年份和时区构造时间
+func wallTime(t time.Time, year int, loc *time.Location) time.Time {
+	return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// initTimeReference 按规则的年份来源设置推断年份的参考时间
+func (p *LogParser) initTimeReference(state *parseState, filePath string) {
+	switch p.rule.YearSource {
+	case config.YearSourceExplicit:
+		state.year = p.rule.Year
+		state.refTime = time.Date(p.rule.Year, 1, 1, 0, 0, 0, 0, p.rule.location)
+		return
+	case config.YearSourceUpload:
+		if !state.logFile.UploadAt.IsZero() {
+			state.refTime = state.logFile.UploadAt
+			return
+		}
+	default:
+		if fileInfo, err := os.Stat(filePath); err == nil {
+			state.refTime = fileInfo.ModTime()
+			return
+		}
+	}
+	state.refTime = time.Now()
+}
+
+// detectTimeLayout 未配置时间格式时，从文件开头的样本行中识别时间格式
+func (p *LogParser) detectTimeLayout(filePath string) *xtime.Layout {
+	reader, _, err := openLogReader(filePath, p.encoding())
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	lr := newLineReader(reader, p.config.LineLimit())
+	var lines []string
+	for len(lines) < detectSampleLines {
+		line, _, err := lr.next()
+		if err != nil {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return p.detectLinesTimeLayout(lines)
+}
+
+// detectLinesTimeLayout 从样本行提取的时间字段中识别时间格式
+func (p *LogParser) detectLinesTimeLayout(lines []string) *xtime.Layout {
+	var samples []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// 格式自身已解析出时间（如JSON、logcat）的行无需识别
+		if f := p.rule.match(line); f.time == nil && f.timestamp != "" {
+			samples = append(samples, f.timestamp)
+		}
+	}
+	layout, _ := xtime.Detect(samples)
+	return layout
+}
+
+// withTimeLayout 返回使用指定时间格式的解析器副本
+func (p *LogParser) withTimeLayout(layout *xtime.Layout) *LogParser {
+	rule := *p.rule
+	rule.timeLayout = layout
+	parser := *p
+	parser.rule = &rule
+	return &parser
+}
+
+// ParseLogFileStream 流式解析日志文件
+// 读取协程按块分发行，多个解析协程并行提取字段，合并阶段按原始行序处理多行记录和时间继承，
+// 每积累一批条目调用一次sink，内存占用与文件大小无关
+func (p *LogParser) ParseLogFileStream(logFile *model.LogFile, filePath string, sink func([]model.LogEntry) error) error {
+	if p.rule == nil {
+		return fmt.Errorf("未指定解析规则")
+	}
+	// 按文件记录中已确定的编码读取，保证识别时间格式与解析使用相同的编码
+	if logFile.Encoding != "" && logFile.Encoding != p.encoding() {
+		var err error
+		if p, err = p.WithEncoding(logFile.Encoding); err != nil {
+			return err
+		}
+	}
+	if p.rule.timeLayout == nil {
+		if layout := p.detectTimeLayout(filePath); layout != nil {
+			p = p.withTimeLayout(layout)
+		}
+	}
+	reader, charset, err := openLogReader(filePath, p.encoding())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	logFile.Encoding = charset
+
+	workers := runtime.NumCPU()
+	jobs := make(chan *lineChunk, workers)
+	ordered := make(chan *lineChunk, workers*2)
+	done := make(chan struct{})
+	readerDone := make(chan struct{})
+	var wg sync.WaitGroup
+	// 提前返回时通知读取协程退出，并等待所有协程结束后再关闭文件
+	defer func() {
+		close(done)
+		<-readerDone
+		wg.Wait()
+	}()
+
+	// 读取协程
+	var readErr error
+	go func() {
+		defer close(readerDone)
+		defer close(jobs)
+		defer close(ordered)
+		lines := newLineReader(reader, p.config.LineLimit())
+		lineNumber := 0
+		chunk := &lineChunk{start: 1, result: make(chan []*parsedLine, 1)}
+		send := func() bool {
+			select {
+			case ordered <- chunk:
+			case <-done:
+				return false
+			}
+			select {
+			case jobs <- chunk:
+			case <-done:
+				return false
+			}
+			return true
+		}
+		for {
+			line, size, err := lines.next()
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				break
+			}
+			lineNumber++
+			if size > len(line) {
+				if chunk.sizes == nil {
+					chunk.sizes = make(map[int]int)
+				}
+				chunk.sizes[len(chunk.lines)] = size
+			}
+			chunk.lines = append(chunk.lines, line)
+			if len(chunk.lines) >= pipelineChunkLines {
+				if !send() {
+					return
+				}
+				chunk = &lineChunk{start: lineNumber + 1, result: make(chan []*parsedLine, 1)}
+			}
+		}
+		if len(chunk.lines) > 0 {
+			send()
+		}
+	}()
+
+	// 解析协程
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range jobs {
+				parsed := make([]*parsedLine, 0, len(chunk.lines))
+				for i, raw := range chunk.lines {
+					if pl := p.parseLine(raw, chunk.start+i); pl != nil {
+						if size, ok := chunk.sizes[i]; ok {
+							pl.size = size
+							pl.truncated = true
+						}
+						parsed = append(parsed, pl)
+					}
+				}
+				chunk.result <- parsed
+			}
+		}()
+	}
+
+	// 合并阶段：按块的原始顺序取回结果
+	state := &parseState{logFile: logFile, source: filePath, diag: newDiagnostics(p.rule)}
+	logFile.Diagnostics = state.diag
+	p.initTimeReference(state, filePath)
+	batch := make([]model.LogEntry, 0, pipelineBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if p.annotator != nil {
+			for i := range batch {
+				p.annotator.annotate(&batch[i])
+			}
+		}
+		if err := sink(batch); err != nil {
+			return err
+		}
+		logFile.Total += len(batch)
+		batch = make([]model.LogEntry, 0, pipelineBatchSize)
+		return nil
+	}
+	for chunk := range ordered {
+		for _, pl := range <-chunk.result {
+			entry := p.assemble(state, pl)
+			if entry == nil {
+				continue
+			}
+			batch = append(batch, *entry)
+			if len(batch) >= pipelineBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if readErr != nil {
+		return fmt.Errorf("读取文件失败: %w", readErr)
+	}
+	if state.current != nil {
+		batch = append(batch, *state.current)
+	}
+	return flush()
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestTimeInference(t *testing.T) {
+	content := `no timestamp yet
+12-31 23:59:59.000  100  100 I Boot: a
+01-01 00:00:01.000  100  100 I Boot: b
+garbage line
+`
+	path := filepath.Join(t.TempDir(), "logcat.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("时区数据不可用")
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{
+		Type:            config.RuleTypeNamed,
+		Pattern:         `^(?P<timestamp>\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3})\s+(?P<process>\d+)\s+(?P<thread>\d+) (?P<level>\w) (?P<tag>\w+): (?P<message>.*)$`,
+		TimestampFormat: "MM-dd HH:mm:ss.SSS",
+		YearSource:      config.YearSourceExplicit,
+		Year:            2023,
+		TimeZone:        "Asia/Shanghai",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		time   time.Time
+		source string
+	}{
+		{time.Date(2023, 1, 1, 0, 0, 0, 0, shanghai), model.TimeSourceSynthesized},
+		{time.Date(2023, 12, 31, 23, 59, 59, 0, shanghai), model.TimeSourceInferred},
+		{time.Date(2024, 1, 1, 0, 0, 1, 0, shanghai), model.TimeSourceInferred},
+		{time.Date(2024, 1, 1, 0, 0, 1, 0, shanghai), model.TimeSourceInherited},
+	}
+	if len(logFile.Entries) != len(want) {
+		t.Fatalf("entries = %d, want %d", len(logFile.Entries), len(want))
+	}
+	for i, w := range want {
+		entry := logFile.Entries[i]
+		if !entry.LogTime.Equal(w.time) || entry.TimeSource != w.source {
+			t.Errorf("entry %d = %v (%s), want %v (%s)", i, entry.LogTime, entry.TimeSource, w.time, w.source)
+		}
+	}
+}
+
+func TestTimeZoneKeepsExplicitOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("{\"ts\":\"2024-08-02T15:56:24Z\",\"msg\":\"x\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{Type: config.RuleTypeJSON, TimeZone: "Asia/Shanghai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := logFile.Entries[0]
+	if !entry.LogTime.Equal(time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)) || entry.TimeSource != model.TimeSourceParsed {
+		t.Errorf("entry = %v (%s)", entry.LogTime, entry.TimeSource)
+	}
+}
+
+func TestDetectTimeLayout(t *testing.T) {
+	content := `2024-08-02 15:56:24,951 INFO start
+2024-08-02 15:56:25,003 WARN slow
+`
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{
+		Timestamp: `^(\S+ \S+)`,
+		Level:     `^\S+ \S+ (\w+)`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 8, 2, 15, 56, 25, 3000000, time.UTC)
+	if entry := logFile.Entries[1]; !entry.LogTime.Equal(want) || entry.TimeSource != model.TimeSourceParsed {
+		t.Errorf("entry = %v (%s), want %v", entry.LogTime, entry.TimeSource, want)
+	}
+}
+
+func TestParseEncodedFile(t *testing.T) {
+	content, err := simplifiedchinese.GBK.NewEncoder().String("2024-08-02 15:56:24 ERROR 电机控制器通信超时\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "gbk.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rule := &config.LogParseRule{Level: `^\S+ \S+ (\w+)`, Message: `^\S+ \S+ \w+ (.*)$`}
+	parser, err := NewLogParserWithRule(nil, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logFile.Encoding != "gbk" || logFile.Entries[0].Message != "电机控制器通信超时" {
+		t.Errorf("encoding = %s, message = %q", logFile.Encoding, logFile.Entries[0].Message)
+	}
+
+	// 指定编码时不再识别
+	rule.Encoding = "windows-1252"
+	parser, err = NewLogParserWithRule(nil, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err = parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logFile.Encoding != "windows-1252" {
+		t.Errorf("encoding = %s", logFile.Encoding)
+	}
+}
+
+func TestTruncateLongLines(t *testing.T) {
+	payload := strings.Repeat("A", 200*1024)
+	content := "2024-08-02 15:56:24 INFO short\n2024-08-02 15:56:25 INFO data=" + payload + "\n2024-08-02 15:56:26 INFO after\n"
+	path := filepath.Join(t.TempDir(), "big.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Storage: config.StorageConfig{MaxLineSize: 1024}}
+	parser, err := NewLogParserWithRule(cfg, &config.LogParseRule{Level: `^\S+ \S+ (\w+)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logFile.Entries) != 3 {
+		t.Fatalf("entries = %d", len(logFile.Entries))
+	}
+	if content := logFile.Entries[1].Content; len(content) > 1100 || !strings.Contains(content, "已截断") {
+		t.Errorf("content = %.80q... (%d)", content, len(content))
+	}
+	d := logFile.Diagnostics
+	if d.TruncatedLines != 1 || d.TruncatedSamples[0].Line != 2 || d.LongestLines[0].Length != len(payload)+30 {
+		t.Errorf("diagnostics = %d %+v %d", d.TruncatedLines, d.TruncatedSamples[0].Line, d.LongestLines[0].Length)
+	}
+	if logFile.RawPath != path {
+		t.Errorf("raw path = %s", logFile.RawPath)
+	}
+}
+
+// writeStackLog 写入每条3行（头行和两行堆栈）的日志，返回文件路径
+func writeStackLog(t *testing.T, records int) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&b, "2024-08-02 15:56:24 INFO record %d\n\tat Foo.bar(Foo.java:%d)\n\tat Main.main(Main.java:1)\n", i, i)
+	}
+	path := filepath.Join(t.TempDir(), "stack.log")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newStackParser(t *testing.T) *LogParser {
+	t.Helper()
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{
+		Timestamp:   `^(\S+ \S+)`,
+		Level:       `^\S+ \S+ (\w+)`,
+		Message:     `^\S+ \S+ \w+ (.*)$`,
+		RecordStart: `^\d{4}-`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parser
+}
+
+func TestPipelineChunkBoundary(t *testing.T) {
+	// 每块的行数不是3的倍数，部分记录的头行和堆栈分属不同的块
+	records := 1200
+	path := writeStackLog(t, records)
+	logFile := &model.LogFile{ID: "f"}
+	var batches []int
+	var entries []model.LogEntry
+	err := newStackParser(t).ParseLogFileStream(logFile, path, func(batch []model.LogEntry) error {
+		batches = append(batches, len(batch))
+		entries = append(entries, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{pipelineBatchSize, pipelineBatchSize, records - 2*pipelineBatchSize}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches = %v, want %v", batches, want)
+	}
+	if len(entries) != records || logFile.Total != records {
+		t.Fatalf("entries = %d, total = %d", len(entries), logFile.Total)
+	}
+	for i, entry := range entries {
+		want := fmt.Sprintf("record %d\n\tat Foo.bar(Foo.java:%d)\n\tat Main.main(Main.java:1)", i, i)
+		if entry.Line != 3*i+1 || entry.EndLine != 3*i+3 || entry.Message != want {
+			t.Fatalf("entry %d = %d-%d %q", i, entry.Line, entry.EndLine, entry.Message)
+		}
+	}
+}
+
+func TestPipelineSinkError(t *testing.T) {
+	// 文件足够大，sink出错时读取和解析协程仍有未处理的块
+	path := writeStackLog(t, 2*pipelineChunkLines*(runtime.NumCPU()+2))
+	before := runtime.NumGoroutine()
+	errSink := errors.New("sink failed")
+	calls := 0
+	err := newStackParser(t).ParseLogFileStream(&model.LogFile{ID: "f"}, path, func(batch []model.LogEntry) error {
+		calls++
+		if calls == 2 {
+			return errSink
+		}
+		return nil
+	})
+	if !errors.Is(err, errSink) || calls != 2 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("goroutines = %d, before = %d", n, before)
+	}
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/pkg/xcharset"
+	"log-tools-go/pkg/xmatch"
+	"log-tools-go/pkg/xtime"
+	"regexp"
+	"time"
+)
+
+// lineFields 单行日志提取出的字段
+type lineFields struct {
+	timestamp string
+	process   string
+	thread    string
+	level     string
+	module    string
+	class     string
+	classLine string
+	tag       string
+	message   string
+
+	time          *time.Time             // 格式自身已解析出的时间（如JSON中的数值时间戳）
+	zoneless      bool                   // time 中不含时区信息，需按规则配置的时区解释
+	attrs         map[string]interface{} // 未映射到固定字段的扩展属性
+	carry         map[string]interface{} // 对本行及后续条目生效的属性（如logcat缓冲区标记）
+	frameWarnings []frameWarning         // 帧解码告警
+	matched       bool                   // 行是否符合预设格式
+	header        bool                   // 记录头行，消息内容在后续行中（如logcat -v long）
+	skip          bool                   // 不形成日志条目的行（如logcat缓冲区标记），只更新carry
+}
+
+// set 按字段名设置字段值
+func (f *lineFields) set(name, value string) {
+	switch name {
+	case "timestamp":
+		f.timestamp = value
+	case "process":
+		f.process = value
+	case "thread":
+		f.thread = value
+	case "level":
+		f.level = value
+	case "module":
+		f.module = value
+	case "class":
+		f.class = value
+	case "class_line":
+		f.classLine = value
+	case "tag":
+		f.tag = value
+	case "message":
+		f.message = value
+	}
+}
+
+// get 按字段名获取字段值
+func (f *lineFields) get(name string) string {
+	switch name {
+	case "timestamp":
+		return f.timestamp
+	case "process":
+		return f.process
+	case "thread":
+		return f.thread
+	case "level":
+		return f.level
+	case "module":
+		return f.module
+	case "class":
+		return f.class
+	case "class_line":
+		return f.classLine
+	case "tag":
+		return f.tag
+	case "message":
+		return f.message
+	}
+	return ""
+}
+
+// lineMatcher 将一行日志解析为字段，实现需可被并发调用
+type lineMatcher interface {
+	match(line string) lineFields
+}
+
+// compiledRule 预编译后的解析规则，创建后只读
+type compiledRule struct {
+	*config.LogParseRule
+	matcher      lineMatcher
+	recordStart  *regexp.Regexp    // 多行日志记录起始正则
+	startOnMatch bool              // 以符合预设格式的行作为记录起始
+	location     *time.Location    // 不含时区的时间所属时区
+	timeLayout   *xtime.Layout     // 预编译的时间格式，未配置时按文件自动识别
+	levels       map[string]string // 原始级别（大写）-> 标准级别
+	customFields []customField     // 自定义提取字段
+	decoders     []frameDecoder    // 帧解码器
+	encoding     string            // 文件编码，为空时按文件自动识别
+}
+
+func init() {
+	// 保存规则时按解析时的编译步骤校验
+	config.RuleCompiler = func(rule *config.LogParseRule) error {
+		_, err := compileRule(rule)
+		return err
+	}
+}
+
+// ruleFieldError 规则中某个配置项的错误，field 为配置项名（如 level、record_start、custom_fields.user）
+type ruleFieldError struct {
+	field string
+	err   error
+}
+
+func (e *ruleFieldError) Error() string { return e.err.Error() }
+
+func (e *ruleFieldError) Unwrap() error { return e.err }
+
+// fieldError 将错误标记为属于指定配置项，err 为nil时返回nil
+func fieldError(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ruleFieldError{field: field, err: err}
+}
+
+// compileRule 校验规则的配置项并预编译其中的所有正则和格式，非法时返回错误；
+// 各配置项的编译错误会全部收集并以 ruleFieldError 标记所属配置项
+func compileRule(rule *config.LogParseRule) (*compiledRule, error) {
+	if err := rule.ValidateSettings(); err != nil {
+		return nil, err
+	}
+	c := &compiledRule{LogParseRule: rule, levels: config.LevelAliases(rule.Levels)}
+	var errs []error
+	var err error
+	switch rule.Type {
+	case "", config.RuleTypeRegex:
+		c.matcher, err = newRegexMatcher(rule)
+	case config.RuleTypeNamed:
+		c.matcher, err = newNamedMatcher(rule)
+	case config.RuleTypeJSON:
+		c.matcher, err = newJSONMatcher(rule)
+	case config.RuleTypeLogfmt:
+		c.matcher, err = newLogfmtMatcher(rule)
+	case config.RuleTypeLogcat:
+		c.matcher, err = newLogcatMatcher(rule)
+		c.startOnMatch = true
+	case config.RuleTypeSyslog:
+		c.matcher, err = newSyslogMatcher(rule)
+	case config.RuleTypeAccess:
+		c.matcher, err = newAccessMatcher(rule)
+	default:
+		err = fmt.Errorf("不支持的规则类型: %s", rule.Type)
+	}
+	errs = append(errs, err)
+	if rule.RecordStart != "" {
+		if c.recordStart, err = regexp.Compile(rule.RecordStart); err != nil {
+			errs = append(errs, fieldError("record_start", fmt.Errorf("record_start正则表达式错误: %w", err)))
+		}
+	}
+	c.customFields, err = compileCustomFields(rule.CustomFields)
+	errs = append(errs, err)
+	c.decoders, err = compileFrameDecoders(rule.Decoders)
+	errs = append(errs, err)
+	if rule.TimestampFormat != "" {
+		if c.timeLayout, err = xtime.Compile(rule.TimestampFormat); err != nil {
+			errs = append(errs, fieldError("timestamp_format", fmt.Errorf("timestamp_format错误: %w", err)))
+		}
+	}
+	if c.location, err = time.LoadLocation(rule.TimeZone); err != nil {
+		errs = append(errs, fieldError("time_zone", fmt.Errorf("时区错误: %w", err)))
+	}
+	if c.encoding, err = xcharset.Normalize(rule.Encoding); err != nil {
+		errs = append(errs, fieldError("encoding", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// match 从一行日志中提取所有字段
+func (c *compiledRule) match(line string) lineFields {
+	f := c.matcher.match(line)
+	applyCustomFields(&f, c.customFields, line)
+	applyFrameDecoders(&f, c.decoders, line)
+	return f
+}
+
+// regexMatcher 逐字段正则
+type regexMatcher struct {
+	fields map[string]*regexp.Regexp
+}
+
+func newRegexMatcher(rule *config.LogParseRule) (*regexMatcher, error) {
+	m := &regexMatcher{fields: make(map[string]*regexp.Regexp)}
+	patterns := rule.FieldPatterns()
+	var errs []error
+	for _, name := range config.RuleFieldNames {
+		if patterns[name] == "" {
+			continue
+		}
+		r, err := regexp.Compile(patterns[name])
+		if err != nil {
+			errs = append(errs, fieldError(name, fmt.Errorf("字段%s正则表达式错误: %w", name, err)))
+			continue
+		}
+		m.fields[name] = r
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *regexMatcher) match(line string) lineFields {
+	var f lineFields
+	for name, r := range m.fields {
+		f.set(name, xmatch.MatchRegexp(r, line))
+	}
+	return f
+}
+
+// namedMatcher 单个命名分组正则
+type namedMatcher struct {
+	pattern *regexp.Regexp
+}
+
+func newNamedMatcher(rule *config.LogParseRule) (*namedMatcher, error) {
+	if rule.Pattern == "" {
+		return nil, fieldError("pattern", fmt.Errorf("named模式必须配置pattern"))
+	}
+	r, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, fieldError("pattern", fmt.Errorf("pattern正则表达式错误: %w", err))
+	}
+	return &namedMatcher{pattern: r}, nil
+}
+
+func (m *namedMatcher) match(line string) lineFields {
+	var f lineFields
+	match := m.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return f
+	}
+	for i, name := range m.pattern.SubexpNames() {
+		if name != "" {
+			f.set(name, match[i])
+		}
+	}
+	return f
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"io"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"log-tools-go/pkg/xtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	detectRuleSampleLines = 200 // 识别项目规则时读取的样本行数
+	fieldScoreWeight      = 0.6 // 字段匹配在评分中的权重，其余为时间解析
+)
+
+// ScoreProjectRules 按字段匹配率和时间解析成功率为所有项目规则评分，按评分降序返回
+func ScoreProjectRules(lines []string) []model.RuleScore {
+	samples := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			samples = append(samples, line)
+		}
+	}
+	scores := make([]model.RuleScore, 0, len(config.ProjectRules))
+	for i := range config.ProjectRules {
+		project := &config.ProjectRules[i]
+		score := model.RuleScore{ProjectName: project.ProjectName}
+		rule, err := compileRule(&project.Rule)
+		if err != nil {
+			score.Error = err.Error()
+		} else {
+			score = scoreRule(rule, samples)
+			score.ProjectName = project.ProjectName
+		}
+		scores = append(scores, score)
+	}
+	// 评分相同时保持配置顺序
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	return scores
+}
+
+// DetectProjectRule 返回样本行上评分最高的项目规则，没有任何规则匹配时返回nil
+func DetectProjectRule(lines []string) (*config.LogProjectRule, model.RuleScore) {
+	scores := ScoreProjectRules(lines)
+	if project := bestProjectRule(scores); project != nil {
+		return project, scores[0]
+	}
+	return nil, model.RuleScore{}
+}
+
+// bestProjectRule 返回评分（已按降序排列）最高的项目规则，没有任何规则匹配时返回nil
+func bestProjectRule(scores []model.RuleScore) *config.LogProjectRule {
+	if len(scores) == 0 || scores[0].Score == 0 {
+		return nil
+	}
+	for i := range config.ProjectRules {
+		if config.ProjectRules[i].ProjectName == scores[0].ProjectName {
+			return &config.ProjectRules[i]
+		}
+	}
+	return nil
+}
+
+// scoreRule 计算单个规则的评分
+func scoreRule(rule *compiledRule, samples []string) model.RuleScore {
+	var score model.RuleScore
+	if len(samples) == 0 {
+		return score
+	}
+	configured := configuredFields(rule)
+	fields := make([]lineFields, len(samples))
+	var timestamps []string
+	for i, line := range samples {
+		fields[i] = rule.match(line)
+		if fields[i].time == nil && fields[i].timestamp != "" {
+			timestamps = append(timestamps, fields[i].timestamp)
+		}
+	}
+	layout := rule.timeLayout
+	if layout == nil {
+		layout, _ = xtime.Detect(timestamps)
+	}
+
+	var matched, fieldSum, timed float64
+	for _, f := range fields {
+		count := 0
+		for _, name := range configured {
+			if f.get(name) != "" {
+				count++
+			}
+		}
+		switch {
+		case len(configured) > 0:
+			fieldSum += float64(count) / float64(len(configured))
+		case f.matched:
+			// 预设格式（json、logcat等）以整行是否符合格式计分
+			fieldSum++
+		}
+		if f.matched || count > 0 {
+			matched++
+		}
+		if f.time != nil {
+			timed++
+		} else if f.timestamp != "" && layout != nil {
+			if _, err := layout.Parse(f.timestamp); err == nil {
+				timed++
+			}
+		}
+	}
+	n := float64(len(samples))
+	score.MatchRate = matched / n
+	score.FieldRate = fieldSum / n
+	score.TimeRate = timed / n
+	score.Score = fieldScoreWeight*score.FieldRate + (1-fieldScoreWeight)*score.TimeRate
+	return score
+}
+
+// configuredFields 逐字段正则和命名分组规则中已配置的字段，预设格式返回空
+func configuredFields(rule *compiledRule) []string {
+	var names []string
+	switch m := rule.matcher.(type) {
+	case *regexMatcher:
+		for _, name := range config.RuleFieldNames {
+			if _, ok := m.fields[name]; ok {
+				names = append(names, name)
+			}
+		}
+	case *namedMatcher:
+		for _, name := range m.pattern.SubexpNames() {
+			if slices.Contains(config.RuleFieldNames, name) && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// SampleLogLines 按指定编码（为空时自动识别）读取文件开头的非空行作为样本
+func SampleLogLines(filePath, charset string, limit int) ([]string, error) {
+	reader, _, err := openLogReader(filePath, charset)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	lr := newLineReader(reader, config.DefaultMaxLineSize)
+	var lines []string
+	for len(lines) < limit {
+		line, _, err := lr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// DetectFileProjectRule 按文件开头的样本行识别项目规则，charset为空时自动识别文件编码，
+// 同时返回所有项目规则的评分（按评分降序），没有任何规则匹配时项目为nil
+func DetectFileProjectRule(filePath, charset string) (*config.LogProjectRule, []model.RuleScore, error) {
+	lines, err := SampleLogLines(filePath, charset, detectRuleSampleLines)
+	if err != nil {
+		return nil, nil, err
+	}
+	scores := ScoreProjectRules(lines)
+	return bestProjectRule(scores), scores, nil
+}
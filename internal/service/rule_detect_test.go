@@ -0,0 +1,115 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectProjectRule(t *testing.T) {
+	saved := config.ProjectRules
+	defer func() { config.ProjectRules = saved }()
+	config.ProjectRules = []config.LogProjectRule{
+		{ProjectName: "json", Rule: config.LogParseRule{Type: config.RuleTypeJSON}},
+		{ProjectName: "logcat", Rule: config.LogParseRule{Type: config.RuleTypeLogcat}},
+		{ProjectName: "access", Rule: config.LogParseRule{Type: config.RuleTypeAccess, Format: "combined"}},
+	}
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{
+			`{"ts":"2024-08-02T15:56:24Z","level":"info","msg":"start"}`,
+			`{"ts":"2024-08-02T15:56:25Z","level":"warn","msg":"slow"}`,
+		}, "json"},
+		{[]string{
+			"08-02 15:56:24.951  1234  1250 I ActivityManager: Start proc",
+			"08-02 15:56:25.003  1234  1250 W ActivityManager: Slow operation",
+		}, "logcat"},
+		{[]string{
+			`10.0.0.8 - - [10/Oct/2000:13:55:36 -0700] "POST /api/login HTTP/1.1" 502 0 "-" "curl/8.0"`,
+		}, "access"},
+	}
+	for _, tt := range tests {
+		project, score := DetectProjectRule(tt.lines)
+		if project == nil || project.ProjectName != tt.want {
+			t.Errorf("%q 识别为 %+v, want %s", tt.lines[0], score, tt.want)
+		}
+	}
+	if project, _ := DetectProjectRule([]string{"", "   "}); project != nil {
+		t.Errorf("空样本识别为 %s", project.ProjectName)
+	}
+}
+
+func TestDetectFileProjectRule(t *testing.T) {
+	saved := config.ProjectRules
+	defer func() { config.ProjectRules = saved }()
+	config.ProjectRules = []config.LogProjectRule{
+		{ProjectName: "logcat", Rule: config.LogParseRule{Type: config.RuleTypeLogcat}},
+		{ProjectName: "json", Rule: config.LogParseRule{Type: config.RuleTypeJSON}},
+	}
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "app.log")
+	os.WriteFile(path, []byte(`{"ts":"2024-08-02T15:56:24Z","level":"info","msg":"start"}`+"\n"), 0644)
+	project, scores, err := DetectFileProjectRule(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project == nil || project.ProjectName != "json" || len(scores) != 2 || scores[0].ProjectName != "json" || scores[0].Score <= scores[1].Score {
+		t.Errorf("project = %v, scores = %+v", project, scores)
+	}
+
+	// 无法识别时仍返回所有项目的评分
+	path = filepath.Join(dir, "unknown.log")
+	os.WriteFile(path, []byte("hello\nworld\n"), 0644)
+	project, scores, err = DetectFileProjectRule(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project != nil || len(scores) != 2 {
+		t.Errorf("project = %v, scores = %+v", project, scores)
+	}
+}
+
+func TestScoreProjectRulesJSON(t *testing.T) {
+	saved := config.ProjectRules
+	defer func() { config.ProjectRules = saved }()
+	config.ProjectRules = []config.LogProjectRule{
+		{ProjectName: "json", Rule: config.LogParseRule{Type: config.RuleTypeJSON}},
+	}
+	scores := ScoreProjectRules([]string{
+		`{"ts":"2024-08-02T15:56:24Z","level":"info","msg":"start"}`,
+		`{"level":"warn","msg":"slow"}`,
+		"starting server",
+		`{"broken":`,
+	})
+	if len(scores) != 1 {
+		t.Fatalf("scores = %+v", scores)
+	}
+	// 解析为对象的行计为匹配，无论是否含有时间字段
+	if s := scores[0]; s.MatchRate != 0.5 || s.FieldRate != 0.5 || s.TimeRate != 0.25 {
+		t.Errorf("score = %+v", s)
+	}
+}
+
+func TestDetectLogfmtProjectRule(t *testing.T) {
+	saved := config.ProjectRules
+	defer func() { config.ProjectRules = saved }()
+	config.ProjectRules = []config.LogProjectRule{
+		{ProjectName: "json", Rule: config.LogParseRule{Type: config.RuleTypeJSON}},
+		{ProjectName: "logfmt", Rule: config.LogParseRule{Type: config.RuleTypeLogfmt}},
+	}
+	lines := []string{
+		`time=2024-08-02T15:56:24Z level=info msg="start" port=8080`,
+		`level=warn msg=slow req.id=42`,
+	}
+	project, score := DetectProjectRule(lines)
+	if project == nil || project.ProjectName != "logfmt" {
+		t.Fatalf("识别为 %+v, want logfmt", score)
+	}
+	if score.MatchRate != 1 || score.FieldRate != 1 || score.TimeRate != 0.5 {
+		t.Errorf("score = %+v", score)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"log-tools-go/pkg/xtime"
+	"strings"
+	"time"
+)
+
+// dryRunMaxLines 规则试解析最多处理的样本行数
+const dryRunMaxLines = 1000
+
+// RuleError 规则中有误的配置项
+type RuleError struct {
+	Field string `json:"field"` // 配置项，如 timestamp、pattern、record_start，无法定位时为 rule
+	Error string `json:"error"`
+}
+
+// RuleDryRunLine 单行的试解析结果
+type RuleDryRunLine struct {
+	Line        int                    `json:"line"`
+	Text        string                 `json:"text"`
+	Matched     bool                   `json:"matched"`               // 匹配到任一字段或符合预设格式
+	RecordStart bool                   `json:"record_start"`          // 是否作为新记录的起始行，否则追加到上一条记录
+	Fields      map[string]string      `json:"fields"`                // 提取到的字段，未匹配的字段不返回
+	Attrs       map[string]interface{} `json:"attrs,omitempty"`       // 扩展属性
+	Level       string                 `json:"level,omitempty"`       // 标准化后的级别
+	Time        *time.Time             `json:"time,omitempty"`        // 解析出的时间（已补全年份和时区）
+	TimeSource  string                 `json:"time_source,omitempty"` // 时间来源
+	TimeError   string                 `json:"time_error,omitempty"`  // 时间字段无法解析的原因
+}
+
+// RuleDryRunResult 规则试解析结果
+type RuleDryRunResult struct {
+	Valid           bool                    `json:"valid"`                      // 规则是否可以编译
+	Errors          []RuleError             `json:"errors"`                     // 无法编译的配置项
+	TimestampFormat string                  `json:"timestamp_format,omitempty"` // 使用的时间格式，未配置时为自动识别的格式
+	Coverage        map[string]float64      `json:"coverage"`                   // 各字段的匹配行占比[0-1]
+	Lines           []RuleDryRunLine        `json:"lines"`
+	Diagnostics     *model.ParseDiagnostics `json:"diagnostics,omitempty"` // 与上传文件相同口径的解析诊断
+}
+
+// DryRunRule 在样本文本上试解析规则，不保存任何数据
+func DryRunRule(cfg *config.Config, rule *config.LogParseRule, text string) *RuleDryRunResult {
+	result := &RuleDryRunResult{
+		Errors:   ruleErrors(rule),
+		Coverage: make(map[string]float64),
+		Lines:    []RuleDryRunLine{},
+	}
+	if len(result.Errors) > 0 {
+		return result
+	}
+	parser, err := NewLogParserWithRule(cfg, rule)
+	if err != nil {
+		result.Errors = append(result.Errors, RuleError{Field: "rule", Error: err.Error()})
+		return result
+	}
+	result.Valid = true
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) > dryRunMaxLines {
+		lines = lines[:dryRunMaxLines]
+	}
+	if parser.rule.timeLayout == nil {
+		if layout := parser.detectLinesTimeLayout(lines); layout != nil {
+			parser = parser.withTimeLayout(layout)
+		}
+	}
+	layout := parser.rule.timeLayout
+	if layout != nil {
+		result.TimestampFormat = layout.Pattern()
+	}
+
+	state := &parseState{logFile: &model.LogFile{UploadAt: time.Now()}, diag: newDiagnostics(parser.rule)}
+	parser.initTimeReference(state, "")
+	var current *parsedLine
+	for i, raw := range lines {
+		pl := parser.parseLine(raw, i+1)
+		if pl == nil {
+			continue
+		}
+		recordLine(state.diag, pl)
+		line := RuleDryRunLine{
+			Line:    pl.number,
+			Text:    pl.line,
+			Matched: pl.fields.matched,
+			Fields:  make(map[string]string),
+			Attrs:   pl.fields.attrs,
+		}
+		for _, name := range config.RuleFieldNames {
+			if value := pl.fields.get(name); value != "" {
+				line.Fields[name] = value
+				line.Matched = true
+			}
+		}
+		if pl.fields.level != "" {
+			line.Level = config.NormalizeLevel(pl.fields.level, parser.rule.levels)
+		}
+		if pl.fields.timestamp != "" && !pl.timeParsed {
+			line.TimeError = timeError(layout, pl.fields.timestamp)
+		}
+		line.RecordStart = current == nil || parser.isRecordStart(pl)
+		if line.RecordStart {
+			current = pl
+			t, source := state.resolveTime(pl, parser.rule.location)
+			state.lastTime = &t
+			line.Time = &t
+			line.TimeSource = source
+		}
+		result.Lines = append(result.Lines, line)
+	}
+
+	if total := state.diag.TotalLines; total > 0 {
+		for name, count := range state.diag.FieldMatches {
+			result.Coverage[name] = float64(count) / float64(total)
+		}
+	}
+	result.Diagnostics = state.diag
+	return result
+}
+
+// ruleErrors 按解析时的编译步骤检查规则，返回所有有误的配置项
+func ruleErrors(rule *config.LogParseRule) []RuleError {
+	errs := []RuleError{}
+	_, err := compileRule(rule)
+	if err == nil {
+		return errs
+	}
+	for _, e := range flattenErrors(err) {
+		field := "rule"
+		var fe *ruleFieldError
+		if errors.As(e, &fe) {
+			field = fe.field
+		}
+		errs = append(errs, RuleError{Field: field, Error: e.Error()})
+	}
+	return errs
+}
+
+// flattenErrors 按顺序展开 errors.Join 合并的错误
+func flattenErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var list []error
+	for _, e := range joined.Unwrap() {
+		list = append(list, flattenErrors(e)...)
+	}
+	return list
+}
+
+// timeError 时间字段无法解析的原因
+func timeError(layout *xtime.Layout, value string) string {
+	if layout == nil {
+		return "未配置timestamp_format且无法自动识别时间格式"
+	}
+	if _, err := layout.Parse(value); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("无法按%s解析时间: %s", layout.Pattern(), value)
+}
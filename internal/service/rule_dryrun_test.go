@@ -0,0 +1,82 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRunRule(t *testing.T) {
+	text := "2024-08-02 15:56:24,951 [main] INFO start\n" +
+		"2024-08-02 15:56:25,003 [main] W slow\n" +
+		"\tat com.example.App.run(App.java:10)\n" +
+		"2024-13-02 15:56:26,000 [main] ERROR bad time\n"
+	result := DryRunRule(nil, &config.LogParseRule{
+		Timestamp:       `^(\d\S+ \S+)`,
+		Thread:          `\[(\w+)\]`,
+		Level:           `\] (\w+)`,
+		TimestampFormat: "yyyy-MM-dd HH:mm:ss,SSS",
+		RecordStart:     `^\d{4}-`,
+	}, text)
+	if !result.Valid || len(result.Errors) != 0 {
+		t.Fatalf("errors = %+v", result.Errors)
+	}
+	if len(result.Lines) != 4 {
+		t.Fatalf("lines = %d", len(result.Lines))
+	}
+	first := result.Lines[0]
+	if first.Fields["thread"] != "main" || first.Level != "INFO" || first.Time == nil ||
+		!first.Time.Equal(time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)) {
+		t.Errorf("line 1 = %+v", first)
+	}
+	if result.Lines[1].Level != "WARN" {
+		t.Errorf("line 2 level = %s", result.Lines[1].Level)
+	}
+	if stack := result.Lines[2]; stack.RecordStart || stack.Matched {
+		t.Errorf("line 3 = %+v", stack)
+	}
+	if bad := result.Lines[3]; bad.TimeError == "" || bad.TimeSource != "inherited" {
+		t.Errorf("line 4 = %+v", bad)
+	}
+	if result.Coverage["timestamp"] != 0.75 || result.Coverage["message"] != 0 {
+		t.Errorf("coverage = %+v", result.Coverage)
+	}
+	if result.Diagnostics.TimestampFailed != 1 {
+		t.Errorf("timestamp failed = %d", result.Diagnostics.TimestampFailed)
+	}
+
+	result = DryRunRule(nil, &config.LogParseRule{Level: `(\w+`, Message: `[`, TimestampFormat: "yyyy-'MM"}, text)
+	if result.Valid || len(result.Errors) != 3 {
+		t.Errorf("errors = %+v", result.Errors)
+	}
+	for i, field := range []string{"level", "message", "timestamp_format"} {
+		if i < len(result.Errors) && result.Errors[i].Field != field {
+			t.Errorf("error %d = %+v, want %s", i, result.Errors[i], field)
+		}
+	}
+
+	// 与保存规则时相同的编译步骤，错误按配置项逐项返回
+	rule := &config.LogParseRule{
+		Type:         config.RuleTypeNamed,
+		Pattern:      `(?P<level>\w+`,
+		RecordStart:  `(`,
+		TimeZone:     "Mars/Olympus",
+		CustomFields: []config.CustomField{{Name: "user", Pattern: `user=(\d+`}},
+	}
+	result = DryRunRule(nil, rule, text)
+	fields := make([]string, len(result.Errors))
+	for i, e := range result.Errors {
+		fields[i] = e.Field
+	}
+	if want := "pattern,record_start,custom_fields.user,time_zone"; strings.Join(fields, ",") != want {
+		t.Errorf("fields = %v, want %s", fields, want)
+	}
+	if err := rule.Validate(); err == nil || !strings.Contains(err.Error(), result.Errors[0].Error) {
+		t.Errorf("Validate() = %v", err)
+	}
+	result = DryRunRule(nil, &config.LogParseRule{Type: config.RuleTypeAccess, Format: "nginx"}, text)
+	if len(result.Errors) != 1 || result.Errors[0].Field != "format" {
+		t.Errorf("errors = %+v", result.Errors)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamedRule(t *testing.T) {
+	content := `2024-08-02 15:56:24.951 [main] WARN  c.d.Service:42 - slow query id=7
+2024-08-02 15:56:25.003 [pool-1] ERROR c.d.Dao:108 - connection lost
+not matched
+`
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLogParserWithRule(nil, &config.LogParseRule{
+		Type:            config.RuleTypeNamed,
+		Pattern:         `^(?P<timestamp>\S+ \S+) \[(?P<thread>[^\]]+)\] (?P<level>\w+)\s+(?P<class>[\w.]+):(?P<class_line>\d+) - (?P<message>.*?)(?: id=(?P<unused>\d+))?$`,
+		TimestampFormat: "yyyy-MM-dd HH:mm:ss.SSS",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := parser.ParseLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logFile.Entries) != 3 {
+		t.Fatalf("entries = %d", len(logFile.Entries))
+	}
+
+	// 分组名对应字段，未知的分组名忽略
+	e := logFile.Entries[0]
+	if *e.Thread != "main" || e.Level != "WARN" || *e.Class != "c.d.Service" || *e.ClassLine != "42" || e.Message != "slow query" {
+		t.Errorf("entry 0 = %s %s %s %s %q", *e.Thread, e.Level, *e.Class, *e.ClassLine, e.Message)
+	}
+	if !e.LogTime.Equal(time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)) || e.Attrs != nil {
+		t.Errorf("entry 0 = %v %v", e.LogTime, e.Attrs)
+	}
+	if e := logFile.Entries[1]; *e.Thread != "pool-1" || e.Level != "ERROR" || e.Message != "connection lost" {
+		t.Errorf("entry 1 = %s %s %q", *e.Thread, e.Level, e.Message)
+	}
+
+	// 不匹配的行整行作为消息
+	if e := logFile.Entries[2]; e.Message != "not matched" || e.Level != "" {
+		t.Errorf("entry 2 = %s %q", e.Level, e.Message)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := map[string]config.LogParseRule{
+		"字段正则":           {Level: `(\w+`},
+		"named缺少pattern": {Type: config.RuleTypeNamed},
+		"named正则":        {Type: config.RuleTypeNamed, Pattern: `(?P<level>\w+`},
+		"未知类型":           {Type: "xml"},
+		"logcat格式":       {Type: config.RuleTypeLogcat, Format: "unknown"},
+		"record_start":   {RecordStart: `[`},
+		"时间格式":           {TimestampFormat: "yyyy-MM-dd 'T"},
+		"时区":             {TimeZone: "Mars/Base"},
+		"编码":             {Encoding: "ebcdic-x"},
+		"级别映射":           {Levels: map[string]string{"note": "notice"}},
+		"自定义字段正则":        {CustomFields: []config.CustomField{{Name: "code", Pattern: `code=(\d+`}}},
+		"帧解码器正则":         {Decoders: []config.FrameDecoder{{Name: "mcu", Pattern: `(`}}},
+	}
+	for name, rule := range tests {
+		if err := rule.Validate(); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+	valid := config.LogParseRule{Type: config.RuleTypeNamed, Pattern: `^(?P<level>\w+) (?P<message>.*)$`, TimeZone: "Asia/Shanghai"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid: %v", err)
+	}
+}
+
+func TestLoadProjectRulesInvalid(t *testing.T) {
+	saved := config.ProjectRules
+	t.Cleanup(func() { config.ProjectRules = saved })
+
+	dir := t.TempDir()
+	validPath := filepath.Join(dir, "valid.json")
+	os.WriteFile(validPath, []byte(`[{"project_name":"ok","rule":{"type":"named","pattern":"^(?P<level>\\w+) (?P<message>.*)$"}}]`), 0644)
+	if err := config.LoadProjectRules(validPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.ProjectRules) != 1 || config.ProjectRules[0].ProjectName != "ok" {
+		t.Fatalf("rules = %+v", config.ProjectRules)
+	}
+
+	invalidPath := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalidPath, []byte(`[{"project_name":"bad","rule":{"type":"named","pattern":"^(?P<level>\\w+"}}]`), 0644)
+	err := config.LoadProjectRules(invalidPath)
+	if err == nil || !strings.Contains(err.Error(), "项目[bad]规则错误") {
+		t.Errorf("err = %v", err)
+	}
+	// 加载失败时保留已加载的规则
+	if len(config.ProjectRules) != 1 || config.ProjectRules[0].ProjectName != "ok" {
+		t.Errorf("rules = %+v", config.ProjectRules)
+	}
+}
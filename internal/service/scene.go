@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"sort"
+	"strings"
+)
+
+// findScene 在项目的所有模块中按名称查找场景，返回场景及所属模块名称
+func findScene(project *config.LogProjectRule, name string) (*config.LogProjectScene, string) {
+	for _, m := range project.Modules {
+		for i := range m.Scenes {
+			if m.Scenes[i].Name == name {
+				return &m.Scenes[i], m.Name
+			}
+		}
+	}
+	return nil, ""
+}
+
+// resolveScene 确定场景所属的项目（未指定时取文件解析时使用的项目）并查找场景，文件不存在时返回 sql.ErrNoRows
+func (s *StorageService) resolveScene(fileIDs []string, projectName, sceneName string) (*config.LogProjectRule, *config.LogProjectScene, string, error) {
+	for _, id := range fileIDs {
+		file, err := s.database.GetLogFile(id)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if projectName == "" {
+			projectName = file.Project
+		}
+	}
+	if projectName == "" {
+		return nil, nil, "", fmt.Errorf("文件未记录解析使用的项目，请指定项目")
+	}
+	project := config.GetProjectRule(projectName)
+	if project == nil {
+		return nil, nil, "", fmt.Errorf("项目不存在: %s", projectName)
+	}
+	scene, module := findScene(project, sceneName)
+	if scene == nil {
+		return nil, nil, "", fmt.Errorf("项目[%s]中不存在场景: %s", projectName, sceneName)
+	}
+	return project, scene, module, nil
+}
+
+// SceneTimeline 按项目场景中的关键词生成文件（逗号分隔支持多个）的时间线，project 为空时取文件解析时使用的项目
+// 文件不存在时返回 sql.ErrNoRows
+func (s *StorageService) SceneTimeline(fileID, projectName, sceneName string) (*model.SceneTimeline, error) {
+	fileIDs := strings.Split(fileID, ",")
+	for i := range fileIDs {
+		fileIDs[i] = strings.TrimSpace(fileIDs[i])
+	}
+	project, scene, module, err := s.resolveScene(fileIDs, projectName, sceneName)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := s.database.GetSceneKeywordHits(fileID, scene.Name)
+	if err != nil {
+		return nil, err
+	}
+	timeline := buildSceneTimeline(scene, hits)
+	timeline.Project = project.ProjectName
+	timeline.Module = module
+	timeline.FileIDs = fileIDs
+	return timeline, nil
+}
+
+// buildSceneTimeline 将场景关键词的命中统计整理为时间线：已出现的步骤按首次出现时间排序（相同时按定义顺序），
+// 未出现的步骤标记为missing并按定义顺序排在最后
+func buildSceneTimeline(scene *config.LogProjectScene, hits map[string]model.KeywordHits) *model.SceneTimeline {
+	timeline := &model.SceneTimeline{Scene: scene.Name, Steps: make([]model.SceneStep, 0, len(scene.Keywords))}
+	for i, k := range scene.Keywords {
+		step := model.SceneStep{Index: i, Keyword: k.Keyword, Desc: k.Desc, Color: k.Color}
+		if h, ok := hits[k.Keyword]; ok && h.Count > 0 {
+			first, last := h.FirstAt, h.LastAt
+			step.Count = h.Count
+			step.FirstAt = &first
+			step.LastAt = &last
+			step.FileID = h.FileID
+			step.Line = h.Line
+			step.Message = h.Message
+			timeline.Found++
+		} else {
+			step.Missing = true
+			timeline.Missing++
+		}
+		timeline.Steps = append(timeline.Steps, step)
+	}
+	sort.SliceStable(timeline.Steps, func(i, j int) bool {
+		a, b := timeline.Steps[i], timeline.Steps[j]
+		if a.Missing != b.Missing {
+			return !a.Missing
+		}
+		if a.Missing {
+			return false
+		}
+		return a.FirstAt.Before(*b.FirstAt)
+	})
+
+	var previous *model.SceneStep
+	for i := range timeline.Steps {
+		step := &timeline.Steps[i]
+		if step.Missing {
+			break
+		}
+		if previous != nil {
+			since := step.FirstAt.Sub(*previous.FirstAt).Milliseconds()
+			step.SincePrevious = &since
+		}
+		previous = step
+	}
+	if timeline.Found > 0 {
+		duration := previous.FirstAt.Sub(*timeline.Steps[0].FirstAt).Milliseconds()
+		timeline.Duration = &duration
+	}
+	return timeline
+}
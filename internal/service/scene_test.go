@@ -0,0 +1,46 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"testing"
+	"time"
+)
+
+func TestBuildSceneTimeline(t *testing.T) {
+	scene := &config.LogProjectScene{
+		Name: "开机",
+		Keywords: []config.LogProjectKeyword{
+			{Keyword: "boot", Desc: "内核启动"},
+			{Keyword: "SystemServer", Desc: "系统服务启动"},
+			{Keyword: "MCU handshake", Desc: "MCU握手"},
+			{Keyword: "notify", Desc: "通知上层"},
+		},
+	}
+	base := time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)
+	hits := map[string]model.KeywordHits{
+		"boot":          {Keyword: "boot", Count: 1, FirstAt: base, LastAt: base, FileID: "f1", Line: 1},
+		"MCU handshake": {Keyword: "MCU handshake", Count: 3, FirstAt: base.Add(1500 * time.Millisecond), LastAt: base.Add(5 * time.Second), FileID: "f2", Line: 7},
+		"SystemServer":  {Keyword: "SystemServer", Count: 2, FirstAt: base.Add(2 * time.Second), LastAt: base.Add(3 * time.Second), FileID: "f1", Line: 20},
+	}
+	timeline := buildSceneTimeline(scene, hits)
+	if timeline.Found != 3 || timeline.Missing != 1 || timeline.Duration == nil || *timeline.Duration != 2000 {
+		t.Fatalf("timeline = %+v", timeline)
+	}
+	var order []string
+	for _, step := range timeline.Steps {
+		order = append(order, step.Keyword)
+	}
+	want := []string{"boot", "MCU handshake", "SystemServer", "notify"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	if timeline.Steps[0].SincePrevious != nil || *timeline.Steps[1].SincePrevious != 1500 || *timeline.Steps[2].SincePrevious != 500 {
+		t.Errorf("since previous = %v %v %v", timeline.Steps[0].SincePrevious, timeline.Steps[1].SincePrevious, timeline.Steps[2].SincePrevious)
+	}
+	if missing := timeline.Steps[3]; !missing.Missing || missing.Index != 3 || missing.FirstAt != nil || missing.Desc != "通知上层" {
+		t.Errorf("missing step = %+v", missing)
+	}
+}
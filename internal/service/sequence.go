@@ -0,0 +1,261 @@
+package service
+
+import (
+	"fmt"
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"regexp"
+	"time"
+)
+
+// sequenceStep 编译后的顺序规则步骤
+type sequenceStep struct {
+	keyword     string
+	maxGap      time.Duration  // 距上一步的最大间隔，为0时不限制
+	correlation *regexp.Regexp // 关联键正则，为nil时所有命中属于同一组
+}
+
+// sequenceRule 场景的顺序规则：关键词按定义顺序作为步骤
+type sequenceRule struct {
+	steps   []sequenceStep
+	index   map[string][]int // 关键词 -> 步骤下标（同一关键词可出现在多个步骤）
+	timeout time.Duration    // 整个流程的最长耗时，为0时不限制
+}
+
+// compileSequence 预编译场景的顺序规则
+func compileSequence(scene *config.LogProjectScene) (*sequenceRule, error) {
+	if len(scene.Keywords) == 0 {
+		return nil, fmt.Errorf("场景[%s]没有关键词", scene.Name)
+	}
+	var sceneCorrelation *regexp.Regexp
+	if scene.Correlation != "" {
+		re, err := regexp.Compile(scene.Correlation)
+		if err != nil {
+			return nil, fmt.Errorf("场景[%s]关联键正则错误: %w", scene.Name, err)
+		}
+		sceneCorrelation = re
+	}
+	rule := &sequenceRule{
+		index:   make(map[string][]int),
+		timeout: time.Duration(scene.Timeout) * time.Millisecond,
+	}
+	for i, k := range scene.Keywords {
+		step := sequenceStep{
+			keyword:     k.Keyword,
+			maxGap:      time.Duration(k.MaxGap) * time.Millisecond,
+			correlation: sceneCorrelation,
+		}
+		if k.Correlation != "" {
+			re, err := regexp.Compile(k.Correlation)
+			if err != nil {
+				return nil, fmt.Errorf("场景[%s]关键词[%s]关联键正则错误: %w", scene.Name, k.Keyword, err)
+			}
+			step.correlation = re
+		}
+		rule.steps = append(rule.steps, step)
+		rule.index[k.Keyword] = append(rule.index[k.Keyword], i)
+	}
+	return rule, nil
+}
+
+// correlationKey 提取关联键（取第一个分组，无分组时取整个匹配），未配置关联键时返回空字符串，
+// 配置了但不匹配时ok为false
+func (s *sequenceStep) correlationKey(content string) (key string, ok bool) {
+	if s.correlation == nil {
+		return "", true
+	}
+	m := s.correlation.FindStringSubmatch(content)
+	if m == nil {
+		return "", false
+	}
+	if len(m) > 1 {
+		return m[1], true
+	}
+	return m[0], true
+}
+
+// openSequence 进行中的流程
+type openSequence struct {
+	run  *model.SequenceRun
+	next int       // 期望的下一步下标
+	last time.Time // 上一步的时间
+}
+
+// evaluate 按时间顺序检查场景关键词的命中，返回所有流程（按开始时间排序）
+// 同一关联键的流程从第一步开始，按顺序推进；缺步、乱序、间隔或总耗时超限时结束并记录原因
+func (r *sequenceRule) evaluate(hits []model.SceneHit) []model.SequenceRun {
+	var runs []*model.SequenceRun
+	open := make(map[string]*openSequence)
+	finish := func(key string, status, reason string) {
+		o := open[key]
+		o.run.Status, o.run.Reason = status, reason
+		first, last := o.run.Steps[0].Time, o.run.Steps[len(o.run.Steps)-1].Time
+		o.run.Duration = last.Sub(first).Milliseconds()
+		delete(open, key)
+	}
+	// expired 判断流程到 now 时是否已超过总耗时限制
+	expired := func(o *openSequence, now time.Time) (string, bool) {
+		total := now.Sub(o.run.Steps[0].Time)
+		if r.timeout <= 0 || total <= r.timeout {
+			return "", false
+		}
+		return fmt.Sprintf("流程耗时%dms，超过%dms", total.Milliseconds(), r.timeout.Milliseconds()), true
+	}
+	start := func(key string, index int, hit model.SceneHit) {
+		run := &model.SequenceRun{Key: key}
+		run.Steps = append(run.Steps, model.SequenceStep{Index: index, Keyword: hit.Keyword, Time: hit.Time, Line: hit.Line})
+		runs = append(runs, run)
+		open[key] = &openSequence{run: run, next: index + 1, last: hit.Time}
+	}
+
+	for _, hit := range hits {
+		candidates := r.index[hit.Keyword]
+		if len(candidates) == 0 {
+			continue
+		}
+		// 同一关键词出现在多个步骤时，优先取对应流程期望的下一步
+		index := candidates[0]
+		key, ok := r.steps[index].correlationKey(hit.Content)
+		for _, i := range candidates {
+			if k, kok := r.steps[i].correlationKey(hit.Content); kok && open[k] != nil && open[k].next == i {
+				index, key, ok = i, k, true
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		step := r.steps[index]
+		o := open[key]
+
+		if index == 0 {
+			if o != nil {
+				if reason, ok := expired(o, hit.Time); ok {
+					finish(key, model.SequenceTimeout, reason+"仍未完成")
+				} else {
+					finish(key, model.SequenceMissing, "缺少步骤: "+r.steps[o.next].keyword)
+				}
+			}
+			start(key, index, hit)
+			if len(r.steps) == 1 {
+				finish(key, model.SequenceCompleted, "")
+			}
+			continue
+		}
+		if o == nil {
+			start(key, index, hit)
+			finish(key, model.SequenceOutOfOrder, fmt.Sprintf("步骤[%s]之前没有起始步骤[%s]", step.keyword, r.steps[0].keyword))
+			continue
+		}
+
+		latency := hit.Time.Sub(o.last).Milliseconds()
+		o.run.Steps = append(o.run.Steps, model.SequenceStep{Index: index, Keyword: hit.Keyword, Time: hit.Time, Line: hit.Line, Latency: &latency})
+		// 每一步都检查总耗时，超时后的缺步、乱序均按超时处理
+		if reason, ok := expired(o, hit.Time); ok {
+			finish(key, model.SequenceTimeout, reason)
+			continue
+		}
+		switch {
+		case index < o.next:
+			finish(key, model.SequenceOutOfOrder, fmt.Sprintf("步骤[%s]重复或乱序出现", step.keyword))
+		case index > o.next:
+			finish(key, model.SequenceMissing, "缺少步骤: "+r.steps[o.next].keyword)
+		case step.maxGap > 0 && hit.Time.Sub(o.last) > step.maxGap:
+			finish(key, model.SequenceTimeout, fmt.Sprintf("步骤[%s]距上一步%dms，超过%dms", step.keyword, latency, step.maxGap.Milliseconds()))
+		case index == len(r.steps)-1:
+			finish(key, model.SequenceCompleted, "")
+		default:
+			o.next++
+			o.last = hit.Time
+		}
+	}
+	// 文件结束时仍未完成的流程，到最后一次命中时已超过总耗时限制的按超时处理
+	for _, run := range runs {
+		if run.Status != "" {
+			continue
+		}
+		o := open[run.Key]
+		if reason, ok := expired(o, hits[len(hits)-1].Time); ok {
+			finish(run.Key, model.SequenceTimeout, reason+"仍未完成")
+		} else {
+			finish(run.Key, model.SequenceMissing, "缺少步骤: "+r.steps[o.next].keyword)
+		}
+	}
+
+	result := make([]model.SequenceRun, len(runs))
+	for i, run := range runs {
+		result[i] = *run
+	}
+	return result
+}
+
+// summarizeSequences 统计流程结果及完成流程的耗时
+func summarizeSequences(report *model.SequenceReport, runs []model.SequenceRun) {
+	report.Total = len(runs)
+	report.Counts = make(map[string]int)
+	var total *model.LatencyStats
+	steps := make(map[string]*model.LatencyStats)
+	stepCounts := make(map[string]int)
+	add := func(stats *model.LatencyStats, value int64, n int) *model.LatencyStats {
+		if stats == nil {
+			return &model.LatencyStats{Min: value, Max: value, Avg: float64(value)}
+		}
+		stats.Min = min(stats.Min, value)
+		stats.Max = max(stats.Max, value)
+		stats.Avg += (float64(value) - stats.Avg) / float64(n)
+		return stats
+	}
+	for _, run := range runs {
+		report.Counts[run.Status]++
+		if run.Status != model.SequenceCompleted {
+			continue
+		}
+		report.Completed++
+		total = add(total, run.Duration, report.Completed)
+		for _, step := range run.Steps {
+			if step.Latency == nil {
+				continue
+			}
+			stepCounts[step.Keyword]++
+			steps[step.Keyword] = add(steps[step.Keyword], *step.Latency, stepCounts[step.Keyword])
+		}
+	}
+	report.Latency = total
+	if len(steps) > 0 {
+		report.Steps = make(map[string]model.LatencyStats, len(steps))
+		for k, v := range steps {
+			report.Steps[k] = *v
+		}
+	}
+}
+
+// SceneSequences 按场景的顺序规则检查单个文件，project 为空时取文件解析时使用的项目；
+// status 不为空时只返回该结果的流程明细，limit 大于0时限制明细条数，统计不受影响。文件不存在时返回 sql.ErrNoRows
+func (s *StorageService) SceneSequences(fileID, projectName, sceneName, status string, limit int) (*model.SequenceReport, error) {
+	project, scene, module, err := s.resolveScene([]string{fileID}, projectName, sceneName)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := compileSequence(scene)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := s.database.GetSceneHits(fileID, scene.Name)
+	if err != nil {
+		return nil, err
+	}
+	runs := rule.evaluate(hits)
+	report := &model.SequenceReport{FileID: fileID, Project: project.ProjectName, Module: module, Scene: scene.Name}
+	summarizeSequences(report, runs)
+	report.Runs = make([]model.SequenceRun, 0)
+	for _, run := range runs {
+		if status != "" && run.Status != status {
+			continue
+		}
+		if limit > 0 && len(report.Runs) >= limit {
+			break
+		}
+		report.Runs = append(report.Runs, run)
+	}
+	return report, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"log-tools-go/internal/config"
+	"log-tools-go/internal/model"
+	"testing"
+	"time"
+)
+
+func TestSceneSequence(t *testing.T) {
+	scene := &config.LogProjectScene{
+		Name:        "串口写入",
+		Correlation: `frame=(\w+)`,
+		Timeout:     1000,
+		Keywords: []config.LogProjectKeyword{
+			{Keyword: "[write]"},
+			{Keyword: "[retAck]", MaxGap: 100},
+			{Keyword: "[notify]"},
+		},
+	}
+	if err := (&config.LogProjectRule{Modules: []config.LogProjectModule{{Scenes: []config.LogProjectScene{*scene}}}}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	rule, err := compileSequence(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)
+	hit := func(ms int, keyword, frame string) model.SceneHit {
+		content := "DeviceService " + keyword
+		if frame != "" {
+			content += " frame=" + frame
+		}
+		return model.SceneHit{Keyword: keyword, Time: base.Add(time.Duration(ms) * time.Millisecond), Line: ms, Content: content}
+	}
+	hits := []model.SceneHit{
+		hit(0, "[write]", "A5"),
+		hit(10, "[write]", "B1"),
+		hit(20, "[retAck]", "C3"), // 没有写入就应答
+		hit(30, "[write]", "D4"),
+		hit(40, "[write]", ""), // 无关联键，忽略
+		hit(50, "[retAck]", "A5"),
+		hit(60, "[notify]", "B1"), // 缺少应答
+		hit(80, "[notify]", "A5"),
+		hit(90, "[write]", "E6"),
+		hit(95, "[retAck]", "E6"),
+		hit(96, "[retAck]", "E6"),  // 重复应答
+		hit(600, "[retAck]", "D4"), // 应答超时
+		hit(700, "[write]", "F7"),  // 文件结束仍未应答
+	}
+	runs := rule.evaluate(hits)
+	want := []struct {
+		key, status string
+		duration    int64
+	}{
+		{"A5", model.SequenceCompleted, 80},
+		{"B1", model.SequenceMissing, 50},
+		{"C3", model.SequenceOutOfOrder, 0},
+		{"D4", model.SequenceTimeout, 570},
+		{"E6", model.SequenceOutOfOrder, 6},
+		{"F7", model.SequenceMissing, 0},
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs = %+v", runs)
+	}
+	for i, w := range want {
+		if r := runs[i]; r.Key != w.key || r.Status != w.status || r.Duration != w.duration {
+			t.Errorf("run %d = %s %s %d (%s), want %+v", i, r.Key, r.Status, r.Duration, r.Reason, w)
+		}
+	}
+	if reason := runs[1].Reason; reason != "缺少步骤: [retAck]" {
+		t.Errorf("reason = %s", reason)
+	}
+	if latency := runs[0].Steps[1].Latency; latency == nil || *latency != 50 {
+		t.Errorf("latency = %v", latency)
+	}
+
+	report := &model.SequenceReport{}
+	summarizeSequences(report, runs)
+	if report.Total != 6 || report.Completed != 1 || report.Counts[model.SequenceOutOfOrder] != 2 ||
+		report.Latency.Avg != 80 || report.Steps["[notify]"].Max != 30 {
+		t.Errorf("report = %+v", report)
+	}
+
+	// 整个流程超时
+	scene.Keywords[1].MaxGap = 0
+	scene.Timeout = 50
+	rule, _ = compileSequence(scene)
+	runs = rule.evaluate([]model.SceneHit{hit(0, "[write]", "A5"), hit(20, "[retAck]", "A5"), hit(80, "[notify]", "A5")})
+	if len(runs) != 1 || runs[0].Status != model.SequenceTimeout {
+		t.Errorf("runs = %+v", runs)
+	}
+
+	// 中间步骤超过总耗时，以及重新开始或文件结束时已超时的未完成流程
+	runs = rule.evaluate([]model.SceneHit{
+		hit(0, "[write]", "A5"),
+		hit(60, "[retAck]", "A5"),
+		hit(100, "[write]", "B1"),
+		hit(200, "[write]", "B1"),
+		hit(210, "[write]", "C3"),
+		hit(230, "[retAck]", "C3"),
+		hit(300, "[write]", "D4"),
+	})
+	want = []struct {
+		key, status string
+		duration    int64
+	}{
+		{"A5", model.SequenceTimeout, 60},
+		{"B1", model.SequenceTimeout, 0},
+		{"B1", model.SequenceTimeout, 0},
+		{"C3", model.SequenceTimeout, 20},
+		{"D4", model.SequenceMissing, 0},
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs = %+v", runs)
+	}
+	for i, w := range want {
+		if r := runs[i]; r.Key != w.key || r.Status != w.status || r.Duration != w.duration {
+			t.Errorf("run %d = %s %s %d (%s), want %+v", i, r.Key, r.Status, r.Duration, r.Reason, w)
+		}
+	}
+	if reason := runs[0].Reason; reason != "流程耗时60ms，超过50ms" {
+		t.Errorf("reason = %s", reason)
+	}
+}
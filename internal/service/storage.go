@@ -1,18 +1,16 @@
 package service
 
 import (
-	"archive/zip"
-	"context"
 	"fmt"
 	"io"
 	"log-tools-go/internal/config"
 	"log-tools-go/internal/model"
+	"log-tools-go/pkg/xjob"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
-
-	"github.com/mholt/archiver/v4"
 )
 
 type StorageService struct {
@@ -37,7 +35,7 @@ func (s *StorageService) SaveUploadedFile(file *os.File, filename string) (strin
 
 	// 生成唯一文件名
 	timestamp := time.Now().Format("20060102_150405")
-	ext := filepath.Ext(filename)
+	ext := fileExt(filename)
 	baseName := strings.TrimSuffix(filename, ext)
 	newFilename := fmt.Sprintf("%s_%s%s", baseName, timestamp, ext)
 	filePath := filepath.Join(s.config.Storage.UploadDir, newFilename)
@@ -62,162 +60,38 @@ func (s *StorageService) SaveUploadedFile(file *os.File, filename string) (strin
 	return filePath, nil
 }
 
-func (s *StorageService) ExtractZipFile(zipPath string) ([]string, error) {
-	ext := strings.ToLower(filepath.Ext(zipPath))
-
-	switch ext {
-	case ".zip":
-		return s.extractZip(zipPath)
-	case ".rar", ".7z":
-		return s.extractWithArchiver(zipPath)
-	default:
-		return nil, fmt.Errorf("不支持的压缩格式: %s", ext)
-	}
-}
-
-// extractZip 处理ZIP文件解压
-func (s *StorageService) extractZip(zipPath string) ([]string, error) {
-	var extractedFiles []string
-
-	reader, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return nil, fmt.Errorf("打开zip文件失败: %w", err)
-	}
-	defer reader.Close()
-
-	extractDir := filepath.Join(s.config.Storage.UploadDir, "extracted_"+time.Now().Format("20060102_150405"))
-	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建解压目录失败: %w", err)
-	}
-
-	for _, file := range reader.File {
-		if file.FileInfo().IsDir() {
-			continue
-		}
-
-		// 检查文件扩展名
-		ext := strings.ToLower(filepath.Ext(file.Name))
-		if ext != ".txt" && ext != ".log" && ext != ".gz" {
-			continue
-		}
-
-		filePath := filepath.Join(extractDir, file.Name)
-
-		// 创建目录结构
-		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-			continue
-		}
-
-		// 创建文件
-		dst, err := os.Create(filePath)
-		if err != nil {
-			continue
-		}
-
-		// 打开zip中的文件
-		src, err := file.Open()
-		if err != nil {
-			dst.Close()
-			continue
-		}
-
-		// 复制内容
-		_, err = io.Copy(dst, src)
-		dst.Close()
-		src.Close()
-
-		if err == nil {
-			extractedFiles = append(extractedFiles, filePath)
-		}
-	}
-
-	return extractedFiles, nil
-}
-
-// extractWithArchiver 使用第三方库处理RAR和7Z文件解压
-func (s *StorageService) extractWithArchiver(archivePath string) ([]string, error) {
-	var extractedFiles []string
-
-	// 创建解压目录
-	extractDir := filepath.Join(s.config.Storage.UploadDir, "extracted_"+time.Now().Format("20060102_150405"))
-	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建解压目录失败: %w", err)
-	}
-
-	// 打开文件
-	file, err := os.Open(archivePath)
+// IngestLogFile 流式解析并入库单个日志文件
+// 先写入文件记录，解析出的条目按批次经任务队列串行写入数据库，已写入的批次即可查询
+func (s *StorageService) IngestLogFile(parser *LogParser, filePath string) (*model.LogFile, error) {
+	logFile, err := parser.NewLogFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("打开压缩文件失败: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	// 使用archiver库解压
-	format, _, err := archiver.Identify(context.Background(), archivePath, file)
+	err = xjob.GetInstance().Submit(func() error {
+		return s.database.SaveLogFileInfo(logFile)
+	}, true)
 	if err != nil {
-		return nil, fmt.Errorf("识别压缩文件格式失败: %w", err)
-	}
-
-	extractor, ok := format.(archiver.Extractor)
-	if !ok {
-		return nil, fmt.Errorf("不支持的压缩格式")
+		return nil, err
 	}
-
-	// 重置文件指针
-	_, err = file.Seek(0, 0)
+	err = parser.ParseLogFileStream(logFile, filePath, func(entries []model.LogEntry) error {
+		return xjob.GetInstance().Submit(func() error {
+			return s.database.InsertLogEntries(logFile.ID, entries)
+		}, true)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("重置文件指针失败: %w", err)
-	}
-
-	// 解压文件
-	err = extractor.Extract(context.Background(), file, func(ctx context.Context, file archiver.FileInfo) error {
-		// 只处理文件，跳过目录
-		if file.FileInfo.IsDir() {
-			return nil
-		}
-
-		// 检查文件扩展名
-		ext := strings.ToLower(filepath.Ext(file.Name()))
-		if ext != ".txt" && ext != ".log" && ext != ".gz" {
-			return nil
-		}
-
-		// 构建目标文件路径
-		filePath := filepath.Join(extractDir, file.Name())
-
-		// 创建目录结构
-		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-			return fmt.Errorf("创建目录失败: %w", err)
+		// 清理已写入的部分数据
+		if delErr := s.database.DeleteLogFile(logFile.ID); delErr != nil {
+			fmt.Printf("清理日志文件 %s 失败: %v\n", logFile.ID, delErr)
 		}
-
-		// 创建目标文件
-		dst, err := os.Create(filePath)
-		if err != nil {
-			return fmt.Errorf("创建文件失败: %w", err)
-		}
-		defer dst.Close()
-
-		// 打开源文件
-		src, err := file.Open()
-		if err != nil {
-			return fmt.Errorf("打开压缩文件中的文件失败: %w", err)
-		}
-		defer src.Close()
-
-		// 复制内容
-		_, err = io.Copy(dst, src)
-		if err != nil {
-			return fmt.Errorf("复制文件内容失败: %w", err)
-		}
-
-		extractedFiles = append(extractedFiles, filePath)
-		return nil
-	})
-
+		return nil, err
+	}
+	err = xjob.GetInstance().Submit(func() error {
+		return s.database.UpdateLogFileDiagnostics(logFile.ID, logFile.Diagnostics)
+	}, true)
 	if err != nil {
-		return nil, fmt.Errorf("解压文件失败: %w", err)
+		return nil, err
 	}
-
-	return extractedFiles, nil
+	return logFile, nil
 }
 
 func (s *StorageService) SaveParsedLogs(logFile *model.LogFile) error {
@@ -245,6 +119,9 @@ func (s *StorageService) LoadParsedLogs(fileID string) (*model.LogFile, error) {
 				Name:     file.Name,
 				Size:     file.Size,
 				UploadAt: file.UploadAt,
+				Encoding: file.Encoding,
+				RawPath:  file.RawPath,
+				Project:  file.Project,
 				Entries:  entries,
 				Total:    len(entries),
 			}
@@ -256,6 +133,45 @@ func (s *StorageService) LoadParsedLogs(fileID string) (*model.LogFile, error) {
 	return nil, fmt.Errorf("日志文件不存在: %s", fileID)
 }
 
+// ReadRawLines 从保留的原始文件中读取第start至end行的完整内容（不截断），文件记录不存在时返回 sql.ErrNoRows
+func (s *StorageService) ReadRawLines(fileID string, start, end int) ([]string, error) {
+	logFile, err := s.database.GetLogFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if logFile.RawPath == "" {
+		return nil, fmt.Errorf("未保留原始文件")
+	}
+	reader, _, err := openLogReader(logFile.RawPath, logFile.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	lr := newLineReader(reader, 0)
+	lines := make([]string, 0, end-start+1)
+	for number := 1; number <= end; number++ {
+		line, _, err := lr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取文件失败: %w", err)
+		}
+		if number >= start {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("行号超出文件范围: %d", start)
+	}
+	return lines, nil
+}
+
+// GetLogFileDiagnostics 获取文件的解析诊断信息
+func (s *StorageService) GetLogFileDiagnostics(fileID string) (*model.ParseDiagnostics, error) {
+	return s.database.GetLogFileDiagnostics(fileID)
+}
+
 func (s *StorageService) GetUploadedFiles() ([]model.LogFile, error) {
 	// 从数据库获取所有日志文件
 	return s.database.GetLogFiles()
@@ -278,21 +194,24 @@ func (s *StorageService) ValidateFile(file *os.File, filename string) error {
 	}
 
 	// 检查文件扩展名
-	ext := strings.ToLower(filepath.Ext(filename))
-	allowedExts := []string{".txt", ".log", ".rar", ".gz", ".zip", ".7z"}
-
-	allowed := false
-	for _, allowedExt := range allowedExts {
-		if ext == allowedExt {
-			allowed = true
-			break
-		}
+	ext := fileExt(filename)
+	if isLogFileName(filename) || slices.Contains(archiveExts, ext) {
+		return nil
 	}
 
-	if !allowed {
-		return fmt.Errorf("不支持的文件类型: %s", ext)
+	// 无扩展名或其他扩展名的文件（如 messages、kmsg、bugreport）按内容判断
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("重置文件指针失败: %w", err)
+	}
+	if extractor, _ := identifyArchive(filename, file); extractor != nil {
+		return nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("重置文件指针失败: %w", err)
+	}
+	if ok, reason := sniffLog(file); !ok {
+		return fmt.Errorf("不支持的文件类型: %s", reason)
 	}
-
 	return nil
 }
 
@@ -306,9 +225,14 @@ func (s *StorageService) GetLogStats(fileID string, filter model.LogFilter) (mod
 	return s.database.GetLogStats(fileID, filter)
 }
 
-// 新增：从数据库搜索日志
-func (s *StorageService) SearchLogs(fileID string, query string, limit int) ([]model.LogEntry, error) {
-	return s.database.SearchLogs(fileID, query, limit)
+// AggregateLogs 按字段分组统计日志
+func (s *StorageService) AggregateLogs(fileID string, filter model.LogFilter, groupBy, metric string, limit int) ([]model.AggregateBucket, error) {
+	return s.database.AggregateLogs(fileID, filter, groupBy, metric, limit)
+}
+
+// SearchLogs 全文检索日志的消息和原始内容
+func (s *StorageService) SearchLogs(fileID string, query model.SearchQuery) ([]model.SearchHit, error) {
+	return s.database.SearchLogs(fileID, query)
 }
 
 func (s *StorageService) GetModuleOptions(fileID string) ([]*string, error) {
@@ -89,7 +89,7 @@ func Qwen3Chat(apiKey string, model *string, msg string) (*string, error) {
 	// 检查 HTTP 状态码
 	if resp.StatusCode != http.StatusOK {
 		fmt.Printf("请求失败，状态码: %d, 响应: %s\n", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("请求失败，状态码:%s", resp.StatusCode)
+		return nil, fmt.Errorf("请求失败，状态码:%d", resp.StatusCode)
 	}
 
 	// 解析响应
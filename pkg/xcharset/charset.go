@@ -0,0 +1,228 @@
+package xcharset
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// 自动识别的编码名称（与 htmlindex 的标准名称一致）
+const (
+	UTF8    = "utf-8"
+	UTF16LE = "utf-16le"
+	UTF16BE = "utf-16be"
+	GBK     = "gbk"
+	GB18030 = "gb18030"
+)
+
+// Auto 自动识别编码
+const Auto = "auto"
+
+// DetectSize 识别编码时读取的字节数
+const DetectSize = 64 * 1024
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Normalize 校验编码名称并返回标准名称，如 GB2312、cp936 返回 gbk，为空或 auto 时返回空字符串表示自动识别
+func Normalize(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, Auto) {
+		return "", nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("不支持的编码: %s", name)
+	}
+	canonical, err := htmlindex.Name(enc)
+	if err != nil {
+		return "", fmt.Errorf("不支持的编码: %s", name)
+	}
+	return canonical, nil
+}
+
+// Detect 按BOM、UTF-16零字节分布、UTF-8合法性、GBK/GB18030字节结构依次识别编码，均不符合时按UTF-8处理
+func Detect(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, bomUTF8):
+		return UTF8
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return UTF16LE
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return UTF16BE
+	}
+	if name := detectUTF16(sample); name != "" {
+		return name
+	}
+	if validUTF8(sample) {
+		return UTF8
+	}
+	if valid, fourByte := validGB18030(sample); valid {
+		if fourByte {
+			return GB18030
+		}
+		return GBK
+	}
+	return UTF8
+}
+
+// IsText 按样本判断内容是否为文本：UTF-16编码的视为文本，其他编码要求不含NUL字节且控制字符不超过10%
+func IsText(sample []byte) bool {
+	if len(sample) == 0 {
+		return false
+	}
+	if bytes.HasPrefix(sample, bomUTF16LE) || bytes.HasPrefix(sample, bomUTF16BE) || detectUTF16(sample) != "" {
+		return true
+	}
+	control := 0
+	for _, b := range sample {
+		switch {
+		case b == 0:
+			return false
+		case b == '\t' || b == '\n' || b == '\r' || b == '\f' || b == '\v' || b == '\b' || b == 0x1B:
+			// 制表、换行及终端颜色转义等常见于日志
+		case b < 0x20 || b == 0x7F:
+			control++
+		}
+	}
+	return control*10 <= len(sample)
+}
+
+// detectUTF16 无BOM时，按奇偶位置上零字节的比例识别UTF-16（ASCII为主的文本每个字符含一个零字节）
+func detectUTF16(sample []byte) string {
+	n := len(sample) / 2 * 2
+	if n < 4 {
+		return ""
+	}
+	var even, odd int
+	for i := 0; i < n; i += 2 {
+		if sample[i] == 0 {
+			even++
+		}
+		if sample[i+1] == 0 {
+			odd++
+		}
+	}
+	pairs := n / 2
+	switch {
+	case odd*10 >= pairs*3 && even*10 < pairs:
+		return UTF16LE
+	case even*10 >= pairs*3 && odd*10 < pairs:
+		return UTF16BE
+	}
+	return ""
+}
+
+// validUTF8 判断样本是否为合法UTF-8，忽略末尾被截断的字符
+func validUTF8(sample []byte) bool {
+	for len(sample) > 0 {
+		r, size := utf8.DecodeRune(sample)
+		if r == utf8.RuneError && size == 1 {
+			return len(sample) < utf8.UTFMax && !utf8.FullRune(sample)
+		}
+		sample = sample[size:]
+	}
+	return true
+}
+
+// validGB18030 判断样本是否符合GBK双字节或GB18030四字节结构，忽略末尾被截断的字符，
+// fourByte 表示样本中存在四字节编码（GBK中没有）
+func validGB18030(sample []byte) (valid, fourByte bool) {
+	multi := false
+	for i := 0; i < len(sample); {
+		b := sample[i]
+		if b < 0x80 {
+			i++
+			continue
+		}
+		if b == 0x80 || b == 0xFF {
+			return false, false
+		}
+		if i+1 >= len(sample) {
+			break
+		}
+		t := sample[i+1]
+		switch {
+		case t >= 0x40 && t <= 0xFE && t != 0x7F:
+			i += 2
+		case t >= 0x30 && t <= 0x39:
+			if i+3 >= len(sample) {
+				i = len(sample)
+				continue
+			}
+			if sample[i+2] < 0x81 || sample[i+2] > 0xFE || sample[i+3] < 0x30 || sample[i+3] > 0x39 {
+				return false, false
+			}
+			fourByte = true
+			i += 4
+		default:
+			return false, false
+		}
+		multi = true
+	}
+	return multi, fourByte
+}
+
+// NewReader 返回将输入转换为UTF-8的reader及实际使用的编码，charset为空或auto时自动识别
+// 开头的BOM会被去除，非法字节序列替换为U+FFFD
+func NewReader(r io.Reader, charset string) (io.Reader, string, error) {
+	name, err := Normalize(charset)
+	if err != nil {
+		return nil, "", err
+	}
+	br := bufio.NewReaderSize(r, DetectSize)
+	sample, err := br.Peek(DetectSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	if name == "" {
+		name = Detect(sample)
+	}
+	// 去除与编码一致的BOM
+	for _, bom := range [][]byte{bomUTF8, bomUTF16LE, bomUTF16BE} {
+		if bytes.HasPrefix(sample, bom) && bomMatches(name, bom) {
+			if _, err := br.Discard(len(bom)); err != nil {
+				return nil, "", err
+			}
+			break
+		}
+	}
+	var enc encoding.Encoding
+	switch name {
+	case UTF8:
+		// 解码器将非法字节替换为U+FFFD
+		enc = unicode.UTF8
+	case UTF16LE:
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case UTF16BE:
+		enc = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	default:
+		if enc, err = htmlindex.Get(name); err != nil {
+			return nil, "", fmt.Errorf("不支持的编码: %s", name)
+		}
+	}
+	return transform.NewReader(br, enc.NewDecoder()), name, nil
+}
+
+func bomMatches(name string, bom []byte) bool {
+	switch name {
+	case UTF8:
+		return bytes.Equal(bom, bomUTF8)
+	case UTF16LE:
+		return bytes.Equal(bom, bomUTF16LE)
+	case UTF16BE:
+		return bytes.Equal(bom, bomUTF16BE)
+	}
+	return false
+}
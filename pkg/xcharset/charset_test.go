@@ -0,0 +1,117 @@
+package xcharset
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const text = "2024-08-02 15:56:24 INFO 车辆启动完成 VIN=LSVAU2180N2183294\n"
+
+func encode(t *testing.T, name string) []byte {
+	t.Helper()
+	var data []byte
+	var err error
+	switch name {
+	case "gbk":
+		data, err = simplifiedchinese.GBK.NewEncoder().Bytes([]byte(text))
+	case "gb18030":
+		// 𠀀 不在GBK中，GB18030中为四字节编码
+		data, err = simplifiedchinese.GB18030.NewEncoder().Bytes([]byte(text + "𠀀\n"))
+	case "utf-16le-bom":
+		data, err = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(text))
+	case "utf-16be":
+		data, err = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(text))
+	case "utf-8-bom":
+		data = append([]byte{0xEF, 0xBB, 0xBF}, text...)
+	default:
+		data = []byte(text)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectAndDecode(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		text  string
+	}{
+		{"utf-8", UTF8, text},
+		{"utf-8-bom", UTF8, text},
+		{"utf-16le-bom", UTF16LE, text},
+		{"utf-16be", UTF16BE, text},
+		{"gbk", GBK, text},
+		{"gb18030", GB18030, text + "𠀀\n"},
+	}
+	for _, tt := range tests {
+		data := encode(t, tt.input)
+		if got := Detect(data); got != tt.want {
+			t.Errorf("Detect(%s) = %s, want %s", tt.input, got, tt.want)
+		}
+		r, name, err := NewReader(bytes.NewReader(data), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != tt.want || string(out) != tt.text {
+			t.Errorf("NewReader(%s) = %s %q", tt.input, name, out)
+		}
+	}
+}
+
+func TestOverrideAndInvalidBytes(t *testing.T) {
+	// 指定编码时不再识别
+	r, name, err := NewReader(bytes.NewReader(encode(t, "gbk")), "GB2312")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := io.ReadAll(r); name != GBK || string(out) != text {
+		t.Errorf("override = %s %q", name, out)
+	}
+
+	// 非法UTF-8字节替换为U+FFFD
+	r, _, err = NewReader(bytes.NewReader([]byte("ok \xff\xfe\xfd end\n")), UTF8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := io.ReadAll(r); string(out) != "ok ��� end\n" {
+		t.Errorf("invalid = %q", out)
+	}
+
+	if _, err := Normalize("no-such-charset"); err == nil {
+		t.Error("未知编码应返回错误")
+	}
+	if name, err := Normalize("Auto"); err != nil || name != "" {
+		t.Errorf("auto = %q, %v", name, err)
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"utf-8", encode(t, "utf-8"), true},
+		{"gbk", encode(t, "gbk"), true},
+		{"utf-16be", encode(t, "utf-16be"), true},
+		{"ansi color", []byte("\x1b[31mE/ActivityManager: ANR\x1b[0m\n"), true},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), false},
+		{"control", []byte("\x01\x02\x03\x04abc"), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsText(tt.data); got != tt.want {
+			t.Errorf("IsText(%s) = %v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package xframe
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// Protocol 二进制帧协议定义，偏移均以字节计，负数表示从帧尾倒数
+type Protocol struct {
+	Header       string    `json:"header"`        // 帧头（十六进制），如 "55 78"，为空时不校验
+	Length       *Field    `json:"length"`        // 长度字段，为空时整段数据作为一帧
+	LengthAdjust int       `json:"length_adjust"` // 帧总长度 = 长度字段值 + length_adjust
+	Command      *Field    `json:"command"`       // 命令ID字段
+	Commands     []Command `json:"commands"`      // 命令表
+	Fields       []Field   `json:"fields"`        // 所有命令共有的字段
+	Checksum     *Checksum `json:"checksum"`      // 校验，为空时不校验
+}
+
+// Command 命令表中的一项
+type Command struct {
+	ID     string  `json:"id"`     // 命令ID（十六进制，按帧中的字节顺序），如 "d9"
+	Name   string  `json:"name"`   // 命令名称
+	Fields []Field `json:"fields"` // 该命令特有的字段
+}
+
+// Field 帧中的一个字段
+type Field struct {
+	Name   string  `json:"name"`   // 字段名
+	Offset int     `json:"offset"` // 起始偏移
+	Size   int     `json:"size"`   // 字节数，数值类型为1-8，hex/ascii为0时取到校验值之前
+	Type   string  `json:"type"`   // 类型[uint,int,hex,ascii]，为空时按uint处理
+	Endian string  `json:"endian"` // 字节序[big,little]，为空时按big处理
+	Scale  float64 `json:"scale"`  // 数值的缩放系数，为0时不缩放
+}
+
+// Checksum 帧校验
+type Checksum struct {
+	Algorithm string `json:"algorithm"` // 算法[sum8,xor8,sum16,crc8,crc16_modbus,crc16_ccitt,crc32]
+	Start     int    `json:"start"`     // 参与计算的起始偏移
+	End       int    `json:"end"`       // 参与计算的结束偏移（不含），为0时到校验值之前
+	Offset    int    `json:"offset"`    // 校验值的偏移，为0时位于帧尾
+	Endian    string `json:"endian"`    // 校验值的字节序[big,little]，为空时按big处理
+}
+
+// 字段类型
+const (
+	TypeUint  = "uint"  // 无符号整数（默认）
+	TypeInt   = "int"   // 有符号整数
+	TypeHex   = "hex"   // 原始字节的十六进制
+	TypeASCII = "ascii" // 字符串，去掉末尾的0
+)
+
+// 字节序
+const (
+	EndianBig    = "big"
+	EndianLittle = "little"
+)
+
+// 解码告警类型
+const (
+	WarnChecksum  = "checksum"  // 校验值不符
+	WarnLength    = "length"    // 长度字段与实际长度不符
+	WarnTruncated = "truncated" // 帧不完整，部分字段无法解码
+	WarnCommand   = "command"   // 命令ID不在命令表中
+)
+
+// ErrHeader 帧头不符，数据不属于该协议
+var ErrHeader = errors.New("帧头不符")
+
+// Warning 解码过程中发现的问题，帧仍会尽量解码
+type Warning struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Frame 解码结果
+type Frame struct {
+	Command   string                 // 命令名称，不在命令表中时为空
+	CommandID string                 // 命令ID（十六进制），未配置命令字段时为空
+	Length    int                    // 帧长度
+	Fields    map[string]interface{} // 字段名 -> 值[int64,float64,string]
+	Checksum  *bool                  // 校验是否通过，未配置校验时为nil
+	Warnings  []Warning
+}
+
+// algorithm 校验算法
+type algorithm struct {
+	size int
+	sum  func([]byte) uint64
+}
+
+var algorithms = map[string]algorithm{
+	"sum8":         {1, func(b []byte) uint64 { return sum(b) & 0xff }},
+	"xor8":         {1, xor8},
+	"sum16":        {2, func(b []byte) uint64 { return sum(b) & 0xffff }},
+	"crc8":         {1, crc8},
+	"crc16_modbus": {2, crc16Modbus},
+	"crc16_ccitt":  {2, crc16CCITT},
+	"crc32":        {4, func(b []byte) uint64 { return uint64(crc32.ChecksumIEEE(b)) }},
+}
+
+// Decoder 按协议解码帧，创建后只读，可并发使用
+type Decoder struct {
+	header       []byte
+	length       *Field
+	lengthAdjust int
+	command      *Field
+	commands     map[string]*Command
+	fields       []Field
+	checksum     *Checksum
+	algorithm    algorithm
+}
+
+// New 校验协议定义并创建解码器
+func New(p Protocol) (*Decoder, error) {
+	d := &Decoder{lengthAdjust: p.LengthAdjust, fields: p.Fields, commands: make(map[string]*Command, len(p.Commands))}
+	if p.Header != "" {
+		header, err := ParseHex(p.Header)
+		if err != nil {
+			return nil, fmt.Errorf("帧头错误: %w", err)
+		}
+		d.header = header
+	}
+	if p.Length != nil {
+		if err := validateNumber(p.Length); err != nil {
+			return nil, fmt.Errorf("长度字段错误: %w", err)
+		}
+		d.length = p.Length
+	}
+	if p.Command != nil {
+		if err := validateNumber(p.Command); err != nil {
+			return nil, fmt.Errorf("命令字段错误: %w", err)
+		}
+		d.command = p.Command
+	} else if len(p.Commands) > 0 {
+		return nil, fmt.Errorf("配置了命令表但未配置命令字段")
+	}
+	for i := range p.Commands {
+		c := &p.Commands[i]
+		id, err := ParseHex(c.ID)
+		if err != nil || len(id) == 0 {
+			return nil, fmt.Errorf("命令ID错误: %q", c.ID)
+		}
+		key := hex.EncodeToString(id)
+		if _, ok := d.commands[key]; ok {
+			return nil, fmt.Errorf("命令ID重复: %s", c.ID)
+		}
+		if err := validateFields(c.Fields); err != nil {
+			return nil, fmt.Errorf("命令%s: %w", c.ID, err)
+		}
+		d.commands[key] = c
+	}
+	if err := validateFields(p.Fields); err != nil {
+		return nil, err
+	}
+	if p.Checksum != nil {
+		algo, ok := algorithms[p.Checksum.Algorithm]
+		if !ok {
+			return nil, fmt.Errorf("不支持的校验算法: %s", p.Checksum.Algorithm)
+		}
+		if err := validateEndian(p.Checksum.Endian); err != nil {
+			return nil, fmt.Errorf("校验: %w", err)
+		}
+		d.checksum = p.Checksum
+		d.algorithm = algo
+	}
+	return d, nil
+}
+
+func validateFields(fields []Field) error {
+	names := make(map[string]bool, len(fields))
+	for i := range fields {
+		f := &fields[i]
+		if f.Name == "" {
+			return fmt.Errorf("字段必须配置name")
+		}
+		if names[f.Name] {
+			return fmt.Errorf("字段重复: %s", f.Name)
+		}
+		names[f.Name] = true
+		var err error
+		switch f.Type {
+		case "", TypeUint, TypeInt:
+			err = validateNumber(f)
+		case TypeHex, TypeASCII:
+			if f.Size < 0 {
+				err = fmt.Errorf("size不能为负数")
+			}
+		default:
+			err = fmt.Errorf("类型不支持: %s", f.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("字段%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateNumber(f *Field) error {
+	if f.Size < 1 || f.Size > 8 {
+		return fmt.Errorf("数值字段的size必须为1-8")
+	}
+	return validateEndian(f.Endian)
+}
+
+func validateEndian(endian string) error {
+	if endian != "" && endian != EndianBig && endian != EndianLittle {
+		return fmt.Errorf("字节序不支持: %s", endian)
+	}
+	return nil
+}
+
+// ParseHex 解析十六进制文本，字节之间可用空白、冒号、短横线或逗号分隔，允许0x前缀，
+// 分隔后的单个字符按一个字节处理（如 "5 a" 为 05 0a）
+func ParseHex(s string) ([]byte, error) {
+	tokens := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ':' || r == '-' || r == ','
+	})
+	var data []byte
+	for _, token := range tokens {
+		if len(token) > 2 && (token[:2] == "0x" || token[:2] == "0X") {
+			token = token[2:]
+		}
+		if len(token) == 1 {
+			token = "0" + token
+		}
+		b, err := hex.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("无效的十六进制: %s", token)
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
+
+// Decode 解码一帧，帧头不符时返回 ErrHeader；长度、校验等问题记录为告警，字段仍尽量解码
+func (d *Decoder) Decode(data []byte) (*Frame, error) {
+	if len(d.header) > 0 && (len(data) < len(d.header) || string(data[:len(d.header)]) != string(d.header)) {
+		return nil, ErrHeader
+	}
+	frame := &Frame{Length: len(data), Fields: make(map[string]interface{})}
+	if d.length != nil {
+		if value, ok := readUint(data, d.length); !ok {
+			frame.warn(WarnTruncated, "帧不完整，缺少长度字段")
+		} else if want := int(value) + d.lengthAdjust; want > len(data) {
+			frame.warn(WarnTruncated, fmt.Sprintf("帧不完整，长度字段为%d字节，实际%d字节", want, len(data)))
+		} else if want <= 0 {
+			frame.warn(WarnLength, fmt.Sprintf("长度字段无效: %d", want))
+		} else if want < len(data) {
+			frame.warn(WarnLength, fmt.Sprintf("长度字段为%d字节，实际%d字节，多余部分已忽略", want, len(data)))
+			data = data[:want]
+			frame.Length = want
+		}
+	}
+	fields := d.fields
+	if d.command != nil {
+		if id, ok := readBytes(data, d.command.Offset, d.command.Size); ok {
+			frame.CommandID = hex.EncodeToString(id)
+			if c, ok := d.commands[frame.CommandID]; ok {
+				frame.Command = c.Name
+				fields = append(fields[:len(fields):len(fields)], c.Fields...)
+			} else if len(d.commands) > 0 {
+				frame.warn(WarnCommand, "未知命令: "+frame.CommandID)
+			}
+		} else {
+			frame.warn(WarnTruncated, "帧不完整，缺少命令字段")
+		}
+	}
+	end := d.payloadEnd(len(data))
+	for i := range fields {
+		f := &fields[i]
+		value, ok := decodeField(data, end, f)
+		if !ok {
+			frame.warn(WarnTruncated, "帧不完整，缺少字段"+f.Name)
+			continue
+		}
+		frame.Fields[f.Name] = value
+	}
+	if d.checksum != nil {
+		d.verify(data, frame)
+	}
+	return frame, nil
+}
+
+func (f *Frame) warn(kind, message string) {
+	f.Warnings = append(f.Warnings, Warning{Kind: kind, Message: message})
+}
+
+// checksumOffset 校验值在帧中的起始偏移
+func (d *Decoder) checksumOffset(n int) int {
+	if d.checksum.Offset == 0 {
+		return n - d.algorithm.size
+	}
+	return offset(d.checksum.Offset, n)
+}
+
+// payloadEnd 变长字段的结束位置：校验值位于末尾时为校验值之前，否则为帧尾
+func (d *Decoder) payloadEnd(n int) int {
+	if d.checksum == nil {
+		return n
+	}
+	if at := d.checksumOffset(n); at >= 0 && at+d.algorithm.size == n {
+		return at
+	}
+	return n
+}
+
+// verify 计算校验值并与帧中的校验值比较
+func (d *Decoder) verify(data []byte, frame *Frame) {
+	n := len(data)
+	at := d.checksumOffset(n)
+	start := offset(d.checksum.Start, n)
+	end := at
+	if d.checksum.End != 0 {
+		end = offset(d.checksum.End, n)
+	}
+	if at < 0 || at+d.algorithm.size > n || start < 0 || end > n || start > end {
+		frame.warn(WarnTruncated, "帧不完整，无法校验")
+		return
+	}
+	field := &Field{Offset: at, Size: d.algorithm.size, Endian: d.checksum.Endian}
+	got, _ := readUint(data, field)
+	want := d.algorithm.sum(data[start:end])
+	ok := got == want
+	frame.Checksum = &ok
+	if !ok {
+		width := d.algorithm.size * 2
+		frame.warn(WarnChecksum, fmt.Sprintf("%s校验失败: 帧中为%0*x，计算为%0*x", d.checksum.Algorithm, width, got, width, want))
+	}
+}
+
+// offset 将负数偏移转换为从帧头开始的偏移
+func offset(off, n int) int {
+	if off < 0 {
+		return n + off
+	}
+	return off
+}
+
+func readBytes(data []byte, off, size int) ([]byte, bool) {
+	start := offset(off, len(data))
+	if start < 0 || size <= 0 || start+size > len(data) {
+		return nil, false
+	}
+	return data[start : start+size], true
+}
+
+// readUint 按字节序读取无符号整数
+func readUint(data []byte, f *Field) (uint64, bool) {
+	b, ok := readBytes(data, f.Offset, f.Size)
+	if !ok {
+		return 0, false
+	}
+	var buf [8]byte
+	if f.Endian == EndianLittle {
+		copy(buf[:], b)
+		return binary.LittleEndian.Uint64(buf[:]), true
+	}
+	copy(buf[8-len(b):], b)
+	return binary.BigEndian.Uint64(buf[:]), true
+}
+
+// decodeField 按类型解码字段，end 为变长字段的结束位置
+func decodeField(data []byte, end int, f *Field) (interface{}, bool) {
+	switch f.Type {
+	case TypeHex, TypeASCII:
+		start := offset(f.Offset, len(data))
+		stop := end
+		if f.Size > 0 {
+			stop = start + f.Size
+		}
+		if start < 0 || stop > len(data) || start > stop {
+			return nil, false
+		}
+		if f.Type == TypeHex {
+			return hex.EncodeToString(data[start:stop]), true
+		}
+		return strings.TrimRight(string(data[start:stop]), "\x00"), true
+	}
+	u, ok := readUint(data, f)
+	if !ok {
+		return nil, false
+	}
+	value := int64(u)
+	if f.Type == TypeInt && f.Size < 8 {
+		// 符号扩展
+		shift := uint(64 - f.Size*8)
+		value = int64(u<<shift) >> shift
+	}
+	if f.Scale != 0 {
+		return float64(value) * f.Scale, true
+	}
+	return value, true
+}
+
+func sum(b []byte) uint64 {
+	var s uint64
+	for _, c := range b {
+		s += uint64(c)
+	}
+	return s
+}
+
+func xor8(b []byte) uint64 {
+	var x byte
+	for _, c := range b {
+		x ^= c
+	}
+	return uint64(x)
+}
+
+// crc8 多项式0x07，初始值0
+func crc8(b []byte) uint64 {
+	var crc byte
+	for _, c := range b {
+		crc ^= c
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint64(crc)
+}
+
+// crc16Modbus 多项式0x8005（反射），初始值0xFFFF
+func crc16Modbus(b []byte) uint64 {
+	crc := uint16(0xffff)
+	for _, c := range b {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return uint64(crc)
+}
+
+// crc16CCITT CCITT-FALSE，多项式0x1021，初始值0xFFFF
+func crc16CCITT(b []byte) uint64 {
+	crc := uint16(0xffff)
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint64(crc)
+}
@@ -0,0 +1,176 @@
+package xframe
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func mcuProtocol() Protocol {
+	return Protocol{
+		Header:  "55 78",
+		Command: &Field{Offset: 2, Size: 1},
+		Length:  &Field{Offset: 3, Size: 1},
+		Commands: []Command{
+			{ID: "d9", Name: "按键上报", Fields: []Field{
+				{Name: "key", Offset: 6, Size: 1},
+				{Name: "state", Offset: 7, Size: 1},
+			}},
+			{ID: "0x30", Name: "温度上报", Fields: []Field{
+				{Name: "temp", Offset: 6, Size: 2, Type: TypeInt, Endian: EndianLittle, Scale: 0.1},
+				{Name: "sn", Offset: 8, Type: TypeASCII},
+			}},
+		},
+		Fields:   []Field{{Name: "seq", Offset: 4, Size: 2}},
+		Checksum: &Checksum{Algorithm: "sum8"},
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := map[string][]byte{
+		"55 78 d9 09":    {0x55, 0x78, 0xd9, 0x09},
+		"0x55,0X78":      {0x55, 0x78},
+		"55:78-D9":       {0x55, 0x78, 0xd9},
+		"5578d9":         {0x55, 0x78, 0xd9},
+		"5 a\t0b":        {0x05, 0x0a, 0x0b},
+		"":               nil,
+		"  ":             nil,
+		"55 78 d9 09 50": {0x55, 0x78, 0xd9, 0x09, 0x50},
+	}
+	for s, want := range tests {
+		got, err := ParseHex(s)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("ParseHex(%q) = %x, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"557", "zz", "55 7g"} {
+		if _, err := ParseHex(s); err == nil {
+			t.Errorf("ParseHex(%q) 应返回错误", s)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	d, err := New(mcuProtocol())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(s string) *Frame {
+		t.Helper()
+		data, err := ParseHex(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame, err := d.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return frame
+	}
+
+	frame := decode("55 78 d9 09 50 ea 00 09 f2")
+	if frame.Command != "按键上报" || frame.CommandID != "d9" || frame.Length != 9 {
+		t.Errorf("frame = %+v", frame)
+	}
+	if want := map[string]interface{}{"seq": int64(0x50ea), "key": int64(0), "state": int64(9)}; !reflect.DeepEqual(frame.Fields, want) {
+		t.Errorf("fields = %v", frame.Fields)
+	}
+	if frame.Checksum == nil || !*frame.Checksum || len(frame.Warnings) != 0 {
+		t.Errorf("checksum = %v, warnings = %v", frame.Checksum, frame.Warnings)
+	}
+
+	// 校验值不符
+	frame = decode("55 78 d9 09 50 ea 00 09 07")
+	if frame.Checksum == nil || *frame.Checksum || len(frame.Warnings) != 1 || frame.Warnings[0].Kind != WarnChecksum {
+		t.Errorf("checksum = %v, warnings = %v", frame.Checksum, frame.Warnings)
+	}
+	if frame.Fields["state"] != int64(9) {
+		t.Error("校验失败时仍应解码字段")
+	}
+
+	// 有符号小端、缩放和变长字符串（到校验值之前为止）
+	frame = decode("55 78 30 0d 00 01 9c ff 41 42 43 00 6c")
+	if frame.Command != "温度上报" || frame.Fields["temp"] != -10.0 || frame.Fields["sn"] != "ABC" {
+		t.Errorf("frame = %+v", frame)
+	}
+	if len(frame.Warnings) != 0 {
+		t.Errorf("warnings = %v", frame.Warnings)
+	}
+
+	// 多余字节按长度字段截断
+	frame = decode("55 78 d9 09 50 ea 00 09 f2 ff ff")
+	if frame.Length != 9 || *frame.Checksum != true || len(frame.Warnings) != 1 || frame.Warnings[0].Kind != WarnLength {
+		t.Errorf("frame = %+v", frame)
+	}
+
+	// 不完整的帧
+	frame = decode("55 78 d9 09 50")
+	kinds := map[string]bool{}
+	for _, w := range frame.Warnings {
+		kinds[w.Kind] = true
+	}
+	if !kinds[WarnTruncated] || frame.Fields["seq"] != nil {
+		t.Errorf("frame = %+v", frame)
+	}
+
+	// 未知命令
+	frame = decode("55 78 01 07 00 01 d6")
+	if frame.Command != "" || frame.CommandID != "01" || len(frame.Warnings) != 1 || frame.Warnings[0].Kind != WarnCommand {
+		t.Errorf("frame = %+v", frame)
+	}
+
+	// 帧头不符
+	if _, err := d.Decode([]byte{0xaa, 0x78, 0xd9}); !errors.Is(err, ErrHeader) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestChecksumAlgorithms(t *testing.T) {
+	data := []byte("123456789")
+	tests := map[string]uint64{
+		"sum8":         0xdd,
+		"xor8":         0x31,
+		"sum16":        0x01dd,
+		"crc8":         0xf4,
+		"crc16_modbus": 0x4b37,
+		"crc16_ccitt":  0x29b1,
+		"crc32":        0xcbf43926,
+	}
+	for name, want := range tests {
+		if got := algorithms[name].sum(data); got != want {
+			t.Errorf("%s = %x, want %x", name, got, want)
+		}
+	}
+
+	// Modbus 帧：校验值低字节在前
+	d, err := New(Protocol{
+		Fields:   []Field{{Name: "addr", Offset: 0, Size: 1}, {Name: "func", Offset: 1, Size: 1}},
+		Checksum: &Checksum{Algorithm: "crc16_modbus", Endian: EndianLittle},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := d.Decode([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0a, 0xc5, 0xcd})
+	if err != nil || frame.Checksum == nil || !*frame.Checksum {
+		t.Errorf("modbus = %+v, %v", frame, err)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []Protocol{
+		{Header: "5x"},
+		{Length: &Field{Offset: 3, Size: 9}},
+		{Commands: []Command{{ID: "01", Name: "a"}}},
+		{Command: &Field{Size: 1}, Commands: []Command{{ID: "01"}, {ID: "0x01"}}},
+		{Fields: []Field{{Name: "a", Size: 1}, {Name: "a", Size: 1}}},
+		{Fields: []Field{{Name: "a", Size: 1, Type: "float"}}},
+		{Fields: []Field{{Name: "a", Size: 1, Endian: "middle"}}},
+		{Checksum: &Checksum{Algorithm: "md5"}},
+	}
+	for i, p := range tests {
+		if _, err := New(p); err == nil {
+			t.Errorf("#%d 应校验失败", i)
+		}
+	}
+}
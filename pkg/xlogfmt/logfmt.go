@@ -0,0 +1,125 @@
+package xlogfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Pair logfmt 中的一个键值对
+type Pair struct {
+	Key      string
+	Value    string
+	HasValue bool // 是否带有 = （单独的键如 "debug" 为 false）
+}
+
+// Parse 解析一行 logfmt 格式的文本，如 time=... level=INFO msg="hello world" key=value
+// 支持双引号包裹的值及其中的转义字符（\" \\ \n \t \r \uXXXX 等）
+func Parse(line string) ([]Pair, error) {
+	var pairs []Pair
+	i := 0
+	for {
+		// 跳过空白
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return pairs, nil
+		}
+		// 读取键
+		start := i
+		for i < len(line) && !isSpace(line[i]) && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("位置%d: 缺少键名", i)
+		}
+		pair := Pair{Key: line[start:i]}
+		if i >= len(line) || line[i] != '=' {
+			if i < len(line) && line[i] == '"' {
+				return nil, fmt.Errorf("位置%d: 键名中不允许出现引号", i)
+			}
+			pairs = append(pairs, pair)
+			continue
+		}
+		// 读取值
+		i++
+		pair.HasValue = true
+		if i < len(line) && line[i] == '"' {
+			value, n, err := unquote(line[i:])
+			if err != nil {
+				return nil, fmt.Errorf("位置%d: %w", i, err)
+			}
+			pair.Value = value
+			i += n
+			if i < len(line) && !isSpace(line[i]) {
+				return nil, fmt.Errorf("位置%d: 引号值后缺少空白", i)
+			}
+		} else {
+			start = i
+			for i < len(line) && !isSpace(line[i]) {
+				if line[i] == '"' {
+					return nil, fmt.Errorf("位置%d: 未加引号的值中不允许出现引号", i)
+				}
+				i++
+			}
+			pair.Value = line[start:i]
+		}
+		pairs = append(pairs, pair)
+	}
+}
+
+// unquote 解析以双引号开头的值，返回解码后的值和消耗的字节数
+func unquote(s string) (string, int, error) {
+	var b strings.Builder
+	i := 1
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1, nil
+		case c == '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("转义字符不完整")
+			}
+			i++
+			switch s[i] {
+			case '"', '\\', '/':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				if i+4 >= len(s) {
+					return "", 0, fmt.Errorf("\\u转义不完整")
+				}
+				r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("\\u转义错误: %w", err)
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("未知的转义字符: \\%c", s[i])
+			}
+			i++
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			i += size
+		}
+	}
+	return "", 0, fmt.Errorf("引号未闭合")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
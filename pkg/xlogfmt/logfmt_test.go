@@ -0,0 +1,63 @@
+package xlogfmt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want []Pair
+	}{
+		{
+			line: `time=2024-01-02T15:04:05.000+08:00 level=INFO msg="hello world" count=3`,
+			want: []Pair{
+				{Key: "time", Value: "2024-01-02T15:04:05.000+08:00", HasValue: true},
+				{Key: "level", Value: "INFO", HasValue: true},
+				{Key: "msg", Value: "hello world", HasValue: true},
+				{Key: "count", Value: "3", HasValue: true},
+			},
+		},
+		{
+			line: `msg="say \"hi\"\n\tnext" path=C:\temp empty= flag`,
+			want: []Pair{
+				{Key: "msg", Value: "say \"hi\"\n\tnext", HasValue: true},
+				{Key: "path", Value: `C:\temp`, HasValue: true},
+				{Key: "empty", Value: "", HasValue: true},
+				{Key: "flag"},
+			},
+		},
+		{
+			line: `req.id=42 user="张三" emoji="\u4e2d"`,
+			want: []Pair{
+				{Key: "req.id", Value: "42", HasValue: true},
+				{Key: "user", Value: "张三", HasValue: true},
+				{Key: "emoji", Value: "中", HasValue: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.line)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.line, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	lines := []string{
+		`msg="unterminated`,
+		`msg="bad escape \x"`,
+		`msg="a"b`,
+		`="no key"`,
+	}
+	for _, line := range lines {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("Parse(%q) expected error", line)
+		}
+	}
+}
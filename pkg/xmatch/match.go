@@ -21,3 +21,15 @@ func Match(pattern string, str string) string {
 	}
 	return ""
 }
+
+// MatchRegexp 使用预编译的正则提取第一个分组，正则为空时返回空字符串
+func MatchRegexp(r *regexp.Regexp, str string) string {
+	if r == nil {
+		return ""
+	}
+	match := r.FindStringSubmatch(str)
+	if len(match) > 1 {
+		return match[1]
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package xtime
+
+// detectPatterns 自动识别时尝试的常见格式，得分相同时靠前的优先
+var detectPatterns = []string{
+	"yyyy-MM-dd HH:mm:ss.SSS",
+	"yyyy-MM-dd HH:mm:ss,SSS",
+	"yyyy-MM-dd HH:mm:ss",
+	"yyyy-MM-dd'T'HH:mm:ss.SSSXXX",
+	"yyyy-MM-dd'T'HH:mm:ssXXX",
+	"yyyy-MM-dd'T'HH:mm:ss.SSS",
+	"yyyy-MM-dd'T'HH:mm:ss",
+	"yyyy/MM/dd HH:mm:ss.SSS",
+	"yyyy/MM/dd HH:mm:ss",
+	"yyyyMMdd HH:mm:ss.SSS",
+	"yyyyMMddHHmmss",
+	"MM-dd HH:mm:ss.SSS",
+	"MM-dd HH:mm:ss",
+	"dd/MMM/yyyy:HH:mm:ss Z",
+	"dd-MMM-yyyy HH:mm:ss.SSS",
+	"EEE MMM d HH:mm:ss yyyy",
+	"EEE, dd MMM yyyy HH:mm:ss z",
+	"MMM d HH:mm:ss",
+	"MMM d, yyyy h:mm:ss a",
+	"HH:mm:ss.SSS",
+	"HH:mm:ss",
+	"epoch",
+}
+
+// Detect 从时间字符串样本中识别格式，返回解析成功率最高的格式及其成功率
+// 成功率相同时，按格式化结果与原文一致（如小数秒位数相同）的样本数择优
+func Detect(samples []string) (*Layout, float64) {
+	if len(samples) == 0 {
+		return nil, 0
+	}
+	var best *Layout
+	bestParsed, bestExact := 0, 0
+	for _, pattern := range detectPatterns {
+		l, err := Compile(pattern)
+		if err != nil {
+			continue
+		}
+		parsed, exact := 0, 0
+		for _, sample := range samples {
+			t, err := l.Parse(sample)
+			if err != nil {
+				continue
+			}
+			parsed++
+			if l.Format(t) == sample {
+				exact++
+			}
+		}
+		if parsed > bestParsed || parsed == bestParsed && exact > bestExact {
+			best, bestParsed, bestExact = l, parsed, exact
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	return best, float64(bestParsed) / float64(len(samples))
+}
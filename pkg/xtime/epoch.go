@@ -0,0 +1,62 @@
+package xtime
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseEpoch 按整数部分的量级将时间戳识别为秒/毫秒/微秒/纳秒
+func ParseEpoch(str string) (time.Time, bool) {
+	return parseEpoch(str, -1)
+}
+
+// parseEpoch 解析时间戳，unit 为每单位的纳秒数，小于0时按量级自动识别
+// 按十进制计算避免浮点精度丢失
+func parseEpoch(str string, unit int64) (time.Time, bool) {
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	n, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	scale := unit
+	if scale < 0 {
+		switch {
+		case n < 1e11:
+			scale = 1e9
+		case n < 1e14:
+			scale = 1e6
+		case n < 1e17:
+			scale = 1e3
+		default:
+			scale = 1
+		}
+	}
+	// 超出 time.Unix 纳秒可表示的范围时视为无效，避免溢出回绕成错误的时间
+	if n > math.MaxInt64/scale || n < math.MinInt64/scale {
+		return time.Time{}, false
+	}
+	nanos := n * scale
+	var frac int64
+	for _, c := range fracPart {
+		if c < '0' || c > '9' {
+			return time.Time{}, false
+		}
+		scale /= 10
+		frac += int64(c-'0') * scale
+	}
+	// 负数时间戳的小数部分同样远离零点，如 -1.5 表示 1970 年前 1.5 秒
+	if strings.HasPrefix(intPart, "-") {
+		if nanos < math.MinInt64+frac {
+			return time.Time{}, false
+		}
+		nanos -= frac
+	} else {
+		if nanos > math.MaxInt64-frac {
+			return time.Time{}, false
+		}
+		nanos += frac
+	}
+	return time.Unix(0, nanos), true
+}
@@ -1,45 +1,311 @@
 package xtime
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
 
+// Layout 预编译的日期格式，支持 Java SimpleDateFormat / ICU 写法、epoch_* 时间戳格式，
+// 以及 Go 原生布局（如 2006-01-02 15:04:05）
+type Layout struct {
+	pattern string
+	parse   string // 解析用的 Go 布局，小数秒可接受任意位数
+	format  string // 格式化用的 Go 布局，小数秒按模式中的位数输出
+	epoch   int64  // epoch 格式每单位的纳秒数，0 表示非 epoch 格式，-1 表示按量级自动识别
+	zoned   bool   // 格式中包含时区
+}
+
+// epoch 时间戳格式及其单位
+var epochFormats = map[string]int64{
+	"epoch":    -1,
+	"epoch_s":  int64(time.Second),
+	"epoch_ms": int64(time.Millisecond),
+	"epoch_us": int64(time.Microsecond),
+	"epoch_ns": 1,
+}
+
+// Compile 解析日期格式
+func Compile(pattern string) (*Layout, error) {
+	if unit, ok := epochFormats[pattern]; ok {
+		return &Layout{pattern: pattern, epoch: unit, zoned: true}, nil
+	}
+	l, err := compileJava(pattern)
+	if err != nil {
+		// 无法按 Java 格式解析且包含数字时，按 Go 原生布局处理（如 January 2, 2006）
+		if !strings.ContainsAny(pattern, "0123456789") {
+			return nil, err
+		}
+		l = &Layout{pattern: pattern, parse: pattern, format: pattern}
+	}
+	l.zoned = goLayoutHasZone(l.parse)
+	return l, nil
+}
+
+// compileJava 按 SimpleDateFormat / ICU 规则转换格式，未加引号的数字原样保留为 Go 布局
+func compileJava(pattern string) (*Layout, error) {
+	tokens, err := tokenize(pattern)
+	if err != nil {
+		return nil, err
+	}
+	l := &Layout{pattern: pattern}
+	var parse, format strings.Builder
+	hasAmPm := false
+	for _, tok := range tokens {
+		if tok.letter == 'a' {
+			hasAmPm = true
+		}
+	}
+	for i, tok := range tokens {
+		if tok.letter == 0 {
+			parse.WriteString(tok.text)
+			format.WriteString(tok.text)
+			continue
+		}
+		if tok.letter == 'S' {
+			// 小数秒必须紧跟在秒和分隔符（. 或 ,）之后，Go 布局中分隔符属于小数秒的一部分
+			if i == 0 || !strings.HasSuffix(tokens[i-1].text, ".") && !strings.HasSuffix(tokens[i-1].text, ",") {
+				return nil, fmt.Errorf("小数秒%s前必须是分隔符.或,: %s", strings.Repeat("S", tok.count), pattern)
+			}
+			parse.WriteString(strings.Repeat("9", tok.count))
+			format.WriteString(strings.Repeat("0", tok.count))
+			continue
+		}
+		value, err := goToken(tok, hasAmPm)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, pattern)
+		}
+		parse.WriteString(value)
+		format.WriteString(value)
+	}
+	l.parse = parse.String()
+	l.format = format.String()
+	return l, nil
+}
+
+// Pattern 返回原始格式
+func (l *Layout) Pattern() string {
+	return l.pattern
+}
+
+// HasZone 格式中是否包含时区（epoch 时间戳为绝对时间，视为包含时区）
+// 不含时区的时间解析结果为UTC，需由调用方按实际时区解释
+func (l *Layout) HasZone() bool {
+	return l.zoned
+}
+
+// Parse 解析时间字符串
+func (l *Layout) Parse(value string) (time.Time, error) {
+	if l.epoch != 0 {
+		t, ok := parseEpoch(value, l.epoch)
+		if !ok {
+			return time.Time{}, fmt.Errorf("无法按%s解析时间: %s", l.pattern, value)
+		}
+		return t, nil
+	}
+	return time.Parse(l.parse, value)
+}
+
+// Format 格式化时间
+func (l *Layout) Format(t time.Time) string {
+	if l.epoch != 0 {
+		unit := l.epoch
+		if unit < 0 {
+			unit = int64(time.Millisecond)
+		}
+		return fmt.Sprint(t.UnixNano() / unit)
+	}
+	return t.Format(l.format)
+}
+
+// token 格式中的一个片段：连续的同一格式字母，或一段原样输出的文本
+type token struct {
+	letter rune // 格式字母，0 表示文本
+	count  int
+	text   string
+}
+
+// tokenize 按 SimpleDateFormat 规则切分格式：
+// 字母为格式字符，单引号内为原样文本，连续两个单引号表示单引号本身，其余字符原样输出
+func tokenize(pattern string) ([]token, error) {
+	var tokens []token
+	literal := func(text string) {
+		if n := len(tokens); n > 0 && tokens[n-1].letter == 0 {
+			tokens[n-1].text += text
+			return
+		}
+		tokens = append(tokens, token{text: text})
+	}
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case c == '\'':
+			if i+1 < len(runes) && runes[i+1] == '\'' {
+				literal("'")
+				i += 2
+				continue
+			}
+			var text strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("引号未闭合: %s", pattern)
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						text.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+				i++
+			}
+			literal(text.String())
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(runes) && runes[j] == c {
+				j++
+			}
+			tokens = append(tokens, token{letter: c, count: j - i})
+			i = j
+		default:
+			literal(string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// goToken 将格式字母转换为 Go 布局
+// 未使用上午/下午标记（a）时 h/K 按24小时制处理，兼容将 hh 当作 HH 使用的规则
+func goToken(tok token, hasAmPm bool) (string, error) {
+	n := tok.count
+	switch tok.letter {
+	case 'y', 'Y', 'u':
+		if n == 2 {
+			return "06", nil
+		}
+		return "2006", nil
+	case 'M', 'L':
+		switch {
+		case n == 1:
+			return "1", nil
+		case n == 2:
+			return "01", nil
+		case n == 3:
+			return "Jan", nil
+		default:
+			return "January", nil
+		}
+	case 'd':
+		if n == 1 {
+			return "2", nil
+		}
+		return "02", nil
+	case 'D':
+		if n >= 3 {
+			return "002", nil
+		}
+		return "__2", nil
+	case 'E':
+		if n >= 4 {
+			return "Monday", nil
+		}
+		return "Mon", nil
+	case 'a':
+		return "PM", nil
+	case 'H', 'k':
+		return "15", nil
+	case 'h', 'K':
+		if !hasAmPm {
+			return "15", nil
+		}
+		if n == 1 {
+			return "3", nil
+		}
+		return "03", nil
+	case 'm':
+		if n == 1 {
+			return "4", nil
+		}
+		return "04", nil
+	case 's':
+		if n == 1 {
+			return "5", nil
+		}
+		return "05", nil
+	case 'z':
+		return "MST", nil
+	case 'Z':
+		if n >= 5 {
+			return "Z07:00", nil
+		}
+		return "-0700", nil
+	case 'X':
+		switch n {
+		case 1:
+			return "Z07", nil
+		case 2:
+			return "Z0700", nil
+		default:
+			return "Z07:00", nil
+		}
+	case 'x':
+		switch n {
+		case 1:
+			return "-07", nil
+		case 2:
+			return "-0700", nil
+		default:
+			return "-07:00", nil
+		}
+	}
+	return "", fmt.Errorf("不支持的格式字符%c", tok.letter)
+}
+
+func goLayoutHasZone(layout string) bool {
+	for _, zone := range []string{"MST", "Z07", "-07"} {
+		if strings.Contains(layout, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatConvert 将 Java 风格的日期格式转换为 Go 布局，无法转换时原样返回
 func FormatConvert(layout string) string {
-	// 定义转换映射：将常见格式转为 Go 的格式
-	replacements := map[string]string{
-		"YYYY":    "2006",
-		"YY":      "06",
-		"MM":      "01",  // 月份
-		"Jan":     "Jan", // 英文月份缩写（Go 原生支持）
-		"January": "January",
-		"dd":      "02",   // 日期
-		"HH":      "15",   // 24小时制
-		"hh":      "03",   // 12小时制
-		"mm":      "04",   // 分钟
-		"ss":      "05",   // 秒
-		"SSS":     ".000", // 毫秒（Go 用 .000 表示）
-		"a":       "PM",   // 上午/下午
-	}
-
-	// 按长度排序键，避免部分匹配错误（比如 MM 被 dd 替换前先匹配了 M）
-	// 这里简单按字符串长度降序处理
-	keys := []string{"SSS", "YYYY", "HH", "hh", "mm", "ss", "MM", "dd", "a", "Jan", "January", "YY"}
-
-	result := layout
-	for _, k := range keys {
-		v := replacements[k]
-		result = strings.ReplaceAll(result, k, v)
-	}
-	return result
+	l, err := Compile(layout)
+	if err != nil || l.epoch != 0 {
+		return layout
+	}
+	return l.format
 }
 
 // FormatTime 使用类似 Java 的格式化字符串来格式化时间
 func FormatTime(t time.Time, layout string) string {
-	return t.Format(FormatConvert(layout))
+	l, err := Compile(layout)
+	if err != nil {
+		return t.Format(layout)
+	}
+	return l.Format(t)
 }
 
 // ParseTime 使用类似 Java 的格式化字符串来解析时间
+// 重复解析同一格式时应使用 Compile 预编译
 func ParseTime(timeStr, layout string) (time.Time, error) {
-	return time.Parse(FormatConvert(layout), timeStr)
+	l, err := Compile(layout)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return l.Parse(timeStr)
+}
+
+// HasZone 判断格式中是否包含时区，不含时区的时间解析结果为UTC，需由调用方按实际时区解释
+func HasZone(layout string) bool {
+	l, err := Compile(layout)
+	return err == nil && l.HasZone()
 }
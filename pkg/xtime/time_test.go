@@ -3,6 +3,7 @@ package xtime
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestParseTime(t *testing.T) {
@@ -22,3 +23,165 @@ func TestParseTime(t *testing.T) {
 	fmt.Println(FormatConvert("YYYY-MM-dd"))              // 2025-08-06
 	fmt.Println(FormatConvert("January 2, 2006-YYYY/06")) // August 6, 2025（Go 原生也支持）
 }
+
+func TestCompileTokens(t *testing.T) {
+	tests := []struct {
+		pattern string
+		parse   string
+		format  string
+	}{
+		{"yyyy", "2006", "2006"},
+		{"YYYY", "2006", "2006"},
+		{"yy", "06", "06"},
+		{"M", "1", "1"},
+		{"MM", "01", "01"},
+		{"MMM", "Jan", "Jan"},
+		{"MMMM", "January", "January"},
+		{"d", "2", "2"},
+		{"dd", "02", "02"},
+		{"DDD", "002", "002"},
+		{"EEE", "Mon", "Mon"},
+		{"EEEE", "Monday", "Monday"},
+		{"H", "15", "15"},
+		{"HH", "15", "15"},
+		{"hh", "15", "15"},
+		{"hh a", "03 PM", "03 PM"},
+		{"h:mm a", "3:04 PM", "3:04 PM"},
+		{"m", "4", "4"},
+		{"mm", "04", "04"},
+		{"s", "5", "5"},
+		{"ss", "05", "05"},
+		{"ss.S", "05.9", "05.0"},
+		{"ss.SSS", "05.999", "05.000"},
+		{"ss,SSSSSS", "05,999999", "05,000000"},
+		{"z", "MST", "MST"},
+		{"Z", "-0700", "-0700"},
+		{"ZZZZZ", "Z07:00", "Z07:00"},
+		{"X", "Z07", "Z07"},
+		{"XX", "Z0700", "Z0700"},
+		{"XXX", "Z07:00", "Z07:00"},
+		{"xxx", "-07:00", "-07:00"},
+		{"yyyy-MM-dd'T'HH:mm:ss", "2006-01-02T15:04:05", "2006-01-02T15:04:05"},
+		{"'at' HH 'o''clock'", "at 15 o'clock", "at 15 o'clock"},
+		{"dd/MMM/yyyy:HH:mm:ss Z", "02/Jan/2006:15:04:05 -0700", "02/Jan/2006:15:04:05 -0700"},
+		{"yyyy-MM-dd HH:mm:ss.000", "2006-01-02 15:04:05.000", "2006-01-02 15:04:05.000"},
+		{"January 2, 2006", "January 2, 2006", "January 2, 2006"},
+	}
+	for _, tt := range tests {
+		l, err := Compile(tt.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q) error: %v", tt.pattern, err)
+			continue
+		}
+		if l.parse != tt.parse || l.format != tt.format {
+			t.Errorf("Compile(%q) = %q / %q, want %q / %q", tt.pattern, l.parse, l.format, tt.parse, tt.format)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{"HHmmssSSS", "yyyy-MM-dd 'T", "yyyy-MM-dd QQ"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) 应返回错误", pattern)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	shanghai := time.FixedZone("", 8*3600)
+	tests := []struct {
+		pattern string
+		value   string
+		want    time.Time
+	}{
+		{"MM-dd HH:mm:ss.SSS", "08-02 15:56:24.951", time.Date(0, 8, 2, 15, 56, 24, 951000000, time.UTC)},
+		{"MM-dd hh:mm:ss", "08-02 15:56:24", time.Date(0, 8, 2, 15, 56, 24, 0, time.UTC)},
+		{"yyyy-MM-dd hh:mm:ss a", "2024-08-02 03:56:24 PM", time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)},
+		{"yyyy-MM-dd HH:mm:ss.SSS", "2024-08-02 15:56:24.951123", time.Date(2024, 8, 2, 15, 56, 24, 951123000, time.UTC)},
+		{"yyyy-MM-dd HH:mm:ss.SSS", "2024-08-02 15:56:24", time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)},
+		{"yyyy-MM-dd HH:mm:ss,SSS", "2024-08-02 15:56:24,951", time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)},
+		{"yyyy-MM-dd'T'HH:mm:ss.SSSXXX", "2024-08-02T15:56:24.951+08:00", time.Date(2024, 8, 2, 15, 56, 24, 951000000, shanghai)},
+		{"yyyy-MM-dd'T'HH:mm:ss.SSSXXX", "2024-08-02T07:56:24.951Z", time.Date(2024, 8, 2, 15, 56, 24, 951000000, shanghai)},
+		{"EEE MMM d HH:mm:ss yyyy", "Fri Aug 2 15:56:24 2024", time.Date(2024, 8, 2, 15, 56, 24, 0, time.UTC)},
+		{"epoch_s", "1722614184.951", time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)},
+		{"epoch_ms", "1722614184951", time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)},
+		{"epoch_us", "1722614184951123", time.Date(2024, 8, 2, 15, 56, 24, 951123000, time.UTC)},
+		{"epoch", "1722614184951", time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.value, tt.pattern)
+		if err != nil {
+			t.Errorf("ParseTime(%q, %q) error: %v", tt.value, tt.pattern, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q, %q) = %v, want %v", tt.value, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	ts := time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)
+	tests := map[string]string{
+		"yyyy-MM-dd HH:mm:ss.SSS": "2024-08-02 15:56:24.951",
+		"hh:mm:ss a":              "03:56:24 PM",
+		"MM-dd HH:mm:ss.SSSSSS":   "08-02 15:56:24.951000",
+		"epoch_ms":                "1722614184951",
+	}
+	for pattern, want := range tests {
+		if got := FormatTime(ts, pattern); got != want {
+			t.Errorf("FormatTime(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		samples []string
+		want    string
+	}{
+		{[]string{"2024-08-02 15:56:24.951", "2024-08-02 15:56:25.003"}, "yyyy-MM-dd HH:mm:ss.SSS"},
+		{[]string{"2024-08-02 15:56:24", "2024-08-02 15:56:25"}, "yyyy-MM-dd HH:mm:ss"},
+		{[]string{"2024-08-02 15:56:24,951"}, "yyyy-MM-dd HH:mm:ss,SSS"},
+		{[]string{"08-02 15:56:24.951", "bad", "08-02 15:56:25.039"}, "MM-dd HH:mm:ss.SSS"},
+		{[]string{"10/Oct/2000:13:55:36 -0700"}, "dd/MMM/yyyy:HH:mm:ss Z"},
+		{[]string{"2024-08-02T15:56:24.951+08:00"}, "yyyy-MM-dd'T'HH:mm:ss.SSSXXX"},
+		{[]string{"1722614184951"}, "epoch"},
+	}
+	for _, tt := range tests {
+		l, _ := Detect(tt.samples)
+		if l == nil || l.Pattern() != tt.want {
+			t.Errorf("Detect(%q) = %v, want %s", tt.samples, l, tt.want)
+		}
+	}
+	if l, ratio := Detect([]string{"not a time"}); l != nil || ratio != 0 {
+		t.Errorf("Detect 无法识别时应返回nil")
+	}
+}
+
+func TestParseEpoch(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"1722614184.951", time.Date(2024, 8, 2, 15, 56, 24, 951000000, time.UTC)},
+		{"-1.5", time.Unix(0, -1500000000)},
+		{"-0.25", time.Unix(0, -250000000)},
+		{"-86400", time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, ok := ParseEpoch(tt.value)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("ParseEpoch(%q) = %v, %v, want %v", tt.value, got, ok, tt.want)
+		}
+	}
+	// 超出纳秒可表示范围的时间戳不应溢出回绕
+	for _, value := range []string{"99999999999", "-99999999999", "9223372036.999999999", "-9223372036.9999999999", "1.2x"} {
+		if got, ok := ParseEpoch(value); ok {
+			t.Errorf("ParseEpoch(%q) = %v, want invalid", value, got)
+		}
+	}
+	if _, err := ParseTime("9223372036854775807", "epoch_s"); err == nil {
+		t.Error("epoch_s 溢出未报错")
+	}
+}
@@ -25,7 +25,10 @@ func InitRouter(r *gin.Engine, cfg *config.Config) {
 	fmt.Println("数据库初始化完成")
 	// 创建服务实例
 	fmt.Println("正在初始化服务...")
-	parser := service.NewLogParserWithRule(cfg, nil)
+	parser, err := service.NewLogParserWithRule(cfg, nil)
+	if err != nil {
+		log.Fatalf("初始化解析器失败: %v", err)
+	}
 	storage := service.NewStorageService(cfg, parser, database)
 	projectService := service.NewProjectService(cfg, parser, database)
 	fmt.Println("服务初始化完成")
@@ -48,20 +51,29 @@ func InitRouter(r *gin.Engine, cfg *config.Config) {
 		// 项目相关
 		api.GET("/projects", projectHandler.GetProjects)
 		api.POST("/projects/save", projectHandler.SaveProjects)
+		api.POST("/projects/detect", projectHandler.DetectProject) // 按样本自动识别项目
+		api.POST("/rules/test", projectHandler.TestRule)           // 规则试解析
 
 		// 文件上传相关
 		api.POST("/upload", uploadHandler.UploadFile)
 		api.GET("/files", uploadHandler.GetUploadedFiles)
 		api.DELETE("/files/:id", uploadHandler.DeleteFile)
+		api.GET("/files/:id/diagnostics", uploadHandler.GetFileDiagnostics) // 解析诊断
+		api.GET("/files/:id/raw", uploadHandler.GetRawLines)                // 原始行内容
 		api.POST("/files/batch-delete", uploadHandler.BatchDeleteFiles)
 
 		// 日志相关
 		api.POST("/logs", logHandler.GetLogs)
 		api.GET("/logs/stats", logHandler.GetLogStats)
 		api.GET("/logs/levels", logHandler.GetLogLevels)
+		api.GET("/logs/aggregate", logHandler.AggregateLogs) // 按字段分组统计
 		api.GET("/logs/search", logHandler.SearchLogs)
 		api.GET("/logs/module/options", logHandler.GetModuleOptions) // 获取日志模块选项
 
+		// 场景相关
+		api.GET("/scenes/timeline", logHandler.GetSceneTimeline)   // 场景时间线
+		api.GET("/scenes/sequences", logHandler.GetSceneSequences) // 场景顺序规则检查
+
 		// Ai 日志分析
 		api.POST("/logs/analysis", aiHandler.AnalysisLog)
 		api.POST("/logs/analysis/stream", aiHandler.AnalysisLogStream)
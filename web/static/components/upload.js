@@ -8,11 +8,11 @@ const UploadView = {
             drag 
             multiple 
             :show-file-list="false" 
-            accept=".log,.txt,.zip,.gz,.7z,.rar" 
+            accept=".log,.txt,.zip,.gz,.7z,.rar,.tar,.tgz,.bz2,.xz,.zst" 
             style="width: 100%;">
                 <i class="el-icon-upload" style="font-size: 48px; color: #409EFF;"></i>
                 <div class="el-upload__text">将文件拖到此处，或 <em>点击上传</em></div>
-                <div class="el-upload__tip" slot="tip">支持 .log、.txt、.zip、.gz、.7z、.rar 文件</div>
+                <div class="el-upload__tip" slot="tip">支持 .log、.txt、.zip、.7z、.rar、.tar 及 .gz、.tgz、.bz2、.xz、.zst 压缩文件</div>
             </el-upload>
         </div>
     `,
@@ -33,11 +33,11 @@ const UploadView = {
     },
     methods : {
         beforeUpload(file) {
-            const isValidType = ['.txt', '.log', '.gz', '.zip'].some(ext =>
+            const isValidType = ['.txt', '.log', '.gz', '.zip', '.7z', '.rar', '.tar', '.tgz', '.bz2', '.xz', '.zst'].some(ext =>
                 file.name.toLowerCase().endsWith(ext)
             );
             if (!isValidType) {
-                this.$message.error('只支持 .txt, .log, .gz, .zip, .7z, .rar 格式的文件');
+                this.$message.error('只支持 .txt, .log, .zip, .7z, .rar, .tar, .gz, .tgz, .bz2, .xz, .zst 格式的文件');
                 return false;
             }
             return true;
@@ -56,13 +56,18 @@ const UploadView = {
                 if (data.success) {
 
                     this.$message.success('上传成功！'+(this.uploadTotal>0?('还有'+this.uploadTotal+'个文件正在上传'):''));
+                    // 压缩包中被拒绝或解析失败的条目
+                    const problems = (data.members || []).filter(m => m.status === 'rejected' || m.status === 'failed');
+                    if (problems.length > 0) {
+                        this.$message.warning('压缩包中有' + problems.length + '个文件未导入：' + problems.slice(0, 3).map(m => m.name + '（' + m.reason + '）').join('；'));
+                    }
                     if (this.uploadTotal === 0) {
                         this.uploading = false;
                         this.$emit('update:uploading', false)
                         this.$emit('upload-success', { fileId: data.file_id });
                     }
                 } else {
-                    this.$message.error(data.message || '上传失败');
+                    this.$message.error(data.error || data.message || '上传失败');
                 }
             }).catch(() => {
                 this.$message.error('上传失败');